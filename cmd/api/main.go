@@ -1,14 +1,30 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/joho/godotenv"
-	"github.com/v1-nce/threadtalk-backend/internal/router"
 	"github.com/v1-nce/threadtalk-backend/internal/db"
+	"github.com/v1-nce/threadtalk-backend/internal/mailer"
+	"github.com/v1-nce/threadtalk-backend/internal/publisher"
+	"github.com/v1-nce/threadtalk-backend/internal/realtime"
+	"github.com/v1-nce/threadtalk-backend/internal/retention"
+	"github.com/v1-nce/threadtalk-backend/internal/router"
+	"github.com/v1-nce/threadtalk-backend/internal/storage"
+	"github.com/v1-nce/threadtalk-backend/internal/webhooks"
 )
 
+// shutdownTimeout bounds how long in-flight requests get to finish. Event
+// streams never finish on their own and are cut off once it passes.
+const shutdownTimeout = 15 * time.Second
+
 func init() {
 	// Load Dotenv
 	if err := godotenv.Load(); err != nil {
@@ -28,9 +44,66 @@ func main() {
 	defer database.Close()
 	log.Println("Connected to PostgreSQL Database")
 
+	// Setup Attachment Storage
+	store, err := storage.FromEnv()
+	if err != nil {
+		log.Fatalf("Unable to Configure Attachment Storage due to: %v", err)
+	}
+
+	// Start Retention Worker
+	purger := retention.NewPurger(database, store, retention.ConfigFromEnv())
+	purger.Start()
+	defer purger.Stop()
+
+	// Start Draft Publisher
+	draftPublisher := publisher.NewPublisher(database, publisher.ConfigFromEnv())
+	draftPublisher.Start()
+	defer draftPublisher.Stop()
+
+	// Start Mailer
+	mail := mailer.NewMailer(database, mailer.ConfigFromEnv())
+	mail.Start()
+	defer mail.Stop()
+
+	// Start Webhook Dispatcher
+	dispatcher := webhooks.NewDispatcher(database, webhooks.ConfigFromEnv())
+	dispatcher.Start()
+	defer dispatcher.Stop()
+
+	// Start Realtime Listener
+	hub := realtime.NewHub(realtime.ConfigFromEnv())
+	listener := realtime.NewListener(os.Getenv("DATABASE_URL"), hub)
+	listener.Start()
+	defer listener.Stop()
+	presence := realtime.NewPresence(database, hub)
+	presence.Start()
+	defer presence.Stop()
+
 	// Setup Router
-	r := router.SetUpRouter(database)
+	r := router.SetUpRouter(database, store, hub, presence)
 
 	// Start Server
-	r.Run(":" + os.Getenv("PORT"))
-}
\ No newline at end of file
+	srv := &http.Server{Addr: ":" + os.Getenv("PORT"), Handler: r}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- srv.ListenAndServe()
+	}()
+	select {
+	case err := <-serveErr:
+		if !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("ERROR: Server stopped: %v", err)
+		}
+	case <-ctx.Done():
+		log.Println("Shutting down server")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			log.Printf("WARN: Graceful shutdown incomplete, closing remaining connections: %v", err)
+			srv.Close()
+		}
+	}
+
+	// Deferred calls stop the workers, then close the database.
+}
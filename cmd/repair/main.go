@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+
+	"github.com/joho/godotenv"
+	"github.com/v1-nce/threadtalk-backend/internal/db"
+)
+
+func init() {
+	// Load Dotenv
+	if err := godotenv.Load(); err != nil {
+		log.Println("No .env file found")
+	}
+}
+
+func main() {
+	batchSize := flag.Int("batch", 1000, "posts to recompute per statement")
+	flag.Parse()
+
+	// Connect Database
+	database, err := db.Connect()
+	if err != nil {
+		log.Fatalf("Unable to Connect to Database due to: %v", err)
+	}
+	defer database.Close()
+
+	// Recompute Denormalized Counters
+	fixed, err := db.RepairPostCounters(context.Background(), database, *batchSize)
+	if err != nil {
+		log.Fatalf("Counter repair failed due to: %v", err)
+	}
+	log.Printf("Counter repair complete: %d posts corrected", fixed)
+}
@@ -0,0 +1,185 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+const (
+	SubscriptionAll     = "all"
+	SubscriptionReplies = "replies"
+	SubscriptionMuted   = "muted"
+)
+
+// RecordNewPost does the bookkeeping for a post created in tx: it stores
+// mentions, subscribes the author to the post, notifies mentioned users and
+// users watching the topic, and queues post.created webhooks. Users who muted
+// the topic hear nothing.
+func RecordNewPost(ctx context.Context, tx *sql.Tx, postID, topicID, authorID int64, content string) error {
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO subscriptions (user_id, post_id, level) VALUES ($1, $2, $3)
+		ON CONFLICT DO NOTHING`, authorID, postID, SubscriptionAll); err != nil {
+		return fmt.Errorf("subscribe author: %w", err)
+	}
+	mentioned, err := SyncMentions(ctx, tx, "post_id", postID, authorID, content)
+	if err != nil {
+		return err
+	}
+	levels, err := subscriptionLevels(ctx, tx, `SELECT user_id, level FROM subscriptions WHERE topic_id = $1`, topicID)
+	if err != nil {
+		return err
+	}
+	n := Notification{Kind: NotificationMention, ActorID: authorID, PostID: &postID}
+	notified := make(map[int64]bool)
+	if err := notifyUnmuted(ctx, tx, n, mentioned, levels, notified); err != nil {
+		return err
+	}
+	n.Kind = NotificationNewPost
+	if err := notifyUnmuted(ctx, tx, n, watchers(levels), levels, notified); err != nil {
+		return err
+	}
+	return EnqueueWebhooks(ctx, tx, WebhookPostCreated, postID)
+}
+
+// RecordNewComment does the bookkeeping for a comment created in tx. The
+// author of the parent comment (or of the post, for root comments) gets a
+// reply notification, as do authors of comments further up the chain who
+// follow the thread at the "replies" level. Mentioned users get a mention, and
+// users following the post at the "all" level an activity notification. Each
+// user hears about the comment at most once, and users who muted the post or
+// its topic not at all, even when mentioned. comment.created webhooks are
+// queued as well.
+func RecordNewComment(ctx context.Context, tx *sql.Tx, commentID, postID int64, parentID *int64, authorID int64, content string) error {
+	var replyTo int64
+	var err error
+	if parentID != nil {
+		err = tx.QueryRowContext(ctx, `SELECT user_id FROM comments WHERE id = $1`, *parentID).Scan(&replyTo)
+	} else {
+		err = tx.QueryRowContext(ctx, `SELECT user_id FROM posts WHERE id = $1`, postID).Scan(&replyTo)
+	}
+	if err != nil {
+		return fmt.Errorf("load reply recipient: %w", err)
+	}
+	mentioned, err := SyncMentions(ctx, tx, "comment_id", commentID, authorID, content)
+	if err != nil {
+		return err
+	}
+	// A post subscription overrides the topic's. A topic's "muted" or
+	// "replies" level applies to every post in it that has no subscription of
+	// its own; its "all" level only covers new posts.
+	levels, err := subscriptionLevels(ctx, tx, `
+		SELECT user_id, level FROM subscriptions WHERE post_id = $1
+		UNION ALL
+		SELECT s.user_id, s.level
+		FROM subscriptions s
+		JOIN posts p ON p.topic_id = s.topic_id
+		WHERE p.id = $1 AND s.level IN ('muted', 'replies')
+			AND NOT EXISTS (SELECT 1 FROM subscriptions o WHERE o.post_id = $1 AND o.user_id = s.user_id)`, postID)
+	if err != nil {
+		return err
+	}
+	repliedTo := []int64{replyTo}
+	if parentID != nil && hasLevel(levels, SubscriptionReplies) {
+		ancestors, err := ancestorAuthors(ctx, tx, *parentID)
+		if err != nil {
+			return err
+		}
+		for _, userID := range ancestors {
+			if levels[userID] == SubscriptionReplies {
+				repliedTo = append(repliedTo, userID)
+			}
+		}
+	}
+	n := Notification{Kind: NotificationReply, ActorID: authorID, PostID: &postID, CommentID: &commentID}
+	notified := make(map[int64]bool)
+	if err := notifyUnmuted(ctx, tx, n, repliedTo, levels, notified); err != nil {
+		return err
+	}
+	n.Kind = NotificationMention
+	if err := notifyUnmuted(ctx, tx, n, mentioned, levels, notified); err != nil {
+		return err
+	}
+	n.Kind = NotificationNewComment
+	if err := notifyUnmuted(ctx, tx, n, watchers(levels), levels, notified); err != nil {
+		return err
+	}
+	return EnqueueWebhooks(ctx, tx, WebhookCommentCreated, commentID)
+}
+
+func subscriptionLevels(ctx context.Context, tx *sql.Tx, query string, id int64) (map[int64]string, error) {
+	rows, err := tx.QueryContext(ctx, query, id)
+	if err != nil {
+		return nil, fmt.Errorf("load subscriptions: %w", err)
+	}
+	defer rows.Close()
+	levels := make(map[int64]string)
+	for rows.Next() {
+		var userID int64
+		var level string
+		if err := rows.Scan(&userID, &level); err != nil {
+			return nil, fmt.Errorf("load subscriptions: %w", err)
+		}
+		levels[userID] = level
+	}
+	return levels, rows.Err()
+}
+
+// ancestorAuthors returns the authors of commentID and the comments above it.
+func ancestorAuthors(ctx context.Context, tx *sql.Tx, commentID int64) ([]int64, error) {
+	rows, err := tx.QueryContext(ctx, `
+		WITH RECURSIVE chain AS (
+			SELECT id, parent_id, user_id FROM comments WHERE id = $1
+			UNION ALL
+			SELECT c.id, c.parent_id, c.user_id FROM comments c JOIN chain ON c.id = chain.parent_id
+		)
+		SELECT DISTINCT user_id FROM chain`, commentID)
+	if err != nil {
+		return nil, fmt.Errorf("load reply chain: %w", err)
+	}
+	defer rows.Close()
+	var ids []int64
+	for rows.Next() {
+		var userID int64
+		if err := rows.Scan(&userID); err != nil {
+			return nil, fmt.Errorf("load reply chain: %w", err)
+		}
+		ids = append(ids, userID)
+	}
+	return ids, rows.Err()
+}
+
+func hasLevel(levels map[int64]string, level string) bool {
+	for _, l := range levels {
+		if l == level {
+			return true
+		}
+	}
+	return false
+}
+
+func watchers(levels map[int64]string) []int64 {
+	var ids []int64
+	for userID, level := range levels {
+		if level == SubscriptionAll {
+			ids = append(ids, userID)
+		}
+	}
+	return ids
+}
+
+// notifyUnmuted sends n to the recipients who have not muted the thread and
+// were not already notified about the same content, and marks them notified.
+// Muting is meant to silence a thread completely, so it applies to replies
+// and mentions too.
+func notifyUnmuted(ctx context.Context, tx *sql.Tx, n Notification, recipients []int64, levels map[int64]string, notified map[int64]bool) error {
+	var send []int64
+	for _, id := range recipients {
+		if id == n.ActorID || notified[id] || levels[id] == SubscriptionMuted {
+			continue
+		}
+		notified[id] = true
+		send = append(send, id)
+	}
+	return InsertNotifications(ctx, tx, n, send)
+}
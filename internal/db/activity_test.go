@@ -0,0 +1,111 @@
+package db
+
+import (
+	"context"
+	"database/sql/driver"
+	"sort"
+	"testing"
+
+	"github.com/v1-nce/threadtalk-backend/internal/dbtest"
+)
+
+func levelRows(levels map[int64]string) dbtest.Reply {
+	r := dbtest.Reply{Match: "SELECT user_id, level FROM subscriptions"}
+	for userID, level := range levels {
+		r.Rows = append(r.Rows, []driver.Value{userID, level})
+	}
+	return r
+}
+
+func userRows(match string, ids ...int64) dbtest.Reply {
+	r := dbtest.Reply{Match: match}
+	for _, id := range ids {
+		r.Rows = append(r.Rows, []driver.Value{id})
+	}
+	return r
+}
+
+func TestRecordNewComment(t *testing.T) {
+	// User 1 replies to a comment by user 2, which is itself a reply to
+	// comments by users 3 and 5.
+	const chain = "WITH RECURSIVE chain"
+	tests := []struct {
+		name      string
+		content   string
+		levels    map[int64]string
+		mentioned []int64
+		want      map[string][]int64
+		wantChain bool
+	}{
+		{
+			name: "direct reply without subscriptions",
+			want: map[string][]int64{NotificationReply: {2}},
+		},
+		{
+			name:      "replies level hears about replies further down",
+			levels:    map[int64]string{3: SubscriptionReplies},
+			want:      map[string][]int64{NotificationReply: {2, 3}},
+			wantChain: true,
+		},
+		{
+			name:      "replies level does not notify the replier",
+			levels:    map[int64]string{1: SubscriptionReplies},
+			want:      map[string][]int64{NotificationReply: {2}},
+			wantChain: true,
+		},
+		{
+			name:   "watchers hear about activity once",
+			levels: map[int64]string{2: SubscriptionAll, 7: SubscriptionAll},
+			want:   map[string][]int64{NotificationReply: {2}, NotificationNewComment: {7}},
+		},
+		{
+			name:      "muting silences replies and mentions",
+			content:   "@bob_2 @carol",
+			levels:    map[int64]string{2: SubscriptionMuted},
+			mentioned: []int64{2, 6},
+			want:      map[string][]int64{NotificationMention: {6}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			replies := []dbtest.Reply{dbtest.Row("SELECT user_id FROM comments WHERE id = $1", int64(2))}
+			if tt.mentioned != nil {
+				replies = append(replies, userRows("INSERT INTO mentions", tt.mentioned...))
+			}
+			replies = append(replies, levelRows(tt.levels), userRows(chain, 2, 3, 5))
+			conn, f := dbtest.New(t, replies...)
+			tx, err := conn.Begin()
+			if err != nil {
+				t.Fatal(err)
+			}
+			parentID := int64(8)
+			if err := RecordNewComment(context.Background(), tx, 9, 4, &parentID, 1, tt.content); err != nil {
+				t.Fatal(err)
+			}
+			got := make(map[string][]int64)
+			for _, stmt := range f.Find("INSERT INTO notifications") {
+				ids := append([]int64(nil), stmt.Args[5].([]int64)...)
+				sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+				got[stmt.Args[0].(string)] = ids
+			}
+			if len(got) != len(tt.want) {
+				t.Errorf("notified %v, want %v", got, tt.want)
+			}
+			for kind, want := range tt.want {
+				if len(got[kind]) != len(want) {
+					t.Errorf("%s notified %v, want %v", kind, got[kind], want)
+					continue
+				}
+				for i := range want {
+					if got[kind][i] != want[i] {
+						t.Errorf("%s notified %v, want %v", kind, got[kind], want)
+						break
+					}
+				}
+			}
+			if f.Executed(chain) != tt.wantChain {
+				t.Errorf("loaded reply chain = %t, want %t", f.Executed(chain), tt.wantChain)
+			}
+		})
+	}
+}
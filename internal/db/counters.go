@@ -0,0 +1,42 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// RepairPostCounters recomputes posts.comment_count and posts.last_comment_at
+// from the comments table in ID-ordered batches and returns how many posts
+// were corrected.
+func RepairPostCounters(ctx context.Context, conn *sql.DB, batchSize int) (int64, error) {
+	var fixed int64
+	var lastID int64
+	for {
+		var maxID sql.NullInt64
+		var n int64
+		err := conn.QueryRowContext(ctx, `
+			WITH batch AS (
+				SELECT id FROM posts WHERE id > $1 ORDER BY id LIMIT $2
+			), stats AS (
+				SELECT b.id, COUNT(c.id) AS cnt, MAX(c.created_at) AS last
+				FROM batch b
+				LEFT JOIN comments c ON c.post_id = b.id AND c.deleted_at IS NULL AND c.redirect_post_id IS NULL
+				GROUP BY b.id
+			), fixed AS (
+				UPDATE posts p SET comment_count = s.cnt, last_comment_at = s.last
+				FROM stats s
+				WHERE p.id = s.id AND (p.comment_count, p.last_comment_at) IS DISTINCT FROM (s.cnt, s.last)
+				RETURNING p.id
+			)
+			SELECT (SELECT MAX(id) FROM batch), (SELECT COUNT(*) FROM fixed)`, lastID, batchSize).Scan(&maxID, &n)
+		if err != nil {
+			return fixed, fmt.Errorf("repair posts after id %d: %w", lastID, err)
+		}
+		fixed += n
+		if !maxID.Valid {
+			return fixed, nil
+		}
+		lastID = maxID.Int64
+	}
+}
@@ -0,0 +1,49 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/v1-nce/threadtalk-backend/internal/dbtest"
+)
+
+func TestRepairPostCounters(t *testing.T) {
+	const batch = "WITH batch AS"
+	db, f := dbtest.New(t,
+		dbtest.Row(batch, int64(100), int64(2)),
+		dbtest.Row(batch, int64(150), int64(1)),
+		dbtest.Row(batch, nil, int64(0)), // past the last post
+	)
+	fixed, err := RepairPostCounters(context.Background(), db, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fixed != 3 {
+		t.Errorf("RepairPostCounters() = %d, want 3", fixed)
+	}
+	stmts := f.Find(batch)
+	if len(stmts) != 3 {
+		t.Fatalf("ran %d batches, want 3", len(stmts))
+	}
+	for i, after := range []int64{0, 100, 150} {
+		if got := stmts[i].Args[0]; got != after {
+			t.Errorf("batch %d started after id %v, want %d", i, got, after)
+		}
+	}
+}
+
+func TestRepairPostCountersError(t *testing.T) {
+	const batch = "WITH batch AS"
+	db, _ := dbtest.New(t,
+		dbtest.Row(batch, int64(100), int64(4)),
+		dbtest.Reply{Match: batch, Err: errors.New("connection reset")},
+	)
+	fixed, err := RepairPostCounters(context.Background(), db, 100)
+	if err == nil {
+		t.Fatal("RepairPostCounters() succeeded, want error")
+	}
+	if fixed != 4 {
+		t.Errorf("RepairPostCounters() = %d before the error, want 4", fixed)
+	}
+}
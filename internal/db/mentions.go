@@ -0,0 +1,49 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/v1-nce/threadtalk-backend/internal/mentions"
+)
+
+// SyncMentions makes the stored mentions of a post or comment match its
+// content and returns the users who were newly mentioned. column must be
+// "post_id" or "comment_id". Only existing accounts resolve, and authors never
+// mention themselves. Calling it again after an edit drops mentions that were
+// removed and reports only the added ones, so nobody is notified twice.
+func SyncMentions(ctx context.Context, tx *sql.Tx, column string, id, authorID int64, content string) ([]int64, error) {
+	names := mentions.Parse(content)
+	if names == nil {
+		names = []string{}
+	}
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf(`
+		DELETE FROM mentions
+		WHERE %s = $1 AND user_id NOT IN (SELECT id FROM users WHERE username = ANY($2))`, column),
+		id, names); err != nil {
+		return nil, fmt.Errorf("remove stale mentions: %w", err)
+	}
+	if len(names) == 0 {
+		return nil, nil
+	}
+	rows, err := tx.QueryContext(ctx, fmt.Sprintf(`
+		INSERT INTO mentions (%s, user_id)
+		SELECT $1, id FROM users WHERE username = ANY($2) AND id <> $3
+		ON CONFLICT DO NOTHING
+		RETURNING user_id`, column),
+		id, names, authorID)
+	if err != nil {
+		return nil, fmt.Errorf("insert mentions: %w", err)
+	}
+	defer rows.Close()
+	var added []int64
+	for rows.Next() {
+		var userID int64
+		if err := rows.Scan(&userID); err != nil {
+			return nil, fmt.Errorf("insert mentions: %w", err)
+		}
+		added = append(added, userID)
+	}
+	return added, rows.Err()
+}
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_posts_topic_pinned;
+
+ALTER TABLE posts DROP COLUMN IF EXISTS locked_at;
+ALTER TABLE posts DROP COLUMN IF EXISTS pinned_at;
+
+ALTER TABLE users DROP COLUMN IF EXISTS role;
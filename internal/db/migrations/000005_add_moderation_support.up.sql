@@ -0,0 +1,6 @@
+ALTER TABLE users ADD COLUMN role VARCHAR(20) NOT NULL DEFAULT 'user' CHECK (role IN ('user', 'moderator', 'admin'));
+
+ALTER TABLE posts ADD COLUMN pinned_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE posts ADD COLUMN locked_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX idx_posts_topic_pinned ON posts(topic_id, pinned_at DESC) WHERE pinned_at IS NOT NULL; -- Optimizes loading pinned posts
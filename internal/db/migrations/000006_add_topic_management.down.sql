@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_topics_listing;
+
+ALTER TABLE topics DROP CONSTRAINT IF EXISTS topics_slug_key;
+ALTER TABLE topics DROP COLUMN IF EXISTS archived_at;
+ALTER TABLE topics DROP COLUMN IF EXISTS position;
+ALTER TABLE topics DROP COLUMN IF EXISTS slug;
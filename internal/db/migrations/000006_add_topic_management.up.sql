@@ -0,0 +1,14 @@
+ALTER TABLE topics ADD COLUMN slug VARCHAR(60);
+ALTER TABLE topics ADD COLUMN position INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE topics ADD COLUMN archived_at TIMESTAMP WITH TIME ZONE;
+
+-- Backfill slugs from names, keeping them non-numeric and unique
+UPDATE topics SET slug = LEFT(TRIM(BOTH '-' FROM REGEXP_REPLACE(LOWER(name), '[^a-z0-9]+', '-', 'g')), 50);
+UPDATE topics SET slug = 't-' || id WHERE slug = '' OR slug ~ '^[0-9]+$';
+UPDATE topics t SET slug = t.slug || '-' || t.id
+WHERE EXISTS (SELECT 1 FROM topics o WHERE o.slug = t.slug AND o.id < t.id);
+
+ALTER TABLE topics ALTER COLUMN slug SET NOT NULL;
+ALTER TABLE topics ADD CONSTRAINT topics_slug_key UNIQUE (slug);
+
+CREATE INDEX idx_topics_listing ON topics(position, name) WHERE archived_at IS NULL; -- Optimizes default topic listing
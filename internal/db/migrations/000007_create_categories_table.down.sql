@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_topics_listing;
+
+ALTER TABLE topics DROP COLUMN IF EXISTS category_id;
+
+CREATE INDEX idx_topics_listing ON topics(position, name) WHERE archived_at IS NULL;
+
+DROP INDEX IF EXISTS idx_categories_parent;
+DROP TABLE IF EXISTS categories;
@@ -0,0 +1,17 @@
+CREATE TABLE categories (
+    id BIGSERIAL PRIMARY KEY,
+    name VARCHAR(50) NOT NULL,
+    slug VARCHAR(60) NOT NULL UNIQUE,
+    description TEXT NOT NULL DEFAULT '',
+    parent_id BIGINT REFERENCES categories(id) ON DELETE RESTRICT,
+    position INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CHECK (parent_id IS NULL OR parent_id <> id)
+);
+
+CREATE INDEX idx_categories_parent ON categories(parent_id, position);
+
+ALTER TABLE topics ADD COLUMN category_id BIGINT REFERENCES categories(id) ON DELETE SET NULL;
+
+DROP INDEX IF EXISTS idx_topics_listing;
+CREATE INDEX idx_topics_listing ON topics(category_id, position, name) WHERE archived_at IS NULL; -- Optimizes default topic listing
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_comments_parent;
+
+ALTER TABLE comments DROP COLUMN IF EXISTS redirect_post_id;
+ALTER TABLE posts DROP COLUMN IF EXISTS redirect_post_id;
@@ -0,0 +1,4 @@
+ALTER TABLE posts ADD COLUMN redirect_post_id BIGINT REFERENCES posts(id) ON DELETE SET NULL;
+ALTER TABLE comments ADD COLUMN redirect_post_id BIGINT REFERENCES posts(id) ON DELETE SET NULL;
+
+CREATE INDEX idx_comments_parent ON comments(parent_id); -- Optimizes walking comment subtrees
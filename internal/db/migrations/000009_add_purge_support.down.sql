@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_comments_purge;
+DROP INDEX IF EXISTS idx_posts_purge;
+
+ALTER TABLE comments DROP COLUMN IF EXISTS purged_at;
+ALTER TABLE posts DROP COLUMN IF EXISTS purged_at;
@@ -0,0 +1,5 @@
+ALTER TABLE posts ADD COLUMN purged_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE comments ADD COLUMN purged_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX idx_posts_purge ON posts(deleted_at) WHERE deleted_at IS NOT NULL AND purged_at IS NULL;       -- Optimizes retention sweeps
+CREATE INDEX idx_comments_purge ON comments(deleted_at) WHERE deleted_at IS NOT NULL AND purged_at IS NULL; -- Optimizes retention sweeps
@@ -0,0 +1,11 @@
+DROP TRIGGER IF EXISTS comments_stats_delete ON comments;
+DROP TRIGGER IF EXISTS comments_stats_update ON comments;
+DROP TRIGGER IF EXISTS comments_stats_insert ON comments;
+
+DROP FUNCTION IF EXISTS comments_stats_after_delete();
+DROP FUNCTION IF EXISTS comments_stats_after_update();
+DROP FUNCTION IF EXISTS comments_stats_after_insert();
+DROP FUNCTION IF EXISTS refresh_post_comment_stats(BIGINT[]);
+
+ALTER TABLE posts DROP COLUMN IF EXISTS last_comment_at;
+ALTER TABLE posts DROP COLUMN IF EXISTS comment_count;
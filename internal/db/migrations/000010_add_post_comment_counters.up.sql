@@ -0,0 +1,48 @@
+ALTER TABLE posts ADD COLUMN comment_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE posts ADD COLUMN last_comment_at TIMESTAMP WITH TIME ZONE;
+
+-- Recomputes counters for the given posts from live, non-placeholder comments
+CREATE FUNCTION refresh_post_comment_stats(post_ids BIGINT[]) RETURNS void AS $$
+    UPDATE posts p
+    SET comment_count = s.cnt, last_comment_at = s.last
+    FROM (
+        SELECT ids.id, COUNT(c.id) AS cnt, MAX(c.created_at) AS last
+        FROM unnest(post_ids) AS ids(id)
+        LEFT JOIN comments c ON c.post_id = ids.id AND c.deleted_at IS NULL AND c.redirect_post_id IS NULL
+        GROUP BY ids.id
+    ) s
+    WHERE p.id = s.id AND (p.comment_count, p.last_comment_at) IS DISTINCT FROM (s.cnt, s.last);
+$$ LANGUAGE sql;
+
+CREATE FUNCTION comments_stats_after_insert() RETURNS trigger AS $$
+BEGIN
+    PERFORM refresh_post_comment_stats(ARRAY(SELECT DISTINCT post_id FROM new_rows));
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE FUNCTION comments_stats_after_update() RETURNS trigger AS $$
+BEGIN
+    PERFORM refresh_post_comment_stats(ARRAY(SELECT post_id FROM new_rows UNION SELECT post_id FROM old_rows));
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE FUNCTION comments_stats_after_delete() RETURNS trigger AS $$
+BEGIN
+    PERFORM refresh_post_comment_stats(ARRAY(SELECT DISTINCT post_id FROM old_rows));
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER comments_stats_insert AFTER INSERT ON comments
+    REFERENCING NEW TABLE AS new_rows
+    FOR EACH STATEMENT EXECUTE FUNCTION comments_stats_after_insert();
+CREATE TRIGGER comments_stats_update AFTER UPDATE ON comments
+    REFERENCING OLD TABLE AS old_rows NEW TABLE AS new_rows
+    FOR EACH STATEMENT EXECUTE FUNCTION comments_stats_after_update();
+CREATE TRIGGER comments_stats_delete AFTER DELETE ON comments
+    REFERENCING OLD TABLE AS old_rows
+    FOR EACH STATEMENT EXECUTE FUNCTION comments_stats_after_delete();
+
+SELECT refresh_post_comment_stats(ARRAY(SELECT id FROM posts));
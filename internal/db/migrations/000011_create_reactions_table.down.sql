@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_reactions_comment_user;
+DROP INDEX IF EXISTS idx_reactions_post_user;
+
+DROP TABLE IF EXISTS reactions;
@@ -0,0 +1,12 @@
+CREATE TABLE reactions (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    post_id BIGINT REFERENCES posts(id) ON DELETE CASCADE,
+    comment_id BIGINT REFERENCES comments(id) ON DELETE CASCADE,
+    emoji VARCHAR(32) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CHECK ((post_id IS NULL) <> (comment_id IS NULL))
+);
+
+CREATE UNIQUE INDEX idx_reactions_post_user ON reactions(post_id, user_id, emoji) WHERE post_id IS NOT NULL;          -- One reaction per emoji per user
+CREATE UNIQUE INDEX idx_reactions_comment_user ON reactions(comment_id, user_id, emoji) WHERE comment_id IS NOT NULL; -- One reaction per emoji per user
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_posts_accepted_comment;
+
+ALTER TABLE posts DROP COLUMN IF EXISTS accepted_comment_id;
+
+ALTER TABLE topics DROP COLUMN IF EXISTS kind;
@@ -0,0 +1,5 @@
+ALTER TABLE topics ADD COLUMN kind VARCHAR(20) NOT NULL DEFAULT 'discussion' CHECK (kind IN ('discussion', 'question'));
+
+ALTER TABLE posts ADD COLUMN accepted_comment_id BIGINT REFERENCES comments(id) ON DELETE SET NULL;
+
+CREATE INDEX idx_posts_accepted_comment ON posts(accepted_comment_id) WHERE accepted_comment_id IS NOT NULL; -- Optimizes counting a user's accepted answers
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_poll_votes_poll_user;
+
+DROP TABLE IF EXISTS poll_votes;
+DROP TABLE IF EXISTS poll_options;
+DROP TABLE IF EXISTS polls;
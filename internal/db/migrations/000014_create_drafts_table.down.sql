@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_drafts_scheduled;
+DROP INDEX IF EXISTS idx_drafts_user;
+
+DROP TABLE IF EXISTS drafts;
@@ -0,0 +1,19 @@
+CREATE TABLE drafts (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    kind VARCHAR(10) NOT NULL CHECK (kind IN ('post', 'comment')),
+    title VARCHAR(250) NOT NULL DEFAULT '',
+    content TEXT NOT NULL DEFAULT '',
+    topic_id BIGINT REFERENCES topics(id) ON DELETE SET NULL,
+    post_id BIGINT REFERENCES posts(id) ON DELETE CASCADE,
+    parent_id BIGINT REFERENCES comments(id) ON DELETE SET NULL,
+    tags TEXT NOT NULL DEFAULT '',
+    scheduled_at TIMESTAMP WITH TIME ZONE,
+    last_error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CHECK (scheduled_at IS NULL OR kind = 'post')
+);
+
+CREATE INDEX idx_drafts_user ON drafts(user_id, updated_at DESC);                          -- Optimizes listing a user's drafts
+CREATE INDEX idx_drafts_scheduled ON drafts(scheduled_at) WHERE scheduled_at IS NOT NULL; -- Optimizes finding drafts due for publishing
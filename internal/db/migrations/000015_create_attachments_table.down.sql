@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_attachments_comment;
+DROP INDEX IF EXISTS idx_attachments_post;
+
+DROP TABLE IF EXISTS attachments;
+DROP TABLE IF EXISTS blobs;
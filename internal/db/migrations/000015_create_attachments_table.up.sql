@@ -0,0 +1,20 @@
+CREATE TABLE blobs (
+    sha256 CHAR(64) PRIMARY KEY,
+    content_type VARCHAR(100) NOT NULL,
+    size BIGINT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE attachments (
+    id BIGSERIAL PRIMARY KEY,
+    blob_sha256 CHAR(64) NOT NULL REFERENCES blobs(sha256),
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    post_id BIGINT REFERENCES posts(id) ON DELETE CASCADE,
+    comment_id BIGINT REFERENCES comments(id) ON DELETE CASCADE,
+    filename VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CHECK ((post_id IS NULL) <> (comment_id IS NULL))
+);
+
+CREATE INDEX idx_attachments_post ON attachments(post_id) WHERE post_id IS NOT NULL;          -- Optimizes loading a post's attachments
+CREATE INDEX idx_attachments_comment ON attachments(comment_id) WHERE comment_id IS NOT NULL; -- Optimizes loading a comment's attachments
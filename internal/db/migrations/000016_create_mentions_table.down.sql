@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS idx_notifications_user;
+DROP TABLE IF EXISTS notifications;
+
+DROP TABLE IF EXISTS user_blocks;
+
+DROP INDEX IF EXISTS idx_mentions_user;
+DROP INDEX IF EXISTS idx_mentions_comment_user;
+DROP INDEX IF EXISTS idx_mentions_post_user;
+DROP TABLE IF EXISTS mentions;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_notifications_unread;
+
+ALTER TABLE notifications DROP COLUMN IF EXISTS action;
@@ -0,0 +1,3 @@
+ALTER TABLE notifications ADD COLUMN action VARCHAR(30);
+
+CREATE INDEX idx_notifications_unread ON notifications(user_id, id DESC) WHERE read_at IS NULL; -- Optimizes unread filter and counts
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_subscriptions_user;
+DROP INDEX IF EXISTS idx_subscriptions_topic_user;
+DROP INDEX IF EXISTS idx_subscriptions_post_user;
+DROP TABLE IF EXISTS subscriptions;
@@ -0,0 +1,18 @@
+CREATE TABLE subscriptions (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    post_id BIGINT REFERENCES posts(id) ON DELETE CASCADE,
+    topic_id BIGINT REFERENCES topics(id) ON DELETE CASCADE,
+    level VARCHAR(10) NOT NULL CHECK (level IN ('all', 'replies', 'muted')),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CHECK ((post_id IS NULL) <> (topic_id IS NULL))
+);
+
+CREATE UNIQUE INDEX idx_subscriptions_post_user ON subscriptions(post_id, user_id) WHERE post_id IS NOT NULL;    -- One subscription per user per post
+CREATE UNIQUE INDEX idx_subscriptions_topic_user ON subscriptions(topic_id, user_id) WHERE topic_id IS NOT NULL; -- One subscription per user per topic
+CREATE INDEX idx_subscriptions_user ON subscriptions(user_id, id DESC);                                         -- Optimizes listing a user's subscriptions
+
+-- Authors follow their existing posts, as they do new ones.
+INSERT INTO subscriptions (user_id, post_id, level)
+SELECT user_id, id, 'all' FROM posts WHERE deleted_at IS NULL AND redirect_post_id IS NULL;
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_email_outbox_pending;
+DROP INDEX IF EXISTS idx_email_outbox_done;
+DROP INDEX IF EXISTS idx_email_settings_unverified;
+DROP TABLE IF EXISTS email_outbox;
+DROP TABLE IF EXISTS email_settings;
@@ -0,0 +1,30 @@
+CREATE TABLE email_settings (
+    user_id BIGINT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    email VARCHAR(254) NOT NULL,
+    frequency VARCHAR(10) NOT NULL DEFAULT 'immediate' CHECK (frequency IN ('off', 'immediate', 'daily', 'weekly')),
+    unsubscribe_token CHAR(64) NOT NULL UNIQUE,
+    verified_at TIMESTAMP WITH TIME ZONE,
+    verification_token CHAR(64) UNIQUE,
+    verification_sent_at TIMESTAMP WITH TIME ZONE,
+    verification_attempts INT NOT NULL DEFAULT 0,
+    verification_next_attempt_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_digest_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE email_outbox (
+    id BIGSERIAL PRIMARY KEY,
+    notification_id BIGINT NOT NULL UNIQUE REFERENCES notifications(id) ON DELETE CASCADE,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    status VARCHAR(10) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'sent', 'skipped', 'failed')),
+    attempts INT NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_error TEXT NOT NULL DEFAULT '',
+    sent_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_email_settings_unverified ON email_settings(user_id) WHERE verification_token IS NOT NULL AND verification_sent_at IS NULL; -- Optimizes the mailer's scan for verification emails
+CREATE INDEX idx_email_outbox_pending ON email_outbox(user_id, next_attempt_at) WHERE status = 'pending';                                    -- Optimizes the mailer's scan for due emails
+CREATE INDEX idx_email_outbox_done ON email_outbox(created_at) WHERE status <> 'pending';                                                    -- Optimizes pruning delivered and abandoned emails
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_incoming_webhooks_bot;
+DROP INDEX IF EXISTS idx_incoming_webhooks_topic;
+DROP TABLE IF EXISTS incoming_webhooks;
@@ -0,0 +1,18 @@
+CREATE TABLE incoming_webhooks (
+    id BIGSERIAL PRIMARY KEY,
+    topic_id BIGINT NOT NULL REFERENCES topics(id) ON DELETE CASCADE,
+    bot_user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    token_hash CHAR(64) NOT NULL UNIQUE,
+    title_template TEXT NOT NULL DEFAULT '',
+    content_template TEXT NOT NULL DEFAULT '',
+    rate_limit INT NOT NULL DEFAULT 30 CHECK (rate_limit BETWEEN 1 AND 600),
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    last_used_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_incoming_webhooks_topic ON incoming_webhooks(topic_id); -- Optimizes topic deletes and listing by topic
+CREATE INDEX idx_incoming_webhooks_bot ON incoming_webhooks(bot_user_id); -- Optimizes bot user lookups
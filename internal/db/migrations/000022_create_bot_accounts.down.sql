@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_bot_tokens_bot;
+DROP INDEX IF EXISTS idx_users_owner;
+DROP TABLE IF EXISTS bot_tokens;
+ALTER TABLE users
+    DROP CONSTRAINT IF EXISTS users_owner_is_bot,
+    DROP COLUMN IF EXISTS owner_id,
+    DROP COLUMN IF EXISTS is_bot;
@@ -0,0 +1,19 @@
+ALTER TABLE users
+    ADD COLUMN is_bot BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN owner_id BIGINT REFERENCES users(id) ON DELETE CASCADE,
+    ADD CONSTRAINT users_owner_is_bot CHECK (owner_id IS NULL OR is_bot);
+
+-- Incoming webhook users were already bots in all but name.
+UPDATE users SET is_bot = TRUE WHERE id IN (SELECT bot_user_id FROM incoming_webhooks);
+
+CREATE TABLE bot_tokens (
+    id BIGSERIAL PRIMARY KEY,
+    bot_user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    token_hash CHAR(64) NOT NULL UNIQUE,
+    last_used_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_users_owner ON users(owner_id) WHERE owner_id IS NOT NULL; -- Optimizes listing an owner's bots
+CREATE INDEX idx_bot_tokens_bot ON bot_tokens(bot_user_id); -- Optimizes listing a bot's tokens
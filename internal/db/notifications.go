@@ -0,0 +1,68 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+const (
+	NotificationMention    = "mention"
+	NotificationReply      = "reply"
+	NotificationModeration = "moderation"
+	NotificationNewPost    = "new_post"
+	NotificationNewComment = "new_comment"
+)
+
+// Execer is satisfied by both *sql.DB and *sql.Tx.
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// Notification describes something actorID did that recipients should hear
+// about. PostID and CommentID point at the content involved; Action says what
+// a moderator did for moderation notifications.
+type Notification struct {
+	Kind      string
+	Action    string
+	ActorID   int64
+	PostID    *int64
+	CommentID *int64
+}
+
+// InsertNotifications stores n for each recipient. The actor is never
+// notified about their own activity, and recipients who have blocked the
+// actor are skipped unless it is a moderation notice. Recipients with a
+// verified address and email enabled also get an outbox row in the same
+// statement, so the mailer sees exactly the notifications that were
+// committed.
+func InsertNotifications(ctx context.Context, ex Execer, n Notification, recipients []int64) error {
+	if len(recipients) == 0 {
+		return nil
+	}
+	var action *string
+	if n.Action != "" {
+		action = &n.Action
+	}
+	blocks := ""
+	if n.Kind != NotificationModeration {
+		blocks = ` AND NOT EXISTS (SELECT 1 FROM user_blocks b WHERE b.blocker_id = u.id AND b.blocked_id = $3)`
+	}
+	query := `
+		WITH inserted AS (
+			INSERT INTO notifications (user_id, kind, action, actor_id, post_id, comment_id)
+			SELECT u.id, $1, $2, $3, $4, $5
+			FROM users u
+			WHERE u.id = ANY($6) AND u.id <> $3` + blocks + `
+			RETURNING id, user_id
+		)
+		INSERT INTO email_outbox (notification_id, user_id)
+		SELECT i.id, i.user_id
+		FROM inserted i
+		JOIN email_settings e ON e.user_id = i.user_id
+		WHERE e.frequency <> 'off' AND e.verified_at IS NOT NULL`
+	if _, err := ex.ExecContext(ctx, query, n.Kind, action, n.ActorID, n.PostID, n.CommentID, recipients); err != nil {
+		return fmt.Errorf("insert notifications: %w", err)
+	}
+	return nil
+}
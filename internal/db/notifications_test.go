@@ -0,0 +1,42 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"github.com/v1-nce/threadtalk-backend/internal/dbtest"
+)
+
+func TestInsertNotifications(t *testing.T) {
+	const blocks = "user_blocks"
+	tests := []struct {
+		name       string
+		kind       string
+		recipients []int64
+		wantInsert bool
+		wantBlocks bool
+	}{
+		{"no recipients", NotificationReply, nil, false, false},
+		{"reply respects blocks", NotificationReply, []int64{2, 3}, true, true},
+		{"mention respects blocks", NotificationMention, []int64{2}, true, true},
+		{"moderation ignores blocks", NotificationModeration, []int64{2}, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, f := dbtest.New(t)
+			n := Notification{Kind: tt.kind, ActorID: 1}
+			if err := InsertNotifications(context.Background(), db, n, tt.recipients); err != nil {
+				t.Fatal(err)
+			}
+			if got := f.Executed("INSERT INTO notifications"); got != tt.wantInsert {
+				t.Errorf("inserted = %t, want %t", got, tt.wantInsert)
+			}
+			if got := f.Executed(blocks); got != tt.wantBlocks {
+				t.Errorf("checked blocks = %t, want %t", got, tt.wantBlocks)
+			}
+			if tt.wantInsert && !f.Executed("u.id <> $3") {
+				t.Error("actor is not excluded from recipients")
+			}
+		})
+	}
+}
@@ -0,0 +1,25 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// InsertPostTags creates any missing tags and links them to a post. tags must
+// already be normalized.
+func InsertPostTags(ctx context.Context, tx *sql.Tx, postID int64, tags []string) error {
+	if len(tags) == 0 {
+		return nil
+	}
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO tags (name) SELECT unnest($1::text[]) ON CONFLICT (name) DO NOTHING`, tags); err != nil {
+		return fmt.Errorf("insert tags: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO post_tags (post_id, tag_id) SELECT $1, id FROM tags WHERE name = ANY($2) ON CONFLICT DO NOTHING`,
+		postID, tags); err != nil {
+		return fmt.Errorf("link post tags: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package db
+
+import (
+	"context"
+	"fmt"
+)
+
+const (
+	WebhookPostCreated    = "post.created"
+	WebhookPostDeleted    = "post.deleted"
+	WebhookCommentCreated = "comment.created"
+)
+
+// WebhookEvents lists the events outgoing webhooks can subscribe to.
+var WebhookEvents = []string{WebhookPostCreated, WebhookPostDeleted, WebhookCommentCreated}
+
+// webhookPayloads builds each event's data from the row with id $2, as it is
+// at enqueue time.
+var webhookPayloads = map[string]string{
+	WebhookPostCreated: `
+		SELECT json_build_object(
+			'id', p.id::text, 'topic_id', p.topic_id::text, 'title', p.title, 'content', p.content,
+			'author', u.username, 'author_is_bot', u.is_bot, 'created_at', p.created_at)
+		FROM posts p JOIN users u ON u.id = p.user_id
+		WHERE p.id = $2`,
+	WebhookPostDeleted: `
+		SELECT json_build_object(
+			'id', p.id::text, 'topic_id', p.topic_id::text, 'title', p.title, 'deleted_at', p.deleted_at)
+		FROM posts p
+		WHERE p.id = $2`,
+	WebhookCommentCreated: `
+		SELECT json_build_object(
+			'id', c.id::text, 'post_id', c.post_id::text, 'parent_id', c.parent_id::text, 'content', c.content,
+			'author', u.username, 'author_is_bot', u.is_bot, 'created_at', c.created_at)
+		FROM comments c JOIN users u ON u.id = c.user_id
+		WHERE c.id = $2`,
+}
+
+// EnqueueWebhooks queues event for every active webhook subscribed to it. Run
+// in the transaction that made the change, deliveries exist exactly when the
+// change commits; the webhooks dispatcher sends them afterwards.
+func EnqueueWebhooks(ctx context.Context, ex Execer, event string, id int64) error {
+	payload, ok := webhookPayloads[event]
+	if !ok {
+		return fmt.Errorf("unknown webhook event %q", event)
+	}
+	query := `
+		INSERT INTO webhook_deliveries (webhook_id, event, payload)
+		SELECT w.id, $1, (` + payload + `)
+		FROM webhooks w
+		WHERE w.active AND $1 = ANY(w.events)`
+	if _, err := ex.ExecContext(ctx, query, event, id); err != nil {
+		return fmt.Errorf("enqueue %s webhooks: %w", event, err)
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+// Package dbtest provides a database/sql driver that answers from a script,
+// for tests that need a few queries to succeed or fail in a particular way
+// without a running Postgres.
+package dbtest
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Reply is what the fake database answers to the first statement that
+// contains Match. Each reply is used once, in order.
+type Reply struct {
+	Match    string
+	Rows     [][]driver.Value
+	Affected int64
+	Err      error
+}
+
+// Row is a single-row reply to the first statement containing match.
+func Row(match string, values ...driver.Value) Reply {
+	return Reply{Match: match, Rows: [][]driver.Value{values}}
+}
+
+// Stmt is a statement the fake database ran.
+type Stmt struct {
+	Query string
+	Args  []driver.Value
+}
+
+// DB records statements and answers them from its replies. Statements
+// without a reply return no rows and affect nothing.
+type DB struct {
+	mu      sync.Mutex
+	replies []*Reply
+	used    map[*Reply]bool
+	stmts   []Stmt
+}
+
+// New opens a fake database that is closed when the test ends.
+func New(t *testing.T, replies ...Reply) (*sql.DB, *DB) {
+	t.Helper()
+	f := &DB{used: map[*Reply]bool{}}
+	for i := range replies {
+		f.replies = append(f.replies, &replies[i])
+	}
+	db := sql.OpenDB(f)
+	t.Cleanup(func() { db.Close() })
+	return db, f
+}
+
+// Executed reports whether a statement containing s was run.
+func (f *DB) Executed(s string) bool {
+	return len(f.Find(s)) > 0
+}
+
+// Find returns the statements containing s, in the order they ran.
+func (f *DB) Find(s string) []Stmt {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var found []Stmt
+	for _, stmt := range f.stmts {
+		if strings.Contains(stmt.Query, s) {
+			found = append(found, stmt)
+		}
+	}
+	return found
+}
+
+func (f *DB) reply(query string, args []driver.NamedValue) *Reply {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	stmt := Stmt{Query: query}
+	for _, a := range args {
+		stmt.Args = append(stmt.Args, a.Value)
+	}
+	f.stmts = append(f.stmts, stmt)
+	for _, r := range f.replies {
+		if !f.used[r] && strings.Contains(query, r.Match) {
+			f.used[r] = true
+			return r
+		}
+	}
+	return &Reply{}
+}
+
+func (f *DB) Connect(context.Context) (driver.Conn, error) { return conn{f}, nil }
+func (f *DB) Driver() driver.Driver                        { return nil }
+
+type conn struct{ f *DB }
+
+func (c conn) Prepare(query string) (driver.Stmt, error) { return stmt{c.f, query}, nil }
+func (c conn) Close() error                              { return nil }
+func (c conn) Begin() (driver.Tx, error)                 { return tx{}, nil }
+
+func (c conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	r := c.f.reply(query, args)
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	return &rows{rows: r.Rows}, nil
+}
+
+func (c conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	r := c.f.reply(query, args)
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	return driver.RowsAffected(r.Affected), nil
+}
+
+// CheckNamedValue accepts any argument, including slices for ANY($1).
+func (c conn) CheckNamedValue(*driver.NamedValue) error { return nil }
+
+type stmt struct {
+	f     *DB
+	query string
+}
+
+func (s stmt) Close() error  { return nil }
+func (s stmt) NumInput() int { return -1 }
+
+func (s stmt) Exec(args []driver.Value) (driver.Result, error) {
+	return conn{s.f}.ExecContext(context.Background(), s.query, nil)
+}
+
+func (s stmt) Query(args []driver.Value) (driver.Rows, error) {
+	return conn{s.f}.QueryContext(context.Background(), s.query, nil)
+}
+
+type tx struct{}
+
+func (tx) Commit() error   { return nil }
+func (tx) Rollback() error { return nil }
+
+type rows struct {
+	rows [][]driver.Value
+}
+
+func (r *rows) Columns() []string {
+	if len(r.rows) == 0 {
+		return nil
+	}
+	return make([]string, len(r.rows[0]))
+}
+
+func (r *rows) Close() error { return nil }
+
+func (r *rows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/v1-nce/threadtalk-backend/internal/models"
+)
+
+var (
+	errNotQuestion   = errors.New("post is not in a question topic")
+	errNotPostAuthor = errors.New("only the post author or a moderator can accept an answer")
+	errNotAcceptable = errors.New("only live root comments on this post can be accepted")
+)
+
+func (h *ForumHandler) AcceptAnswer(c *gin.Context) {
+	var input models.AcceptAnswerInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+	h.setAcceptedAnswer(c, &input.CommentID)
+}
+
+func (h *ForumHandler) UnacceptAnswer(c *gin.Context) {
+	h.setAcceptedAnswer(c, nil)
+}
+
+// setAcceptedAnswer marks commentID as the accepted answer on a question post,
+// or clears it when commentID is nil.
+func (h *ForumHandler) setAcceptedAnswer(c *gin.Context, commentID *int64) {
+	postID, err := strconv.ParseInt(c.Param("post_id"), 10, 64)
+	if err != nil || postID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid post ID"})
+		return
+	}
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+	err = h.withTx(ctx, func(tx *sql.Tx) error {
+		var authorID int64
+		var kind string
+		err := tx.QueryRowContext(ctx, `
+			SELECT p.user_id, t.kind
+			FROM posts p
+			JOIN topics t ON t.id = p.topic_id
+			WHERE p.id = $1 AND p.deleted_at IS NULL AND p.redirect_post_id IS NULL
+			FOR UPDATE OF p`, postID).Scan(&authorID, &kind)
+		if err == sql.ErrNoRows {
+			return errPostNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if kind != models.TopicKindQuestion {
+			return errNotQuestion
+		}
+		if authorID != userID {
+			role, err := userRole(ctx, h.DB, userID)
+			if err != nil {
+				return err
+			}
+			if !models.IsModeratorRole(role) {
+				return errNotPostAuthor
+			}
+		}
+		if commentID != nil {
+			var acceptable bool
+			err := tx.QueryRowContext(ctx, `
+				SELECT EXISTS (
+					SELECT 1 FROM comments
+					WHERE id = $1 AND post_id = $2 AND parent_id IS NULL
+						AND deleted_at IS NULL AND redirect_post_id IS NULL
+				)`, *commentID, postID).Scan(&acceptable)
+			if err != nil {
+				return err
+			}
+			if !acceptable {
+				return errNotAcceptable
+			}
+		}
+		_, err = tx.ExecContext(ctx, `UPDATE posts SET accepted_comment_id = $1 WHERE id = $2`, commentID, postID)
+		return err
+	})
+	if err != nil {
+		switch {
+		case ctx.Err() == context.DeadlineExceeded:
+			log.Printf("WARN: Request timeout setting accepted answer on post %d by user %d", postID, userID)
+			c.JSON(http.StatusRequestTimeout, gin.H{"error": "Request timeout"})
+		case errors.Is(err, errPostNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Post not found"})
+		case errors.Is(err, errNotPostAuthor):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		case errors.Is(err, errNotQuestion), errors.Is(err, errNotAcceptable):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			log.Printf("ERROR: Failed to set accepted answer on post %d by user %d: %v", postID, userID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update accepted answer"})
+		}
+		return
+	}
+	var accepted interface{}
+	if commentID != nil {
+		accepted = strconv.FormatInt(*commentID, 10)
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"post_id":             strconv.FormatInt(postID, 10),
+		"accepted_comment_id": accepted,
+	})
+}
+
+// acceptedAnswerFirst moves the accepted root comment to the front and flags
+// it. Other comments keep their chronological order.
+func acceptedAnswerFirst(roots []*models.Comment, acceptedID int64) []*models.Comment {
+	for i, c := range roots {
+		if c.ID == acceptedID {
+			c.Accepted = true
+			copy(roots[1:i+1], roots[:i])
+			roots[0] = c
+			break
+		}
+	}
+	return roots
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/v1-nce/threadtalk-backend/internal/dbtest"
+	"github.com/v1-nce/threadtalk-backend/internal/models"
+)
+
+func TestAcceptedAnswerFirst(t *testing.T) {
+	tests := []struct {
+		name       string
+		ids        []int64
+		acceptedID int64
+		want       []int64
+	}{
+		{"accepted in the middle", []int64{1, 2, 3, 4}, 3, []int64{3, 1, 2, 4}},
+		{"accepted last", []int64{1, 2, 3}, 3, []int64{3, 1, 2}},
+		{"accepted already first", []int64{1, 2, 3}, 1, []int64{1, 2, 3}},
+		{"accepted not on this page", []int64{1, 2, 3}, 9, []int64{1, 2, 3}},
+		{"no accepted answer", []int64{1, 2}, 0, []int64{1, 2}},
+		{"no comments", nil, 3, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var roots []*models.Comment
+			for _, id := range tt.ids {
+				roots = append(roots, &models.Comment{ID: id})
+			}
+			got := acceptedAnswerFirst(roots, tt.acceptedID)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d comments, want %d", len(got), len(tt.want))
+			}
+			for i, c := range got {
+				if c.ID != tt.want[i] {
+					t.Errorf("comment %d = %d, want %d", i, c.ID, tt.want[i])
+				}
+				if c.Accepted != (c.ID == tt.acceptedID) {
+					t.Errorf("comment %d accepted = %t", c.ID, c.Accepted)
+				}
+			}
+		})
+	}
+}
+
+func TestAcceptAnswer(t *testing.T) {
+	const body = `{"comment_id": "7"}`
+	post := func(authorID int64, kind string) dbtest.Reply {
+		return dbtest.Row("SELECT p.user_id, t.kind", authorID, kind)
+	}
+	tests := []struct {
+		name       string
+		replies    []dbtest.Reply
+		wantStatus int
+		wantUpdate bool
+	}{
+		{"author accepts a root comment", []dbtest.Reply{post(1, models.TopicKindQuestion), dbtest.Row("SELECT EXISTS", true)}, http.StatusOK, true},
+		{"moderator accepts for the author", []dbtest.Reply{post(2, models.TopicKindQuestion), dbtest.Row("SELECT role FROM users", "moderator"), dbtest.Row("SELECT EXISTS", true)}, http.StatusOK, true},
+		{"other member", []dbtest.Reply{post(2, models.TopicKindQuestion), dbtest.Row("SELECT role FROM users", "user")}, http.StatusForbidden, false},
+		{"discussion topic", []dbtest.Reply{post(1, "discussion")}, http.StatusBadRequest, false},
+		{"reply or comment on another post", []dbtest.Reply{post(1, models.TopicKindQuestion), dbtest.Row("SELECT EXISTS", false)}, http.StatusBadRequest, false},
+		{"missing post", nil, http.StatusNotFound, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, f := dbtest.New(t, tt.replies...)
+			h := &ForumHandler{DB: db}
+			status, resp := callHandler(t, h.AcceptAnswer, body, "post_id", "5")
+			if status != tt.wantStatus {
+				t.Errorf("status = %d %v, want %d", status, resp, tt.wantStatus)
+			}
+			if got := f.Executed("SET accepted_comment_id"); got != tt.wantUpdate {
+				t.Errorf("accepted answer updated = %t, want %t", got, tt.wantUpdate)
+			}
+			if tt.wantStatus == http.StatusOK && resp["accepted_comment_id"] != "7" {
+				t.Errorf("accepted_comment_id = %v, want \"7\"", resp["accepted_comment_id"])
+			}
+		})
+	}
+}
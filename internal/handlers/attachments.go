@@ -0,0 +1,381 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/v1-nce/threadtalk-backend/internal/models"
+	"github.com/v1-nce/threadtalk-backend/internal/storage"
+)
+
+const (
+	defaultAttachmentMaxBytes = 5 << 20
+	maxAttachmentsPerTarget   = 10
+	maxFilenameLength         = 255
+)
+
+// Types are sniffed from the file contents, never taken from the client.
+var allowedAttachmentTypes = map[string]bool{
+	"image/png":       true,
+	"image/jpeg":      true,
+	"image/gif":       true,
+	"image/webp":      true,
+	"application/pdf": true,
+	"text/plain":      true,
+}
+
+var (
+	attachmentLimitOnce sync.Once
+	attachmentLimit     int64
+)
+
+// attachmentMaxBytes reads ATTACHMENT_MAX_BYTES once.
+func attachmentMaxBytes() int64 {
+	attachmentLimitOnce.Do(func() {
+		attachmentLimit = defaultAttachmentMaxBytes
+		if s := os.Getenv("ATTACHMENT_MAX_BYTES"); s != "" {
+			if v, err := strconv.ParseInt(s, 10, 64); err == nil && v > 0 {
+				attachmentLimit = v
+			} else {
+				log.Printf("WARN: Ignoring invalid ATTACHMENT_MAX_BYTES %q", s)
+			}
+		}
+	})
+	return attachmentLimit
+}
+
+type AttachmentHandler struct {
+	DB    *sql.DB
+	Store storage.BlobStore
+}
+
+func attachmentURL(id int64) string {
+	return "/attachments/" + strconv.FormatInt(id, 10)
+}
+
+func sanitizeFilename(name string) string {
+	name = path.Base(strings.ReplaceAll(name, "\\", "/"))
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || r == '"' {
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.TrimSpace(name)
+	for len(name) > maxFilenameLength {
+		_, size := utf8.DecodeLastRuneInString(name)
+		name = name[:len(name)-size]
+	}
+	if name == "" || name == "." || name == "/" {
+		return "attachment"
+	}
+	return name
+}
+
+func (h *AttachmentHandler) UploadPostAttachment(c *gin.Context) {
+	h.upload(c, "posts", "post_id", "post")
+}
+
+func (h *AttachmentHandler) UploadCommentAttachment(c *gin.Context) {
+	h.upload(c, "comments", "comment_id", "comment")
+}
+
+// upload stores a multipart "file" field and attaches it to a post or comment
+// owned by the caller. Identical contents are stored once. table and column
+// must be trusted constants; they are interpolated into the query.
+func (h *AttachmentHandler) upload(c *gin.Context, table, column, noun string) {
+	targetID, err := strconv.ParseInt(c.Param(column), 10, 64)
+	if err != nil || targetID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid %s ID", noun)})
+		return
+	}
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	maxBytes := attachmentMaxBytes()
+	tooLarge := fmt.Sprintf("File exceeds the %d byte limit", maxBytes)
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBytes+1<<20)
+	file, header, err := c.Request.FormFile("file")
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": tooLarge})
+		} else {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "A multipart file field named \"file\" is required"})
+		}
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, maxBytes+1))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read upload"})
+		return
+	}
+	if int64(len(data)) > maxBytes {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": tooLarge})
+		return
+	}
+	if len(data) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "File is empty"})
+		return
+	}
+	contentType := http.DetectContentType(data)
+	if mediaType, _, err := mime.ParseMediaType(contentType); err != nil || !allowedAttachmentTypes[mediaType] {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "File type is not allowed"})
+		return
+	}
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	attachment := models.Attachment{
+		Filename:    sanitizeFilename(header.Filename),
+		ContentType: contentType,
+		Size:        int64(len(data)),
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+	var ownerID int64
+	var count int
+	err = h.DB.QueryRowContext(ctx, fmt.Sprintf(`
+		SELECT t.user_id, (SELECT COUNT(*) FROM attachments a WHERE a.%s = t.id)
+		FROM %s t
+		WHERE t.id = $1 AND t.deleted_at IS NULL`, column, table), targetID).Scan(&ownerID, &count)
+	if err == nil && ownerID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("You can only attach files to your own %ss", noun)})
+		return
+	}
+	if err == nil && count >= maxAttachmentsPerTarget {
+		err = errTooManyAttachments
+	}
+	if err == nil {
+		err = h.attach(ctx, table, column, targetID, userID, hash, data, &attachment)
+	}
+	if errors.Is(err, errTooManyAttachments) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("A %s can have at most %d attachments", noun, maxAttachmentsPerTarget)})
+		return
+	}
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			log.Printf("WARN: Request timeout uploading attachment to %s %d by user %d", noun, targetID, userID)
+			c.JSON(http.StatusRequestTimeout, gin.H{"error": "Request timeout"})
+		} else if err == sql.ErrNoRows || isPgError(err, "23503") {
+			c.JSON(http.StatusNotFound, gin.H{"error": strings.ToUpper(noun[:1]) + noun[1:] + " not found"})
+		} else {
+			log.Printf("ERROR: Failed to upload attachment to %s %d by user %d: %v", noun, targetID, userID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to upload attachment"})
+		}
+		return
+	}
+	attachment.URL = attachmentURL(attachment.ID)
+	c.JSON(http.StatusCreated, attachment)
+}
+
+var errTooManyAttachments = errors.New("too many attachments")
+
+// attach stores data under hash if it is new or its file is missing and
+// attaches it to the target. The blob row stays locked until the attachment
+// is committed, so the retention worker cannot collect it in between; the
+// target row is locked while its attachments are counted so concurrent
+// uploads cannot pass the limit together.
+func (h *AttachmentHandler) attach(ctx context.Context, table, column string, targetID, userID int64, hash string, data []byte, a *models.Attachment) error {
+	tx, err := h.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	var created bool
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO blobs (sha256, content_type, size) VALUES ($1, $2, $3)
+		ON CONFLICT (sha256) DO UPDATE SET content_type = blobs.content_type
+		RETURNING xmax = 0`, hash, a.ContentType, a.Size).Scan(&created)
+	if err != nil {
+		return err
+	}
+	missing := created
+	if !created {
+		// A failed retention run can leave a blob row whose file is already
+		// gone; store the file again rather than attach to nothing.
+		if missing, err = h.blobMissing(ctx, hash); err != nil {
+			return err
+		}
+	}
+	if missing {
+		if err := h.Store.Put(ctx, storage.BlobKey(hash), data, a.ContentType); err != nil {
+			return err
+		}
+	}
+	if err := h.insertAttachment(ctx, tx, table, column, targetID, userID, hash, a); err != nil {
+		if created {
+			// The blob row is still locked, so no other upload relies on the
+			// file yet.
+			if delErr := h.Store.Delete(context.Background(), storage.BlobKey(hash)); delErr != nil {
+				log.Printf("WARN: Failed to remove unused blob %s: %v", hash, delErr)
+			}
+		}
+		return err
+	}
+	return tx.Commit()
+}
+
+func (h *AttachmentHandler) blobMissing(ctx context.Context, hash string) (bool, error) {
+	r, err := h.Store.Get(ctx, storage.BlobKey(hash))
+	if errors.Is(err, storage.ErrNotFound) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	r.Close()
+	return false, nil
+}
+
+func (h *AttachmentHandler) insertAttachment(ctx context.Context, tx *sql.Tx, table, column string, targetID, userID int64, hash string, a *models.Attachment) error {
+	var locked int64
+	err := tx.QueryRowContext(ctx, fmt.Sprintf(`SELECT id FROM %s WHERE id = $1 AND deleted_at IS NULL FOR NO KEY UPDATE`, table), targetID).Scan(&locked)
+	if err != nil {
+		return err
+	}
+	var count int
+	if err := tx.QueryRowContext(ctx, fmt.Sprintf(`SELECT COUNT(*) FROM attachments WHERE %s = $1`, column), targetID).Scan(&count); err != nil {
+		return err
+	}
+	if count >= maxAttachmentsPerTarget {
+		return errTooManyAttachments
+	}
+	return tx.QueryRowContext(ctx, fmt.Sprintf(`
+		INSERT INTO attachments (blob_sha256, user_id, %s, filename) VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at`, column), hash, userID, targetID, a.Filename).Scan(&a.ID, &a.CreatedAt)
+}
+
+// GetAttachment streams an attachment. Files on deleted posts or comments, or
+// on comments of deleted posts, are no longer served.
+func (h *AttachmentHandler) GetAttachment(c *gin.Context) {
+	attachmentID, err := strconv.ParseInt(c.Param("attachment_id"), 10, 64)
+	if err != nil || attachmentID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid attachment ID"})
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+	var filename, contentType, hash string
+	var size int64
+	err = h.DB.QueryRowContext(ctx, `
+		SELECT a.filename, b.content_type, b.size, b.sha256
+		FROM attachments a
+		JOIN blobs b ON b.sha256 = a.blob_sha256
+		LEFT JOIN posts p ON p.id = a.post_id
+		LEFT JOIN comments c ON c.id = a.comment_id
+		LEFT JOIN posts cp ON cp.id = c.post_id
+		WHERE a.id = $1 AND p.deleted_at IS NULL AND c.deleted_at IS NULL AND cp.deleted_at IS NULL`, attachmentID).Scan(&filename, &contentType, &size, &hash)
+	var body io.ReadCloser
+	if err == nil {
+		body, err = h.Store.Get(ctx, storage.BlobKey(hash))
+	}
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			log.Printf("WARN: Request timeout fetching attachment %d", attachmentID)
+			c.JSON(http.StatusRequestTimeout, gin.H{"error": "Request timeout"})
+		} else if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Attachment not found"})
+		} else {
+			log.Printf("ERROR: Failed to fetch attachment %d: %v", attachmentID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch attachment"})
+		}
+		return
+	}
+	defer body.Close()
+	disposition := "attachment"
+	if strings.HasPrefix(contentType, "image/") {
+		disposition = "inline"
+	}
+	c.DataFromReader(http.StatusOK, size, contentType, body, map[string]string{
+		"Content-Disposition":    mime.FormatMediaType(disposition, map[string]string{"filename": filename}),
+		"X-Content-Type-Options": "nosniff",
+		"Cache-Control":          "public, max-age=86400",
+	})
+}
+
+// DeleteAttachment detaches a file. The blob may be shared with other
+// attachments; the retention worker removes it once nothing refers to it.
+func (h *AttachmentHandler) DeleteAttachment(c *gin.Context) {
+	attachmentID, err := strconv.ParseInt(c.Param("attachment_id"), 10, 64)
+	if err != nil || attachmentID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid attachment ID"})
+		return
+	}
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+	res, err := h.DB.ExecContext(ctx, `DELETE FROM attachments WHERE id = $1 AND user_id = $2`, attachmentID, userID)
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			log.Printf("WARN: Request timeout deleting attachment %d by user %d", attachmentID, userID)
+			c.JSON(http.StatusRequestTimeout, gin.H{"error": "Request timeout"})
+		} else {
+			log.Printf("ERROR: Failed to delete attachment %d by user %d: %v", attachmentID, userID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete attachment"})
+		}
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Attachment not found"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// threadAttachments lists attachments on a post and its comments. The post's
+// attachments are keyed under 0.
+func (h *ForumHandler) threadAttachments(ctx context.Context, postID int64) (map[int64][]models.Attachment, error) {
+	rows, err := h.DB.QueryContext(ctx, `
+		SELECT COALESCE(a.comment_id, 0), a.id, a.filename, b.content_type, b.size, a.created_at
+		FROM attachments a
+		JOIN blobs b ON b.sha256 = a.blob_sha256
+		WHERE a.post_id = $1 OR a.comment_id IN (SELECT id FROM comments WHERE post_id = $1)
+		ORDER BY a.id ASC`, postID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	byTarget := make(map[int64][]models.Attachment)
+	for rows.Next() {
+		var targetID int64
+		var a models.Attachment
+		if err := rows.Scan(&targetID, &a.ID, &a.Filename, &a.ContentType, &a.Size, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		a.URL = attachmentURL(a.ID)
+		byTarget[targetID] = append(byTarget[targetID], a)
+	}
+	return byTarget, rows.Err()
+}
+
+func attachCommentAttachments(comments []*models.Comment, byTarget map[int64][]models.Attachment) {
+	for _, c := range comments {
+		if !c.Deleted {
+			c.Attachments = byTarget[c.ID]
+		}
+		attachCommentAttachments(c.Children, byTarget)
+	}
+}
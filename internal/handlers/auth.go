@@ -37,8 +37,8 @@ func (h *AuthHandler) Signup(c *gin.Context) {
 	}
 	var user models.User
 	user.Username = input.Username
-	query := `INSERT INTO users (username, password_hash) VALUES ($1, $2) RETURNING id, created_at, updated_at`
-	if err := h.DB.QueryRowContext(c.Request.Context(), query, input.Username, string(hashedPwd)).Scan(&user.ID, &user.CreatedAt, &user.UpdatedAt); err != nil {
+	query := `INSERT INTO users (username, password_hash) VALUES ($1, $2) RETURNING id, role, created_at, updated_at`
+	if err := h.DB.QueryRowContext(c.Request.Context(), query, input.Username, string(hashedPwd)).Scan(&user.ID, &user.Role, &user.CreatedAt, &user.UpdatedAt); err != nil {
 		if isPgError(err, "23505") {
 			c.JSON(http.StatusConflict, gin.H{"error": "Username already exists"})
 			return
@@ -61,8 +61,8 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 	var user models.User
-	query := `SELECT id, username, password_hash, created_at, updated_at FROM users WHERE username = $1`
-	if err := h.DB.QueryRowContext(c.Request.Context(), query, input.Username).Scan(&user.ID, &user.Username, &user.Password, &user.CreatedAt, &user.UpdatedAt); err != nil {
+	query := `SELECT id, username, password_hash, role, is_bot, created_at, updated_at FROM users WHERE username = $1`
+	if err := h.DB.QueryRowContext(c.Request.Context(), query, input.Username).Scan(&user.ID, &user.Username, &user.Password, &user.Role, &user.IsBot, &user.CreatedAt, &user.UpdatedAt); err != nil {
 		if err == sql.ErrNoRows {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 			return
@@ -71,7 +71,10 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Authentication failed"})
 		return
 	}
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(input.Password)); err != nil {
+	// Bots have no password and authenticate with tokens from their owner.
+	// They get the same answer as a wrong password so the endpoint does not
+	// reveal which usernames are bots.
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(input.Password)); err != nil || user.IsBot {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 		return
 	}
@@ -104,8 +107,8 @@ func (h *AuthHandler) GetProfile(c *gin.Context) {
 		return
 	}
 	var user models.User
-	query := `SELECT id, username, created_at, updated_at FROM users WHERE id = $1`
-	if err := h.DB.QueryRowContext(c.Request.Context(), query, userID).Scan(&user.ID, &user.Username, &user.CreatedAt, &user.UpdatedAt); err != nil {
+	query := `SELECT id, username, role, is_bot, created_at, updated_at FROM users WHERE id = $1`
+	if err := h.DB.QueryRowContext(c.Request.Context(), query, userID).Scan(&user.ID, &user.Username, &user.Role, &user.IsBot, &user.CreatedAt, &user.UpdatedAt); err != nil {
 		if err == sql.ErrNoRows {
 			log.Printf("WARN: User ID %d not found in database", userID)
 			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
@@ -115,5 +118,16 @@ func (h *AuthHandler) GetProfile(c *gin.Context) {
 		}
 		return
 	}
+	// Accepted answers on deleted posts no longer count.
+	err := h.DB.QueryRowContext(c.Request.Context(), `
+		SELECT COUNT(*)
+		FROM posts p
+		JOIN comments c ON c.id = p.accepted_comment_id
+		WHERE c.user_id = $1 AND p.deleted_at IS NULL`, userID).Scan(&user.AcceptedAnswers)
+	if err != nil {
+		log.Printf("ERROR: Failed to count accepted answers for user ID %d: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve user information"})
+		return
+	}
 	c.JSON(http.StatusOK, user)
 }
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/v1-nce/threadtalk-backend/internal/models"
+)
+
+// Blocking a user stops them from generating notifications for the blocker.
+// Their content stays visible.
+
+func (h *AuthHandler) GetBlocks(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+	rows, err := h.DB.QueryContext(ctx, `
+		SELECT u.username, b.created_at
+		FROM user_blocks b
+		JOIN users u ON u.id = b.blocked_id
+		WHERE b.blocker_id = $1
+		ORDER BY b.created_at DESC`, userID)
+	if err != nil {
+		blockErrorResponse(c, ctx, err, "fetch blocked users", userID)
+		return
+	}
+	defer rows.Close()
+	blocked := make([]models.BlockedUser, 0)
+	for rows.Next() {
+		var b models.BlockedUser
+		if err := rows.Scan(&b.Username, &b.CreatedAt); err != nil {
+			blockErrorResponse(c, ctx, err, "fetch blocked users", userID)
+			return
+		}
+		blocked = append(blocked, b)
+	}
+	if err := rows.Err(); err != nil {
+		blockErrorResponse(c, ctx, err, "fetch blocked users", userID)
+		return
+	}
+	c.JSON(http.StatusOK, blocked)
+}
+
+func (h *AuthHandler) BlockUser(c *gin.Context) {
+	h.setBlocked(c, true)
+}
+
+func (h *AuthHandler) UnblockUser(c *gin.Context) {
+	h.setBlocked(c, false)
+}
+
+func (h *AuthHandler) setBlocked(c *gin.Context, block bool) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	username := c.Param("username")
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+	var targetID int64
+	if err := h.DB.QueryRowContext(ctx, `SELECT id FROM users WHERE username = $1`, username).Scan(&targetID); err != nil {
+		blockErrorResponse(c, ctx, err, "update blocked users", userID)
+		return
+	}
+	if targetID == userID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot block yourself"})
+		return
+	}
+	var err error
+	if block {
+		_, err = h.DB.ExecContext(ctx,
+			`INSERT INTO user_blocks (blocker_id, blocked_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`, userID, targetID)
+	} else {
+		_, err = h.DB.ExecContext(ctx,
+			`DELETE FROM user_blocks WHERE blocker_id = $1 AND blocked_id = $2`, userID, targetID)
+	}
+	if err != nil {
+		blockErrorResponse(c, ctx, err, "update blocked users", userID)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func blockErrorResponse(c *gin.Context, ctx context.Context, err error, action string, userID int64) {
+	switch {
+	case ctx.Err() == context.DeadlineExceeded:
+		log.Printf("WARN: Request timeout trying to %s for user %d", action, userID)
+		c.JSON(http.StatusRequestTimeout, gin.H{"error": "Request timeout"})
+	case err == sql.ErrNoRows:
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	default:
+		log.Printf("ERROR: Failed to %s for user %d: %v", action, userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to " + action})
+	}
+}
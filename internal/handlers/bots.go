@@ -0,0 +1,327 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/v1-nce/threadtalk-backend/internal/models"
+)
+
+// Bots are accounts that act through the API with tokens instead of a
+// password. Each is owned by the user who created it, and only that user can
+// see it, manage its tokens or disable it. Bots cannot own bots.
+
+const (
+	maxBotsPerOwner = 10
+	maxTokensPerBot = 5
+)
+
+// botPasswordHash is not a bcrypt hash, so password login could never succeed
+// for a bot even without the is_bot check in Login.
+const botPasswordHash = "!"
+
+var (
+	errTooManyBots   = fmt.Errorf("a user can have at most %d bots", maxBotsPerOwner)
+	errTooManyTokens = fmt.Errorf("a bot can have at most %d tokens", maxTokensPerBot)
+)
+
+func botErrorResponse(c *gin.Context, ctx context.Context, err error, action string, botID int64) {
+	switch {
+	case ctx.Err() == context.DeadlineExceeded:
+		log.Printf("WARN: Request timeout trying to %s for bot %d", action, botID)
+		c.JSON(http.StatusRequestTimeout, gin.H{"error": "Request timeout"})
+	case err == sql.ErrNoRows:
+		c.JSON(http.StatusNotFound, gin.H{"error": "Bot not found"})
+	case errors.Is(err, errTooManyBots):
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("A user can have at most %d bots", maxBotsPerOwner)})
+	case errors.Is(err, errTooManyTokens):
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("A bot can have at most %d tokens", maxTokensPerBot)})
+	default:
+		log.Printf("ERROR: Failed to %s for bot %d: %v", action, botID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to " + action})
+	}
+}
+
+// botOwner returns the caller's user ID, refusing bots themselves.
+func botOwner(c *gin.Context) (int64, bool) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return 0, false
+	}
+	if c.GetBool("isBot") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Bots cannot manage bots"})
+		return 0, false
+	}
+	return userID, true
+}
+
+func parseBotID(c *gin.Context) (int64, bool) {
+	botID, err := strconv.ParseInt(c.Param("bot_id"), 10, 64)
+	if err != nil || botID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid bot ID"})
+		return 0, false
+	}
+	return botID, true
+}
+
+// ownsBot reports sql.ErrNoRows unless botID is an enabled bot owned by
+// ownerID, so other users' bots look the same as missing ones.
+func (h *AuthHandler) ownsBot(ctx context.Context, ownerID, botID int64) error {
+	var exists bool
+	return h.DB.QueryRowContext(ctx,
+		`SELECT TRUE FROM users WHERE id = $1 AND owner_id = $2 AND is_bot AND disabled_at IS NULL`, botID, ownerID).Scan(&exists)
+}
+
+// GetBots lists the caller's bots.
+func (h *AuthHandler) GetBots(c *gin.Context) {
+	userID, ok := botOwner(c)
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+	rows, err := h.DB.QueryContext(ctx, `
+		SELECT id, username, created_at FROM users
+		WHERE owner_id = $1 AND is_bot AND disabled_at IS NULL
+		ORDER BY id`, userID)
+	if err != nil {
+		botErrorResponse(c, ctx, err, "fetch bots", 0)
+		return
+	}
+	defer rows.Close()
+	bots := make([]models.Bot, 0)
+	for rows.Next() {
+		var b models.Bot
+		if err := rows.Scan(&b.ID, &b.Username, &b.CreatedAt); err != nil {
+			botErrorResponse(c, ctx, err, "fetch bots", 0)
+			return
+		}
+		bots = append(bots, b)
+	}
+	if err := rows.Err(); err != nil {
+		botErrorResponse(c, ctx, err, "fetch bots", 0)
+		return
+	}
+	c.JSON(http.StatusOK, bots)
+}
+
+// CreateBot registers a bot account owned by the caller. It has no password
+// and cannot act until a token is created for it.
+func (h *AuthHandler) CreateBot(c *gin.Context) {
+	var input models.Bot
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+	userID, ok := botOwner(c)
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+	err := withTx(ctx, h.DB, func(tx *sql.Tx) error {
+		// Locking the owner makes concurrent creations wait, so they cannot
+		// all pass the cap.
+		var n int
+		err := tx.QueryRowContext(ctx, `SELECT id FROM users WHERE id = $1 FOR NO KEY UPDATE`, userID).Scan(new(int64))
+		if err == nil {
+			err = tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM users WHERE owner_id = $1 AND disabled_at IS NULL`, userID).Scan(&n)
+		}
+		if err != nil {
+			return err
+		}
+		if n >= maxBotsPerOwner {
+			return errTooManyBots
+		}
+		return tx.QueryRowContext(ctx, `
+			INSERT INTO users (username, password_hash, is_bot, owner_id) VALUES ($1, $2, TRUE, $3)
+			RETURNING id, created_at`,
+			input.Username, botPasswordHash, userID).Scan(&input.ID, &input.CreatedAt)
+	})
+	if err != nil {
+		if isPgError(err, "23505") {
+			c.JSON(http.StatusConflict, gin.H{"error": "Username already exists"})
+		} else {
+			botErrorResponse(c, ctx, err, "create bot", 0)
+		}
+		return
+	}
+	log.Printf("INFO: User %d created bot %d (%s)", userID, input.ID, input.Username)
+	c.JSON(http.StatusCreated, input)
+}
+
+// DeleteBot disables one of the caller's bots and revokes its tokens. The
+// account and what it posted are kept, so its username stays taken, but it
+// no longer counts toward the owner's bot limit.
+func (h *AuthHandler) DeleteBot(c *gin.Context) {
+	userID, ok := botOwner(c)
+	if !ok {
+		return
+	}
+	botID, ok := parseBotID(c)
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+	err := withTx(ctx, h.DB, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, `
+			UPDATE users SET disabled_at = CURRENT_TIMESTAMP
+			WHERE id = $1 AND owner_id = $2 AND is_bot AND disabled_at IS NULL`, botID, userID)
+		if err != nil {
+			return err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return sql.ErrNoRows
+		}
+		_, err = tx.ExecContext(ctx, `DELETE FROM bot_tokens WHERE bot_user_id = $1`, botID)
+		return err
+	})
+	if err != nil {
+		botErrorResponse(c, ctx, err, "delete bot", botID)
+		return
+	}
+	log.Printf("INFO: User %d disabled bot %d", userID, botID)
+	c.Status(http.StatusNoContent)
+}
+
+// GetBotTokens lists a bot's tokens without their values.
+func (h *AuthHandler) GetBotTokens(c *gin.Context) {
+	userID, ok := botOwner(c)
+	if !ok {
+		return
+	}
+	botID, ok := parseBotID(c)
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+	if err := h.ownsBot(ctx, userID, botID); err != nil {
+		botErrorResponse(c, ctx, err, "fetch bot tokens", botID)
+		return
+	}
+	rows, err := h.DB.QueryContext(ctx, `
+		SELECT id, name, last_used_at, created_at FROM bot_tokens
+		WHERE bot_user_id = $1
+		ORDER BY id`, botID)
+	if err != nil {
+		botErrorResponse(c, ctx, err, "fetch bot tokens", botID)
+		return
+	}
+	defer rows.Close()
+	tokens := make([]models.BotToken, 0)
+	for rows.Next() {
+		var t models.BotToken
+		if err := rows.Scan(&t.ID, &t.Name, &t.LastUsedAt, &t.CreatedAt); err != nil {
+			botErrorResponse(c, ctx, err, "fetch bot tokens", botID)
+			return
+		}
+		tokens = append(tokens, t)
+	}
+	if err := rows.Err(); err != nil {
+		botErrorResponse(c, ctx, err, "fetch bot tokens", botID)
+		return
+	}
+	c.JSON(http.StatusOK, tokens)
+}
+
+// CreateBotToken issues a token for one of the caller's bots. The token is
+// only returned here; it is stored as a hash.
+func (h *AuthHandler) CreateBotToken(c *gin.Context) {
+	var input models.BotToken
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+	userID, ok := botOwner(c)
+	if !ok {
+		return
+	}
+	botID, ok := parseBotID(c)
+	if !ok {
+		return
+	}
+	token, err := newWebhookSecret()
+	if err != nil {
+		log.Printf("ERROR: Failed to generate token for bot %d: %v", botID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create bot token"})
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+	err = withTx(ctx, h.DB, func(tx *sql.Tx) error {
+		// Locking the bot checks ownership and makes concurrent creations
+		// wait, so they cannot all pass the cap.
+		var n int
+		err := tx.QueryRowContext(ctx,
+			`SELECT id FROM users WHERE id = $1 AND owner_id = $2 AND is_bot AND disabled_at IS NULL FOR NO KEY UPDATE`, botID, userID).Scan(new(int64))
+		if err == nil {
+			err = tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM bot_tokens WHERE bot_user_id = $1`, botID).Scan(&n)
+		}
+		if err != nil {
+			return err
+		}
+		if n >= maxTokensPerBot {
+			return errTooManyTokens
+		}
+		return tx.QueryRowContext(ctx, `
+			INSERT INTO bot_tokens (bot_user_id, name, token_hash) VALUES ($1, $2, $3)
+			RETURNING id, created_at`,
+			botID, input.Name, hashToken(token)).Scan(&input.ID, &input.CreatedAt)
+	})
+	if err != nil {
+		botErrorResponse(c, ctx, err, "create bot token", botID)
+		return
+	}
+	input.Token = token
+	input.LastUsedAt = nil
+	log.Printf("INFO: User %d created token %d for bot %d", userID, input.ID, botID)
+	c.JSON(http.StatusCreated, input)
+}
+
+// DeleteBotToken revokes one of a bot's tokens immediately.
+func (h *AuthHandler) DeleteBotToken(c *gin.Context) {
+	userID, ok := botOwner(c)
+	if !ok {
+		return
+	}
+	botID, ok := parseBotID(c)
+	if !ok {
+		return
+	}
+	tokenID, err := strconv.ParseInt(c.Param("token_id"), 10, 64)
+	if err != nil || tokenID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid token ID"})
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+	res, err := h.DB.ExecContext(ctx, `
+		DELETE FROM bot_tokens t
+		USING users u
+		WHERE t.id = $1 AND t.bot_user_id = $2 AND u.id = t.bot_user_id AND u.owner_id = $3`,
+		tokenID, botID, userID)
+	if err == nil {
+		if n, _ := res.RowsAffected(); n == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Bot token not found"})
+			return
+		}
+	}
+	if err != nil {
+		botErrorResponse(c, ctx, err, "delete bot token", botID)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Requests that are refused before the database is reached need no DB.
+func TestDeleteBotRejectsBeforeQuerying(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h := &AuthHandler{}
+	tests := []struct {
+		name       string
+		isBot      bool
+		botID      string
+		wantStatus int
+	}{
+		{"bots cannot delete bots", true, "5", http.StatusForbidden},
+		{"invalid bot ID", false, "abc", http.StatusBadRequest},
+		{"zero bot ID", false, "0", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodDelete, "/api/bots/"+tt.botID, nil)
+			c.Params = gin.Params{{Key: "bot_id", Value: tt.botID}}
+			c.Set("userID", int64(1))
+			if tt.isBot {
+				c.Set("isBot", true)
+			}
+			h.DeleteBot(c)
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+		})
+	}
+}
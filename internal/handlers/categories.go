@@ -0,0 +1,282 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/v1-nce/threadtalk-backend/internal/models"
+)
+
+const maxCategoryDepth = 5
+
+var (
+	errCategoryCycle    = errors.New("category cannot be moved beneath itself")
+	errCategoryTooDeep  = fmt.Errorf("categories can be nested at most %d levels deep", maxCategoryDepth)
+	errCategoryNotFound = errors.New("parent category not found")
+)
+
+func (h *ForumHandler) GetCategories(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+	rows, err := h.DB.QueryContext(ctx, `
+		SELECT c.id, c.name, c.slug, c.description, c.parent_id, c.position, c.created_at,
+			(SELECT COUNT(*) FROM topics t WHERE t.category_id = c.id AND t.archived_at IS NULL)
+		FROM categories c
+		ORDER BY c.position ASC, c.name ASC`)
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			log.Printf("WARN: Request timeout fetching categories")
+			c.JSON(http.StatusRequestTimeout, gin.H{"error": "Request timeout"})
+		} else {
+			log.Printf("ERROR: Failed to fetch categories: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch categories"})
+		}
+		return
+	}
+	defer rows.Close()
+	var all []*models.Category
+	for rows.Next() {
+		cat := &models.Category{Children: []*models.Category{}}
+		if err := rows.Scan(&cat.ID, &cat.Name, &cat.Slug, &cat.Description, &cat.ParentID, &cat.Position, &cat.CreatedAt, &cat.TopicCount); err != nil {
+			log.Printf("ERROR: Failed to scan category row: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch categories"})
+			return
+		}
+		all = append(all, cat)
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("ERROR: Error iterating categories: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch categories"})
+		return
+	}
+	c.JSON(http.StatusOK, buildCategoryTree(all))
+}
+
+func buildCategoryTree(all []*models.Category) []*models.Category {
+	lookup := make(map[int64]*models.Category, len(all))
+	roots := make([]*models.Category, 0)
+	for _, cat := range all {
+		lookup[cat.ID] = cat
+	}
+	for _, cat := range all {
+		if cat.ParentID != nil {
+			if parent, exists := lookup[*cat.ParentID]; exists {
+				parent.Children = append(parent.Children, cat)
+				continue
+			}
+		}
+		roots = append(roots, cat)
+	}
+	var total func(cat *models.Category) int
+	total = func(cat *models.Category) int {
+		cat.TotalTopicCount = cat.TopicCount
+		for _, child := range cat.Children {
+			cat.TotalTopicCount += total(child)
+		}
+		return cat.TotalTopicCount
+	}
+	for _, root := range roots {
+		total(root)
+	}
+	return roots
+}
+
+// checkCategoryParent verifies that parentID exists, is not categoryID or one
+// of its descendants, and that categoryID with all of its descendants still
+// fits within maxCategoryDepth beneath it. categoryID is 0 for a new category.
+func checkCategoryParent(ctx context.Context, tx *sql.Tx, categoryID, parentID int64) error {
+	var selfCount, depth, height int
+	err := tx.QueryRowContext(ctx, `
+		WITH RECURSIVE ancestors AS (
+			SELECT id, parent_id, 1 AS depth FROM categories WHERE id = $1
+			UNION ALL
+			SELECT c.id, c.parent_id, a.depth + 1
+			FROM categories c
+			JOIN ancestors a ON c.id = a.parent_id
+			WHERE a.depth < 100
+		), subtree AS (
+			SELECT id, 0 AS height FROM categories WHERE id = $2
+			UNION ALL
+			SELECT c.id, s.height + 1
+			FROM categories c
+			JOIN subtree s ON c.parent_id = s.id
+			WHERE s.height < 100
+		)
+		SELECT (SELECT COUNT(*) FROM ancestors WHERE id = $2),
+			(SELECT COALESCE(MAX(depth), 0) FROM ancestors),
+			(SELECT COALESCE(MAX(height), 0) FROM subtree)`,
+		parentID, categoryID).Scan(&selfCount, &depth, &height)
+	if err != nil {
+		return err
+	}
+	if depth == 0 {
+		return errCategoryNotFound
+	}
+	if selfCount > 0 {
+		return errCategoryCycle
+	}
+	return checkCategoryNesting(depth, height)
+}
+
+// checkCategoryNesting fails when placing a category with height levels of
+// descendants under a parent at parentDepth (roots are at depth 1) would
+// nest anything deeper than maxCategoryDepth.
+func checkCategoryNesting(parentDepth, height int) error {
+	if parentDepth+1+height > maxCategoryDepth {
+		return errCategoryTooDeep
+	}
+	return nil
+}
+
+func (h *ForumHandler) CreateCategory(c *gin.Context) {
+	var input models.Category
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+	input.Name = strings.TrimSpace(input.Name)
+	input.Children = []*models.Category{}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+	err := h.withTx(ctx, func(tx *sql.Tx) error {
+		if input.ParentID != nil {
+			if _, err := tx.ExecContext(ctx, `LOCK TABLE categories IN SHARE ROW EXCLUSIVE MODE`); err != nil {
+				return err
+			}
+			if err := checkCategoryParent(ctx, tx, 0, *input.ParentID); err != nil {
+				return err
+			}
+		}
+		return insertWithUniqueSlug(slugify(input.Name), func(slug string) error {
+			return tx.QueryRowContext(ctx,
+				`INSERT INTO categories (name, slug, description, parent_id, position) VALUES ($1, $2, $3, $4, $5)
+				ON CONFLICT (slug) DO NOTHING RETURNING id, slug, created_at`,
+				input.Name, slug, input.Description, input.ParentID, input.Position).Scan(&input.ID, &input.Slug, &input.CreatedAt)
+		})
+	})
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			log.Printf("WARN: Request timeout creating category: %s", input.Name)
+			c.JSON(http.StatusRequestTimeout, gin.H{"error": "Request timeout"})
+		} else if errors.Is(err, errCategoryNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid parent category ID"})
+		} else if errors.Is(err, errCategoryTooDeep) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		} else {
+			log.Printf("ERROR: Failed to create category %s: %v", input.Name, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create category"})
+		}
+		return
+	}
+	c.JSON(http.StatusCreated, input)
+}
+
+func (h *ForumHandler) UpdateCategory(c *gin.Context) {
+	categoryID, err := strconv.ParseInt(c.Param("category_id"), 10, 64)
+	if err != nil || categoryID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid category ID"})
+		return
+	}
+	var input models.CategoryUpdate
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+	var sets []string
+	var args []interface{}
+	add := func(column string, value interface{}) {
+		args = append(args, value)
+		sets = append(sets, fmt.Sprintf("%s = $%d", column, len(args)))
+	}
+	if input.Name != nil {
+		add("name", strings.TrimSpace(*input.Name))
+	}
+	if input.Description != nil {
+		add("description", *input.Description)
+	}
+	if input.ParentID != nil {
+		if *input.ParentID == 0 {
+			sets = append(sets, "parent_id = NULL")
+		} else {
+			add("parent_id", *input.ParentID)
+		}
+	}
+	if input.Position != nil {
+		add("position", *input.Position)
+	}
+	if len(sets) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No fields to update"})
+		return
+	}
+	args = append(args, categoryID)
+	query := fmt.Sprintf(`UPDATE categories SET %s WHERE id = $%d RETURNING id, name, slug, description, parent_id, position, created_at`,
+		strings.Join(sets, ", "), len(args))
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+	cat := models.Category{Children: []*models.Category{}}
+	err = h.withTx(ctx, func(tx *sql.Tx) error {
+		if input.ParentID != nil && *input.ParentID != 0 {
+			if _, err := tx.ExecContext(ctx, `LOCK TABLE categories IN SHARE ROW EXCLUSIVE MODE`); err != nil {
+				return err
+			}
+			if err := checkCategoryParent(ctx, tx, categoryID, *input.ParentID); err != nil {
+				return err
+			}
+		}
+		return tx.QueryRowContext(ctx, query, args...).
+			Scan(&cat.ID, &cat.Name, &cat.Slug, &cat.Description, &cat.ParentID, &cat.Position, &cat.CreatedAt)
+	})
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			log.Printf("WARN: Request timeout updating category %d", categoryID)
+			c.JSON(http.StatusRequestTimeout, gin.H{"error": "Request timeout"})
+		} else if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Category not found"})
+		} else if errors.Is(err, errCategoryNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid parent category ID"})
+		} else if errors.Is(err, errCategoryCycle) || errors.Is(err, errCategoryTooDeep) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		} else {
+			log.Printf("ERROR: Failed to update category %d: %v", categoryID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update category"})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, cat)
+}
+
+func (h *ForumHandler) DeleteCategory(c *gin.Context) {
+	categoryID, err := strconv.ParseInt(c.Param("category_id"), 10, 64)
+	if err != nil || categoryID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid category ID"})
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+	res, err := h.DB.ExecContext(ctx, `DELETE FROM categories WHERE id = $1`, categoryID)
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			log.Printf("WARN: Request timeout deleting category %d", categoryID)
+			c.JSON(http.StatusRequestTimeout, gin.H{"error": "Request timeout"})
+		} else if isPgError(err, "23503") {
+			c.JSON(http.StatusConflict, gin.H{"error": "Category has subcategories"})
+		} else {
+			log.Printf("ERROR: Failed to delete category %d: %v", categoryID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete category"})
+		}
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Category not found"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
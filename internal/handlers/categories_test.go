@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/v1-nce/threadtalk-backend/internal/models"
+)
+
+func TestCheckCategoryNesting(t *testing.T) {
+	tests := []struct {
+		name        string
+		parentDepth int
+		height      int
+		wantErr     error
+	}{
+		{"leaf under root", 1, 0, nil},
+		{"leaf at the deepest level", maxCategoryDepth - 1, 0, nil},
+		{"leaf too deep", maxCategoryDepth, 0, errCategoryTooDeep},
+		{"subtree fits exactly", 2, maxCategoryDepth - 3, nil},
+		{"subtree one level too deep", 2, maxCategoryDepth - 2, errCategoryTooDeep},
+		{"tall subtree under root", 1, maxCategoryDepth - 1, errCategoryTooDeep},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := checkCategoryNesting(tt.parentDepth, tt.height); !errors.Is(err, tt.wantErr) {
+				t.Errorf("checkCategoryNesting(%d, %d) = %v, want %v", tt.parentDepth, tt.height, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestBuildCategoryTree(t *testing.T) {
+	id := func(n int64) *int64 { return &n }
+	cat := func(catID int64, parent *int64, topics int) *models.Category {
+		return &models.Category{ID: catID, ParentID: parent, TopicCount: topics, Children: []*models.Category{}}
+	}
+	all := []*models.Category{
+		cat(1, nil, 2),
+		cat(2, id(1), 3),
+		cat(3, id(2), 4),
+		cat(4, nil, 0),
+		// A parent missing from the list makes the category a root.
+		cat(5, id(99), 1),
+	}
+	roots := buildCategoryTree(all)
+	if len(roots) != 3 || roots[0].ID != 1 || roots[1].ID != 4 || roots[2].ID != 5 {
+		t.Fatalf("roots = %v, want categories 1, 4 and 5", roots)
+	}
+	if got := roots[0].Children[0].Children[0].ID; got != 3 {
+		t.Errorf("grandchild of 1 = %d, want 3", got)
+	}
+	for _, c := range []struct {
+		cat  *models.Category
+		want int
+	}{{roots[0], 9}, {roots[0].Children[0], 7}, {roots[1], 0}, {roots[2], 1}} {
+		if c.cat.TotalTopicCount != c.want {
+			t.Errorf("category %d total_topic_count = %d, want %d", c.cat.ID, c.cat.TotalTopicCount, c.want)
+		}
+	}
+}
@@ -0,0 +1,246 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/v1-nce/threadtalk-backend/internal/models"
+)
+
+const (
+	maxDraftsListed      = 100
+	maxPostContentLength = 600
+)
+
+// normalizeDraft checks the fields that make sense for the draft's kind. Drafts
+// may be incomplete unless they are scheduled, since the publisher has no one
+// to report validation errors to.
+func normalizeDraft(d *models.Draft) error {
+	tags, err := normalizeTags(d.Tags)
+	if err != nil {
+		return err
+	}
+	d.Tags = tags
+	switch d.Kind {
+	case models.DraftKindPost:
+		if d.PostID != nil || d.ParentID != nil {
+			return errors.New("post drafts cannot have a post_id or parent_id")
+		}
+		if utf8.RuneCountInString(d.Content) > maxPostContentLength {
+			return fmt.Errorf("post content can be at most %d characters", maxPostContentLength)
+		}
+	case models.DraftKindComment:
+		if d.TopicID != nil || d.Title != "" || len(d.Tags) > 0 {
+			return errors.New("comment drafts cannot have a title, topic_id or tags")
+		}
+		if d.ScheduledAt != nil {
+			return errors.New("only post drafts can be scheduled")
+		}
+	}
+	if d.ScheduledAt != nil {
+		if !d.ScheduledAt.After(time.Now()) {
+			return errors.New("scheduled_at must be in the future")
+		}
+		if d.TopicID == nil || utf8.RuneCountInString(strings.TrimSpace(d.Title)) < 5 {
+			return errors.New("scheduled drafts need a topic_id and a title of at least 5 characters")
+		}
+	}
+	d.LastError = ""
+	return nil
+}
+
+const draftSelect = `SELECT id, kind, title, content, topic_id, post_id, parent_id, tags, scheduled_at, last_error, created_at, updated_at
+	FROM drafts`
+
+func scanDraft(row rowScanner, d *models.Draft) error {
+	var tags string
+	if err := row.Scan(&d.ID, &d.Kind, &d.Title, &d.Content, &d.TopicID, &d.PostID, &d.ParentID, &tags, &d.ScheduledAt, &d.LastError, &d.CreatedAt, &d.UpdatedAt); err != nil {
+		return err
+	}
+	d.Tags = splitTags(tags)
+	return nil
+}
+
+var draftErrors = errorStatuses{
+	{sql.ErrNoRows, http.StatusNotFound, "Draft not found"},
+}
+
+func (h *ForumHandler) GetDrafts(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	query := draftSelect + ` WHERE user_id = $1`
+	args := []interface{}{userID}
+	if kind := c.Query("kind"); kind != "" {
+		if kind != models.DraftKindPost && kind != models.DraftKindComment {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid kind parameter"})
+			return
+		}
+		query += ` AND kind = $2`
+		args = append(args, kind)
+	}
+	query += fmt.Sprintf(` ORDER BY updated_at DESC LIMIT %d`, maxDraftsListed)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+	rows, err := h.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		respondError(c, ctx, err, "fetch drafts", "user", userID, draftErrors)
+		return
+	}
+	defer rows.Close()
+	drafts := make([]models.Draft, 0)
+	for rows.Next() {
+		var d models.Draft
+		if err := scanDraft(rows, &d); err != nil {
+			respondError(c, ctx, err, "fetch drafts", "user", userID, draftErrors)
+			return
+		}
+		drafts = append(drafts, d)
+	}
+	if err := rows.Err(); err != nil {
+		respondError(c, ctx, err, "fetch drafts", "user", userID, draftErrors)
+		return
+	}
+	c.JSON(http.StatusOK, drafts)
+}
+
+func (h *ForumHandler) GetDraft(c *gin.Context) {
+	draftID, err := strconv.ParseInt(c.Param("draft_id"), 10, 64)
+	if err != nil || draftID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid draft ID"})
+		return
+	}
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+	var d models.Draft
+	if err := scanDraft(h.DB.QueryRowContext(ctx, draftSelect+` WHERE id = $1 AND user_id = $2`, draftID, userID), &d); err != nil {
+		respondError(c, ctx, err, "fetch draft", "user", userID, draftErrors)
+		return
+	}
+	c.JSON(http.StatusOK, d)
+}
+
+func (h *ForumHandler) CreateDraft(c *gin.Context) {
+	var input models.Draft
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	if err := normalizeDraft(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+	err := h.DB.QueryRowContext(ctx, `
+		INSERT INTO drafts (user_id, kind, title, content, topic_id, post_id, parent_id, tags, scheduled_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id, created_at, updated_at`,
+		userID, input.Kind, input.Title, input.Content, input.TopicID, input.PostID, input.ParentID, strings.Join(input.Tags, ","), input.ScheduledAt).
+		Scan(&input.ID, &input.CreatedAt, &input.UpdatedAt)
+	if err != nil {
+		if isPgError(err, "23503") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid topic, post or parent comment ID"})
+			return
+		}
+		respondError(c, ctx, err, "create draft", "user", userID, draftErrors)
+		return
+	}
+	c.JSON(http.StatusCreated, input)
+}
+
+// UpdateDraft replaces a draft's contents. Clearing scheduled_at unschedules
+// it.
+func (h *ForumHandler) UpdateDraft(c *gin.Context) {
+	draftID, err := strconv.ParseInt(c.Param("draft_id"), 10, 64)
+	if err != nil || draftID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid draft ID"})
+		return
+	}
+	var input models.Draft
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	if err := normalizeDraft(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	input.ID = draftID
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+	err = h.DB.QueryRowContext(ctx, `
+		UPDATE drafts SET kind = $3, title = $4, content = $5, topic_id = $6, post_id = $7, parent_id = $8,
+			tags = $9, scheduled_at = $10, last_error = '', updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND user_id = $2
+		RETURNING created_at, updated_at`,
+		draftID, userID, input.Kind, input.Title, input.Content, input.TopicID, input.PostID, input.ParentID, strings.Join(input.Tags, ","), input.ScheduledAt).
+		Scan(&input.CreatedAt, &input.UpdatedAt)
+	if err != nil {
+		if isPgError(err, "23503") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid topic, post or parent comment ID"})
+			return
+		}
+		respondError(c, ctx, err, "update draft", "user", userID, draftErrors)
+		return
+	}
+	c.JSON(http.StatusOK, input)
+}
+
+func (h *ForumHandler) DeleteDraft(c *gin.Context) {
+	draftID, err := strconv.ParseInt(c.Param("draft_id"), 10, 64)
+	if err != nil || draftID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid draft ID"})
+		return
+	}
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+	res, err := h.DB.ExecContext(ctx, `DELETE FROM drafts WHERE id = $1 AND user_id = $2`, draftID, userID)
+	if err == nil {
+		if n, _ := res.RowsAffected(); n == 0 {
+			err = sql.ErrNoRows
+		}
+	}
+	if err != nil {
+		respondError(c, ctx, err, "delete draft", "user", userID, draftErrors)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// discardDraft removes the draft a post or comment was published from. It is
+// best effort: the content is already saved, so failures are only logged.
+func (h *ForumHandler) discardDraft(ctx context.Context, draftID *int64, userID int64) {
+	if draftID == nil {
+		return
+	}
+	if _, err := h.DB.ExecContext(ctx, `DELETE FROM drafts WHERE id = $1 AND user_id = $2`, *draftID, userID); err != nil {
+		log.Printf("WARN: Failed to discard draft %d for user %d: %v", *draftID, userID, err)
+	}
+}
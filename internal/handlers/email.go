@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/v1-nce/threadtalk-backend/internal/models"
+)
+
+// Email notifications are delivered by the mailer worker from the outbox
+// that InsertNotifications fills. Users without email settings, or whose
+// address is not verified yet, get no email.
+
+func emailErrorResponse(c *gin.Context, ctx context.Context, err error, action string, userID int64) {
+	switch {
+	case ctx.Err() == context.DeadlineExceeded:
+		log.Printf("WARN: Request timeout trying to %s for user %d", action, userID)
+		c.JSON(http.StatusRequestTimeout, gin.H{"error": "Request timeout"})
+	default:
+		log.Printf("ERROR: Failed to %s for user %d: %v", action, userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to " + action})
+	}
+}
+
+// GetEmailPreferences returns the caller's email address and frequency.
+// Users who never set an address get an empty address with frequency "off".
+func (h *AuthHandler) GetEmailPreferences(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+	prefs := models.EmailPreferences{Frequency: "off"}
+	err := h.DB.QueryRowContext(ctx,
+		`SELECT email, frequency, verified_at IS NOT NULL, updated_at FROM email_settings WHERE user_id = $1`, userID).
+		Scan(&prefs.Email, &prefs.Frequency, &prefs.Verified, &prefs.UpdatedAt)
+	if err != nil && err != sql.ErrNoRows {
+		emailErrorResponse(c, ctx, err, "fetch email preferences", userID)
+		return
+	}
+	c.JSON(http.StatusOK, prefs)
+}
+
+// PutEmailPreferences sets the caller's email address and how often they are
+// emailed: immediately, in a daily or weekly digest, or not at all. A new
+// address gets a verification email and nothing else until it is confirmed;
+// saving an unverified address again sends a fresh one.
+func (h *AuthHandler) PutEmailPreferences(c *gin.Context) {
+	var input models.EmailPreferences
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	unsubscribeToken, err := newHexToken()
+	var verificationToken string
+	if err == nil {
+		verificationToken, err = newHexToken()
+	}
+	if err != nil {
+		log.Printf("ERROR: Failed to generate email tokens for user %d: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update email preferences"})
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+	// Switching to a digest starts its period now rather than sending one
+	// straight away. A changed address is unverified again and gets a new
+	// unsubscribe token, so links mailed to the old address stop working.
+	var updatedAt time.Time
+	err = h.DB.QueryRowContext(ctx, `
+		INSERT INTO email_settings (user_id, email, frequency, unsubscribe_token, verification_token, last_digest_at)
+		VALUES ($1, $2, $3, $4, $5, CURRENT_TIMESTAMP)
+		ON CONFLICT (user_id) DO UPDATE SET
+			email = EXCLUDED.email,
+			frequency = EXCLUDED.frequency,
+			last_digest_at = CASE WHEN email_settings.frequency = EXCLUDED.frequency
+				THEN email_settings.last_digest_at ELSE CURRENT_TIMESTAMP END,
+			unsubscribe_token = CASE WHEN email_settings.email = EXCLUDED.email
+				THEN email_settings.unsubscribe_token ELSE EXCLUDED.unsubscribe_token END,
+			verified_at = CASE WHEN email_settings.email = EXCLUDED.email
+				THEN email_settings.verified_at END,
+			verification_token = CASE WHEN email_settings.email = EXCLUDED.email AND email_settings.verified_at IS NOT NULL
+				THEN NULL ELSE EXCLUDED.verification_token END,
+			verification_sent_at = CASE WHEN email_settings.email = EXCLUDED.email AND email_settings.verified_at IS NOT NULL
+				THEN email_settings.verification_sent_at END,
+			verification_attempts = 0,
+			verification_next_attempt_at = CURRENT_TIMESTAMP,
+			updated_at = CURRENT_TIMESTAMP
+		RETURNING verified_at IS NOT NULL, updated_at`,
+		userID, input.Email, input.Frequency, unsubscribeToken, verificationToken).Scan(&input.Verified, &updatedAt)
+	if err != nil {
+		emailErrorResponse(c, ctx, err, "update email preferences", userID)
+		return
+	}
+	input.UpdatedAt = &updatedAt
+	c.JSON(http.StatusOK, input)
+}
+
+// unsubscribePage confirms the unsubscribe with a form so that link scanners
+// following the emailed URL do not turn email off by themselves.
+const unsubscribePage = `<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>Unsubscribe from ThreadTalk</title></head>
+<body>
+<p>Stop all ThreadTalk notification emails?</p>
+<form method="post" action="?token=%s"><button type="submit">Unsubscribe</button></form>
+</body></html>
+`
+
+func newHexToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// validHexToken checks the shape of a 32-byte hex token before it is looked
+// up.
+func validHexToken(token string) bool {
+	if len(token) != 64 {
+		return false
+	}
+	_, err := hex.DecodeString(token)
+	return err == nil
+}
+
+// UnsubscribePage shows the confirmation page linked from every email.
+func (h *AuthHandler) UnsubscribePage(c *gin.Context) {
+	token := c.Query("token")
+	if !validHexToken(token) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid unsubscribe token"})
+		return
+	}
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(fmt.Sprintf(unsubscribePage, token)))
+}
+
+// Unsubscribe turns email off for the owner of the token. It serves both the
+// confirmation form and one-click unsubscribe from mail clients (RFC 8058),
+// and needs no login.
+func (h *AuthHandler) Unsubscribe(c *gin.Context) {
+	token := c.Query("token")
+	if !validHexToken(token) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid unsubscribe token"})
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+	res, err := h.DB.ExecContext(ctx, `
+		UPDATE email_settings SET frequency = 'off', updated_at = CURRENT_TIMESTAMP
+		WHERE unsubscribe_token = $1`, token)
+	if err == nil {
+		if n, _ := res.RowsAffected(); n == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Unsubscribe token not found"})
+			return
+		}
+	}
+	if err != nil {
+		emailErrorResponse(c, ctx, err, "unsubscribe", 0)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Unsubscribed from email notifications"})
+}
+
+// verificationTTL is how long a verification link stays valid after it is
+// mailed.
+const verificationTTL = 7 * 24 * time.Hour
+
+// VerifyEmail confirms the address the token was mailed to. Following the
+// link proves the mailbox received it, so a plain GET is enough and no login
+// is needed.
+func (h *AuthHandler) VerifyEmail(c *gin.Context) {
+	token := c.Query("token")
+	if !validHexToken(token) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid verification token"})
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+	res, err := h.DB.ExecContext(ctx, `
+		UPDATE email_settings SET verified_at = CURRENT_TIMESTAMP, verification_token = NULL, updated_at = CURRENT_TIMESTAMP
+		WHERE verification_token = $1 AND verification_sent_at > $2`, token, time.Now().Add(-verificationTTL))
+	if err == nil {
+		if n, _ := res.RowsAffected(); n == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Verification token not found or expired"})
+			return
+		}
+	}
+	if err != nil {
+		emailErrorResponse(c, ctx, err, "verify email", 0)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Email address verified"})
+}
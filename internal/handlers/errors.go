@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// errorStatus is the response for an error a handler expects. An empty
+// message returns the error's own text.
+type errorStatus struct {
+	err     error
+	status  int
+	message string
+}
+
+// errorStatuses lists the sentinel errors, including sql.ErrNoRows, that a
+// handler expects. The first entry that matches wins, so wrapped errors that
+// match several sentinels always get the same response.
+type errorStatuses []errorStatus
+
+// respondError writes the response for a failed request on noun id: 408 when
+// ctx ran out, the first matching entry of statuses, and otherwise a logged 500
+// "Failed to <action>".
+func respondError(c *gin.Context, ctx context.Context, err error, action, noun string, id int64, statuses errorStatuses) {
+	if ctx.Err() == context.DeadlineExceeded {
+		log.Printf("WARN: Request timeout trying to %s for %s %d", action, noun, id)
+		c.JSON(http.StatusRequestTimeout, gin.H{"error": "Request timeout"})
+		return
+	}
+	for _, reply := range statuses {
+		if errors.Is(err, reply.err) {
+			msg := reply.message
+			if msg == "" {
+				msg = err.Error()
+			}
+			c.JSON(reply.status, gin.H{"error": msg})
+			return
+		}
+	}
+	log.Printf("ERROR: Failed to %s for %s %d: %v", action, noun, id, err)
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to " + action})
+}
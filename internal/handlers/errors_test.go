@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRespondError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	expired, cancel := context.WithTimeout(context.Background(), -1)
+	defer cancel()
+	tests := []struct {
+		name       string
+		ctx        context.Context
+		err        error
+		wantStatus int
+		wantBody   string
+	}{
+		{"timeout wins", expired, sql.ErrNoRows, http.StatusRequestTimeout, "Request timeout"},
+		{"not found", context.Background(), sql.ErrNoRows, http.StatusNotFound, "Post not found"},
+		{"wrapped sentinel", context.Background(), fmt.Errorf("merge: %w", errPostLocked), http.StatusLocked, "This thread is locked"},
+		{"own message", context.Background(), errSamePost, http.StatusBadRequest, errSamePost.Error()},
+		{"first match wins", context.Background(), fmt.Errorf("%w: %w", errSamePost, errPostLocked), http.StatusLocked, "This thread is locked"},
+		{"unexpected", context.Background(), errors.New("boom"), http.StatusInternalServerError, "Failed to merge posts"},
+	}
+	statuses := errorStatuses{
+		{sql.ErrNoRows, http.StatusNotFound, "Post not found"},
+		{errPostLocked, http.StatusLocked, "This thread is locked"},
+		{errSamePost, http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			respondError(c, tt.ctx, tt.err, "merge posts", "post", 1, statuses)
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if !strings.Contains(w.Body.String(), tt.wantBody) {
+				t.Errorf("body = %s, want it to contain %q", w.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
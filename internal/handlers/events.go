@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/v1-nce/threadtalk-backend/internal/realtime"
+)
+
+// sseHeartbeat keeps idle streams alive through proxies that close quiet
+// connections.
+const sseHeartbeat = 25 * time.Second
+
+// publish broadcasts a change after it has been committed. Live updates are
+// best effort, so failures are only logged.
+func (h *ForumHandler) publish(ctx context.Context, ev realtime.Event, data interface{}) {
+	if err := realtime.Publish(ctx, h.DB, ev, data); err != nil {
+		log.Printf("WARN: Failed to publish %s event for post %d: %v", ev.Type, ev.PostID, err)
+	}
+}
+
+// PostEvents streams new and deleted comments on a post, and the post's own
+// deletion, as Server-Sent Events.
+func (h *ForumHandler) PostEvents(c *gin.Context) {
+	postID, err := strconv.ParseInt(c.Param("post_id"), 10, 64)
+	if err != nil || postID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid post ID"})
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+	var exists bool
+	err = h.DB.QueryRowContext(ctx,
+		`SELECT EXISTS (SELECT 1 FROM posts WHERE id = $1 AND deleted_at IS NULL AND redirect_post_id IS NULL)`, postID).Scan(&exists)
+	if err == nil && !exists {
+		err = sql.ErrNoRows
+	}
+	if err != nil {
+		streamErrorResponse(c, ctx, err, "post", postID)
+		return
+	}
+	h.stream(c, realtime.Stream{Kind: realtime.StreamPost, ID: postID})
+}
+
+// TopicEvents streams new and deleted posts in a topic as Server-Sent Events.
+func (h *ForumHandler) TopicEvents(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+	topicID, err := h.resolveTopicID(ctx, c.Param("topic_id"))
+	if err == nil {
+		var exists bool
+		err = h.DB.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM topics WHERE id = $1)`, topicID).Scan(&exists)
+		if err == nil && !exists {
+			err = sql.ErrNoRows
+		}
+	}
+	if err != nil {
+		streamErrorResponse(c, ctx, err, "topic", topicID)
+		return
+	}
+	h.stream(c, realtime.Stream{Kind: realtime.StreamTopic, ID: topicID})
+}
+
+func streamErrorResponse(c *gin.Context, ctx context.Context, err error, noun string, id int64) {
+	switch {
+	case ctx.Err() == context.DeadlineExceeded:
+		log.Printf("WARN: Request timeout opening event stream for %s %d", noun, id)
+		c.JSON(http.StatusRequestTimeout, gin.H{"error": "Request timeout"})
+	case err == sql.ErrNoRows:
+		if noun == "post" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Post not found"})
+		} else {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Topic not found"})
+		}
+	default:
+		log.Printf("ERROR: Failed to open event stream for %s %d: %v", noun, id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to open event stream"})
+	}
+}
+
+// stream writes hub events for st until the client disconnects. If the
+// subscriber falls behind or the hub loses its database connection, a
+// "resync" event is sent and the stream ends; clients should refetch and
+// reconnect.
+func (h *ForumHandler) stream(c *gin.Context, st realtime.Stream) {
+	sub, err := h.Hub.Subscribe(st)
+	if errors.Is(err, realtime.ErrTooManySubscribers) {
+		c.Header("Retry-After", "30")
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Too many live connections, try again later"})
+		return
+	}
+	defer sub.Close()
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	io.WriteString(c.Writer, ": connected\n\n")
+	c.Writer.Flush()
+	heartbeat := time.NewTicker(sseHeartbeat)
+	defer heartbeat.Stop()
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case <-heartbeat.C:
+			io.WriteString(w, ": ping\n\n")
+			return true
+		case ev, ok := <-sub.C:
+			if !ok {
+				c.SSEvent("resync", gin.H{})
+				return false
+			}
+			if !ev.Ephemeral() {
+				c.SSEvent(ev.Type, ev)
+			}
+			return true
+		}
+	})
+}
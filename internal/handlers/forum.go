@@ -14,11 +14,19 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/v1-nce/threadtalk-backend/internal/db"
+	"github.com/v1-nce/threadtalk-backend/internal/markdown"
 	"github.com/v1-nce/threadtalk-backend/internal/models"
+	"github.com/v1-nce/threadtalk-backend/internal/realtime"
+	"github.com/v1-nce/threadtalk-backend/internal/search"
 )
 
 type ForumHandler struct {
-	DB *sql.DB
+	DB  *sql.DB
+	Hub *realtime.Hub
+
+	hookMu       sync.Mutex
+	hookLimiters map[int64]*hookLimiter
 }
 
 func isPgError(err error, code string) bool {
@@ -29,21 +37,46 @@ func isPgError(err error, code string) bool {
 	return strings.Contains(err.Error(), code)
 }
 
+func (h *ForumHandler) withTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	return withTx(ctx, h.DB, fn)
+}
+
+func withTx(ctx context.Context, db *sql.DB, fn func(tx *sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
 func (h *ForumHandler) CreateTopic(c *gin.Context) {
 	var input models.Topic
 	if err := c.ShouldBindJSON(&input); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
 		return
 	}
+	if input.Kind == "" {
+		input.Kind = models.TopicKindDiscussion
+	}
 	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
 	defer cancel()
-	query := `INSERT INTO topics (name, description) VALUES ($1, $2) RETURNING id, created_at`
-	if err := h.DB.QueryRowContext(ctx, query, input.Name, input.Description).Scan(&input.ID, &input.CreatedAt); err != nil {
+	query := `INSERT INTO topics (name, slug, description, kind, category_id, position) VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (slug) DO NOTHING RETURNING id, slug, created_at`
+	err := insertWithUniqueSlug(slugify(input.Name), func(slug string) error {
+		return h.DB.QueryRowContext(ctx, query, input.Name, slug, input.Description, input.Kind, input.CategoryID, input.Position).Scan(&input.ID, &input.Slug, &input.CreatedAt)
+	})
+	if err != nil {
 		if ctx.Err() == context.DeadlineExceeded {
 			log.Printf("WARN: Request timeout creating topic: %s", input.Name)
 			c.JSON(http.StatusRequestTimeout, gin.H{"error": "Request timeout"})
 		} else if isPgError(err, "23505") {
 			c.JSON(http.StatusConflict, gin.H{"error": "Topic name already exists"})
+		} else if isPgError(err, "23503") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid category ID"})
 		} else {
 			log.Printf("ERROR: Failed to create topic %s: %v", input.Name, err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create topic"})
@@ -70,21 +103,57 @@ func (h *ForumHandler) CreatePost(c *gin.Context) {
 		return
 	}
 	input.UserID = userID
+	input.IsBot = c.GetBool("isBot")
+	tags, err := normalizeTags(input.Tags)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	input.Tags = tags
+	if input.Poll != nil {
+		if err := normalizePoll(input.Poll); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
 	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
 	defer cancel()
-	query := `INSERT INTO posts (title, content, user_id, topic_id) VALUES ($1, $2, $3, $4) RETURNING id, created_at`
-	if err := h.DB.QueryRowContext(ctx, query, input.Title, input.Content, input.UserID, input.TopicID).Scan(&input.ID, &input.CreatedAt); err != nil {
+	err = h.withTx(ctx, func(tx *sql.Tx) error {
+		if err := checkWritableTopic(ctx, tx, input.TopicID); err != nil {
+			return err
+		}
+		query := `INSERT INTO posts (title, content, user_id, topic_id) VALUES ($1, $2, $3, $4) RETURNING id, created_at`
+		if err := tx.QueryRowContext(ctx, query, input.Title, input.Content, input.UserID, input.TopicID).Scan(&input.ID, &input.CreatedAt); err != nil {
+			return err
+		}
+		if err := db.InsertPostTags(ctx, tx, input.ID, input.Tags); err != nil {
+			return err
+		}
+		if input.Poll != nil {
+			if err := insertPoll(ctx, tx, input.ID, input.Poll); err != nil {
+				return err
+			}
+		}
+		return db.RecordNewPost(ctx, tx, input.ID, input.TopicID, input.UserID, input.Content)
+	})
+	if err != nil {
 		if ctx.Err() == context.DeadlineExceeded {
 			log.Printf("WARN: Request timeout creating post in topic %d by user %d", input.TopicID, input.UserID)
 			c.JSON(http.StatusRequestTimeout, gin.H{"error": "Request timeout"})
-		} else if isPgError(err, "23503") {
+		} else if errors.Is(err, errTopicNotFound) || isPgError(err, "23503") {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid topic ID"})
+		} else if errors.Is(err, errTopicArchived) {
+			c.JSON(http.StatusLocked, gin.H{"error": "This topic is archived"})
 		} else {
 			log.Printf("ERROR: Failed to create post in topic %d by user %d: %v", input.TopicID, input.UserID, err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create post"})
 		}
 		return
 	}
+	h.discardDraft(ctx, input.DraftID, userID)
+	input.DraftID = nil
+	input.ContentHTML = markdown.Render(input.Content)
+	h.publish(ctx, realtime.Event{Type: realtime.EventPostCreated, TopicID: input.TopicID, PostID: input.ID}, input)
 	c.JSON(http.StatusCreated, input)
 }
 
@@ -105,13 +174,54 @@ func (h *ForumHandler) CreateComment(c *gin.Context) {
 		return
 	}
 	input.UserID = userID
+	input.IsBot = c.GetBool("isBot")
 	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
 	defer cancel()
-	query := `INSERT INTO comments (content, user_id, post_id, parent_id) VALUES ($1, $2, $3, $4) RETURNING id, created_at`
-	if err := h.DB.QueryRowContext(ctx, query, input.Content, input.UserID, input.PostID, input.ParentID).Scan(&input.ID, &input.CreatedAt); err != nil {
+	err := h.withTx(ctx, func(tx *sql.Tx) error {
+		// Locking the post (as the comment counter trigger will anyway) and
+		// sharing the topic holds off a concurrent lock or archive until the
+		// comment is in.
+		var locked, archived bool
+		err := tx.QueryRowContext(ctx, `
+			SELECT p.locked_at IS NOT NULL, t.archived_at IS NOT NULL
+			FROM posts p
+			JOIN topics t ON t.id = p.topic_id
+			WHERE p.id = $1
+			FOR NO KEY UPDATE OF p FOR SHARE OF t`, input.PostID).Scan(&locked, &archived)
+		if err == sql.ErrNoRows {
+			return errPostNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if archived {
+			return errTopicArchived
+		}
+		if locked {
+			role, err := userRole(ctx, h.DB, userID)
+			if err != nil {
+				return fmt.Errorf("load role: %w", err)
+			}
+			if !models.IsModeratorRole(role) {
+				return errPostLocked
+			}
+		}
+		query := `INSERT INTO comments (content, user_id, post_id, parent_id) VALUES ($1, $2, $3, $4) RETURNING id, created_at`
+		if err := tx.QueryRowContext(ctx, query, input.Content, input.UserID, input.PostID, input.ParentID).Scan(&input.ID, &input.CreatedAt); err != nil {
+			return err
+		}
+		return db.RecordNewComment(ctx, tx, input.ID, input.PostID, input.ParentID, input.UserID, input.Content)
+	})
+	if err != nil {
 		if ctx.Err() == context.DeadlineExceeded {
 			log.Printf("WARN: Request timeout creating comment on post %d by user %d", input.PostID, input.UserID)
 			c.JSON(http.StatusRequestTimeout, gin.H{"error": "Request timeout"})
+		} else if errors.Is(err, errPostNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid post ID"})
+		} else if errors.Is(err, errTopicArchived) {
+			c.JSON(http.StatusLocked, gin.H{"error": "This topic is archived"})
+		} else if errors.Is(err, errPostLocked) {
+			c.JSON(http.StatusLocked, gin.H{"error": "This thread is locked"})
 		} else if isPgError(err, "23503") {
 			if input.ParentID != nil {
 				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid post ID or parent comment ID"})
@@ -124,15 +234,37 @@ func (h *ForumHandler) CreateComment(c *gin.Context) {
 		}
 		return
 	}
+	h.discardDraft(ctx, input.DraftID, userID)
+	input.DraftID = nil
+	input.ContentHTML = markdown.Render(input.Content)
 	input.Children = []*models.Comment{}
+	h.publish(ctx, realtime.Event{Type: realtime.EventCommentCreated, PostID: input.PostID, CommentID: input.ID}, input)
 	c.JSON(http.StatusCreated, input)
 }
 
 func (h *ForumHandler) GetTopics(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
 	defer cancel()
-	query := `SELECT id, name, description, created_at FROM topics ORDER BY name ASC`
-	rows, err := h.DB.QueryContext(ctx, query)
+	query := topicSelect + ` WHERE TRUE`
+	var args []interface{}
+	if c.Query("include_archived") != "true" {
+		query += ` AND t.archived_at IS NULL`
+	}
+	if categoryStr := c.Query("category_id"); categoryStr != "" {
+		categoryID, err := strconv.ParseInt(categoryStr, 10, 64)
+		if err != nil || categoryID < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid category_id parameter"})
+			return
+		}
+		if categoryID == 0 {
+			query += ` AND t.category_id IS NULL`
+		} else {
+			args = append(args, categoryID)
+			query += fmt.Sprintf(` AND t.category_id = $%d`, len(args))
+		}
+	}
+	query += ` ORDER BY t.category_id ASC NULLS FIRST, t.position ASC, t.name ASC`
+	rows, err := h.DB.QueryContext(ctx, query, args...)
 	if err != nil {
 		if ctx.Err() == context.DeadlineExceeded {
 			log.Printf("WARN: Request timeout fetching topics")
@@ -147,7 +279,7 @@ func (h *ForumHandler) GetTopics(c *gin.Context) {
 	topics := make([]models.Topic, 0)
 	for rows.Next() {
 		var t models.Topic
-		if err := rows.Scan(&t.ID, &t.Name, &t.Description, &t.CreatedAt); err != nil {
+		if err := scanTopic(rows, &t); err != nil {
 			log.Printf("ERROR: Failed to scan topic row: %v", err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch topics"})
 			return
@@ -163,54 +295,40 @@ func (h *ForumHandler) GetTopics(c *gin.Context) {
 }
 
 func (h *ForumHandler) GetPosts(c *gin.Context) {
-	topicIDStr := c.Param("topic_id")
-	topicID, err := strconv.ParseInt(topicIDStr, 10, 64)
-	if err != nil || topicID <= 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid topic ID"})
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+	topicID, err := h.resolveTopicID(ctx, c.Param("topic_id"))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid topic ID"})
+		} else {
+			log.Printf("ERROR: Failed to resolve topic %s: %v", c.Param("topic_id"), err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch posts"})
+		}
 		return
 	}
-	limit := 20
-	cursorStr := c.Query("cursor")
-	search := c.Query("search")
-	var cursor int64
-	if cursorStr != "" {
-		var parseErr error
-		cursor, parseErr = strconv.ParseInt(cursorStr, 10, 64)
-		if parseErr != nil || cursor <= 0 {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor parameter"})
-			return
-		}
+	cursor, ok := parseCursor(c)
+	if !ok {
+		return
 	}
-	query := `SELECT p.id,
-		CASE WHEN p.deleted_at IS NULL THEN p.title ELSE '[deleted]' END,
-		CASE WHEN p.deleted_at IS NULL THEN p.content ELSE '[deleted]' END,
-		CASE WHEN p.deleted_at IS NULL THEN p.user_id ELSE 0 END,
-		p.created_at,
-		CASE WHEN p.deleted_at IS NULL THEN u.username ELSE '[deleted]' END,
-		(SELECT COUNT(*) FROM comments WHERE post_id = p.id)
-	FROM posts p
-	JOIN users u ON p.user_id = u.id
-	WHERE p.topic_id = $1`
-	args := []interface{}{topicID}
-	argPos := 2
-	if search != "" {
-		if len(search) > 200 {
-			search = search[:200]
-		}
-		query += fmt.Sprintf(` AND (p.title ILIKE $%d OR p.content ILIKE $%d)`, argPos, argPos)
-		args = append(args, "%"+search+"%")
-		argPos++
+	// search is plain text here; the query language is only on /search.
+	q := search.Literal(c.Query("search"))
+	filters, filterArgs, err := tagFilter(c, 2)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
-	if cursor > 0 {
-		query += fmt.Sprintf(` AND p.id < $%d`, argPos)
-		args = append(args, cursor)
-		argPos++
+	switch c.Query("status") {
+	case "":
+	case "answered":
+		filters += ` AND p.accepted_comment_id IS NOT NULL`
+	case "unanswered":
+		filters += ` AND p.accepted_comment_id IS NULL AND p.redirect_post_id IS NULL`
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status parameter"})
+		return
 	}
-	query += fmt.Sprintf(` ORDER BY p.id DESC LIMIT $%d`, argPos)
-	args = append(args, limit+1)
-	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
-	defer cancel()
-	rows, err := h.DB.QueryContext(ctx, query, args...)
+	posts, nextCursor, err := h.fetchPostPage(ctx, `p.topic_id = $1`+filters, append([]interface{}{topicID}, filterArgs...), q, cursor, true)
 	if err != nil {
 		if ctx.Err() == context.DeadlineExceeded {
 			log.Printf("WARN: Request timeout fetching posts for topic %d", topicID)
@@ -221,31 +339,213 @@ func (h *ForumHandler) GetPosts(c *gin.Context) {
 		}
 		return
 	}
+	c.JSON(http.StatusOK, gin.H{"data": posts, "next_cursor": nextCursor})
+}
+
+func (h *ForumHandler) SearchPosts(c *gin.Context) {
+	raw := strings.TrimSpace(c.Query("q"))
+	if raw == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Search query is required"})
+		return
+	}
+	cursor, ok := parseCursor(c)
+	if !ok {
+		return
+	}
+	q, err := search.Parse(raw)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+	posts, nextCursor, err := h.fetchPostPage(ctx, `TRUE`, nil, q, cursor, false)
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			log.Printf("WARN: Request timeout searching posts")
+			c.JSON(http.StatusRequestTimeout, gin.H{"error": "Request timeout"})
+		} else {
+			log.Printf("ERROR: Failed to search posts: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search posts"})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": posts, "next_cursor": nextCursor})
+}
+
+func parseCursor(c *gin.Context) (int64, bool) {
+	cursorStr := c.Query("cursor")
+	if cursorStr == "" {
+		return 0, true
+	}
+	cursor, err := strconv.ParseInt(cursorStr, 10, 64)
+	if err != nil || cursor <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor parameter"})
+		return 0, false
+	}
+	return cursor, true
+}
+
+// buildPostSelect returns the shared post projection. Unless reveal is set,
+// deleted posts have their title, content and author masked and only carry
+// the deleted flag, not when they were deleted.
+func buildPostSelect(reveal bool) string {
+	mask := func(expr, placeholder string) string {
+		if reveal {
+			return expr
+		}
+		return fmt.Sprintf("CASE WHEN p.deleted_at IS NULL THEN %s ELSE %s END", expr, placeholder)
+	}
+	deletedAt := "NULL"
+	if reveal {
+		deletedAt = "p.deleted_at"
+	}
+	return `SELECT p.id,
+		` + mask("p.title", "'[deleted]'") + `,
+		` + mask("p.content", "'[deleted]'") + `,
+		` + mask("p.user_id", "0") + `,
+		p.topic_id,
+		p.created_at,
+		` + mask("u.username", "'[deleted]'") + `,
+		` + mask("u.is_bot", "FALSE") + `,
+		p.comment_count,
+		p.last_comment_at,
+		COALESCE((SELECT string_agg(t.name, ',' ORDER BY t.name) FROM post_tags pt JOIN tags t ON t.id = pt.tag_id WHERE pt.post_id = p.id), ''),
+		p.pinned_at,
+		p.locked_at,
+		p.redirect_post_id,
+		p.deleted_at IS NOT NULL,
+		` + deletedAt + `,
+		p.accepted_comment_id
+	FROM posts p
+	JOIN users u ON p.user_id = u.id`
+}
+
+var (
+	postSelect         = buildPostSelect(false)
+	revealedPostSelect = buildPostSelect(true)
+)
+
+func buildCommentSelect(reveal bool) string {
+	mask := func(expr, placeholder string) string {
+		if reveal {
+			return expr
+		}
+		return fmt.Sprintf("CASE WHEN c.deleted_at IS NULL THEN %s ELSE %s END", expr, placeholder)
+	}
+	deletedAt := "NULL"
+	if reveal {
+		deletedAt = "c.deleted_at"
+	}
+	return `SELECT c.id,
+		` + mask("c.content", "'[deleted]'") + `,
+		` + mask("c.user_id", "0") + `,
+		c.parent_id, c.created_at,
+		` + mask("u.username", "'[deleted]'") + `,
+		` + mask("u.is_bot", "FALSE") + `,
+		c.redirect_post_id,
+		c.deleted_at IS NOT NULL,
+		` + deletedAt + `
+	FROM comments c
+	JOIN users u ON c.user_id = u.id`
+}
+
+var (
+	commentSelect         = buildCommentSelect(false)
+	revealedCommentSelect = buildCommentSelect(true)
+)
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanPost(row rowScanner, p *models.Post) error {
+	var tags string
+	if err := row.Scan(&p.ID, &p.Title, &p.Content, &p.UserID, &p.TopicID, &p.CreatedAt, &p.Username, &p.IsBot, &p.CommentCount, &p.LastCommentAt, &tags, &p.PinnedAt, &p.LockedAt, &p.RedirectPostID, &p.Deleted, &p.DeletedAt, &p.AcceptedCommentID); err != nil {
+		return err
+	}
+	p.Tags = splitTags(tags)
+	p.ContentHTML = markdown.Render(p.Content)
+	return nil
+}
+
+func (h *ForumHandler) queryPosts(ctx context.Context, clause string, args []interface{}) ([]models.Post, error) {
+	rows, err := h.DB.QueryContext(ctx, postSelect+` WHERE `+clause, args...)
+	if err != nil {
+		return nil, err
+	}
 	defer rows.Close()
-	posts := make([]models.Post, 0, limit)
+	posts := make([]models.Post, 0)
 	for rows.Next() {
 		var p models.Post
-		if err := rows.Scan(&p.ID, &p.Title, &p.Content, &p.UserID, &p.CreatedAt, &p.Username, &p.CommentCount); err != nil {
-			log.Printf("ERROR: Failed to scan post row for topic %d: %v", topicID, err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch posts"})
-			return
+		if err := scanPost(rows, &p); err != nil {
+			return nil, fmt.Errorf("scan post: %w", err)
 		}
 		posts = append(posts, p)
 	}
 	if err := rows.Err(); err != nil {
-		log.Printf("ERROR: Error iterating posts for topic %d: %v", topicID, err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch posts"})
-		return
+		return nil, err
+	}
+	return posts, nil
+}
+
+// fetchPostPage returns one page of posts matching where, newest first. With
+// pinnedFirst, live pinned posts are listed ahead of the first page and left
+// out of the paginated results.
+func (h *ForumHandler) fetchPostPage(ctx context.Context, where string, args []interface{}, q *search.Query, cursor int64, pinnedFirst bool) ([]models.Post, string, error) {
+	limit := 20
+	argPos := len(args) + 1
+	if !q.Empty() {
+		cond, searchArgs := search.Compile(q, argPos)
+		where += ` AND p.deleted_at IS NULL` + cond
+		args = append(args, searchArgs...)
+		argPos += len(searchArgs)
+	}
+	posts := make([]models.Post, 0, limit)
+	if pinnedFirst {
+		if cursor == 0 {
+			pinned, err := h.queryPosts(ctx, where+` AND p.pinned_at IS NOT NULL AND p.deleted_at IS NULL ORDER BY p.pinned_at DESC`, args)
+			if err != nil {
+				return nil, "", fmt.Errorf("pinned posts: %w", err)
+			}
+			posts = append(posts, pinned...)
+		}
+		where += ` AND (p.pinned_at IS NULL OR p.deleted_at IS NOT NULL)`
+	}
+	if cursor > 0 {
+		where += fmt.Sprintf(` AND p.id < $%d`, argPos)
+		args = append(args, cursor)
+		argPos++
+	}
+	where += fmt.Sprintf(` ORDER BY p.id DESC LIMIT $%d`, argPos)
+	args = append(args, limit+1)
+	page, err := h.queryPosts(ctx, where, args)
+	if err != nil {
+		return nil, "", err
 	}
 	var nextCursor string
-	if len(posts) > limit {
-		nextCursor = strconv.FormatInt(posts[limit-1].ID, 10)
-		posts = posts[:limit]
+	if len(page) > limit {
+		nextCursor = strconv.FormatInt(page[limit-1].ID, 10)
+		page = page[:limit]
 	}
-	c.JSON(http.StatusOK, gin.H{"data": posts, "next_cursor": nextCursor})
+	return append(posts, page...), nextCursor, nil
 }
 
 func (h *ForumHandler) GetPostWithComments(c *gin.Context) {
+	h.getPostWithComments(c, false)
+}
+
+// GetPostWithDeletedContent is the moderator view of a thread: deleted posts
+// and comments are returned with their original content and authors.
+func (h *ForumHandler) GetPostWithDeletedContent(c *gin.Context) {
+	h.getPostWithComments(c, true)
+}
+
+func (h *ForumHandler) getPostWithComments(c *gin.Context, reveal bool) {
+	selectPost, selectComments := postSelect, commentSelect
+	if reveal {
+		selectPost, selectComments = revealedPostSelect, revealedCommentSelect
+	}
 	postIDStr := c.Param("post_id")
 	postID, err := strconv.ParseInt(postIDStr, 10, 64)
 	if err != nil || postID <= 0 {
@@ -256,23 +556,16 @@ func (h *ForumHandler) GetPostWithComments(c *gin.Context) {
 	defer cancel()
 	var post models.Post
 	var rootComments []*models.Comment
-	errs := make(chan error, 2)
+	var reactions map[int64][]models.Reaction
+	var poll *models.Poll
+	var attachments map[int64][]models.Attachment
+	viewerID := optionalUserID(c)
+	errs := make(chan error, 5)
 	var wg sync.WaitGroup
-	wg.Add(2)
+	wg.Add(5)
 	go func() {
 		defer wg.Done()
-		err := h.DB.QueryRowContext(ctx, `
-			SELECT p.id,
-				CASE WHEN p.deleted_at IS NULL THEN p.title ELSE '[deleted]' END,
-				CASE WHEN p.deleted_at IS NULL THEN p.content ELSE '[deleted]' END,
-				CASE WHEN p.deleted_at IS NULL THEN p.user_id ELSE 0 END,
-				p.created_at,
-				CASE WHEN p.deleted_at IS NULL THEN u.username ELSE '[deleted]' END,
-				(SELECT COUNT(*) FROM comments WHERE post_id = p.id)
-			FROM posts p
-			JOIN users u ON p.user_id = u.id
-			WHERE p.id = $1`, postID).
-			Scan(&post.ID, &post.Title, &post.Content, &post.UserID, &post.CreatedAt, &post.Username, &post.CommentCount)
+		err := scanPost(h.DB.QueryRowContext(ctx, selectPost+` WHERE p.id = $1`, postID), &post)
 		if err != nil {
 			if err == sql.ErrNoRows {
 				errs <- fmt.Errorf("post not found: %w", err)
@@ -283,13 +576,7 @@ func (h *ForumHandler) GetPostWithComments(c *gin.Context) {
 	}()
 	go func() {
 		defer wg.Done()
-		rows, err := h.DB.QueryContext(ctx, `
-			SELECT c.id,
-				CASE WHEN c.deleted_at IS NULL THEN c.content ELSE '[deleted]' END,
-				c.user_id, c.parent_id, c.created_at,
-				CASE WHEN c.deleted_at IS NULL THEN u.username ELSE '[deleted]' END
-			FROM comments c
-			JOIN users u ON c.user_id = u.id
+		rows, err := h.DB.QueryContext(ctx, selectComments+`
 			WHERE c.post_id = $1
 			ORDER BY c.created_at ASC`, postID)
 		if err != nil {
@@ -300,10 +587,11 @@ func (h *ForumHandler) GetPostWithComments(c *gin.Context) {
 		var allComments []*models.Comment
 		for rows.Next() {
 			c := &models.Comment{Children: []*models.Comment{}}
-			if err := rows.Scan(&c.ID, &c.Content, &c.UserID, &c.ParentID, &c.CreatedAt, &c.Username); err != nil {
+			if err := rows.Scan(&c.ID, &c.Content, &c.UserID, &c.ParentID, &c.CreatedAt, &c.Username, &c.IsBot, &c.RedirectPostID, &c.Deleted, &c.DeletedAt); err != nil {
 				errs <- fmt.Errorf("comment scan: %w", err)
 				return
 			}
+			c.ContentHTML = markdown.Render(c.Content)
 			allComments = append(allComments, c)
 		}
 		if rows.Err() != nil {
@@ -311,6 +599,30 @@ func (h *ForumHandler) GetPostWithComments(c *gin.Context) {
 			return
 		}
 		rootComments = buildCommentTree(allComments)
+		if !reveal {
+			rootComments = pruneDeletedComments(rootComments)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		var err error
+		if reactions, err = h.threadReactions(ctx, postID, viewerID); err != nil {
+			errs <- fmt.Errorf("reactions: %w", err)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		var err error
+		if poll, err = h.loadPoll(ctx, postID, viewerID); err != nil {
+			errs <- fmt.Errorf("poll: %w", err)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		var err error
+		if attachments, err = h.threadAttachments(ctx, postID); err != nil {
+			errs <- fmt.Errorf("attachments: %w", err)
+		}
 	}()
 	wg.Wait()
 	close(errs)
@@ -328,6 +640,16 @@ func (h *ForumHandler) GetPostWithComments(c *gin.Context) {
 			return
 		}
 	}
+	if !post.Deleted {
+		post.Reactions = reactions[0]
+		post.Poll = poll
+		post.Attachments = attachments[0]
+	}
+	if post.AcceptedCommentID != nil {
+		rootComments = acceptedAnswerFirst(rootComments, *post.AcceptedCommentID)
+	}
+	attachCommentReactions(rootComments, reactions)
+	attachCommentAttachments(rootComments, attachments)
 	c.JSON(http.StatusOK, gin.H{
 		"post":     post,
 		"comments": rootComments,
@@ -353,6 +675,30 @@ func buildCommentTree(all []*models.Comment) []*models.Comment {
 	return roots
 }
 
+// pruneDeletedComments drops deleted comments that have no live replies
+// beneath them and collapses chains of deleted comments into the topmost one.
+func pruneDeletedComments(comments []*models.Comment) []*models.Comment {
+	kept := comments[:0]
+	for _, c := range comments {
+		c.Children = pruneDeletedComments(c.Children)
+		if !c.Deleted {
+			kept = append(kept, c)
+			continue
+		}
+		if len(c.Children) == 0 {
+			continue
+		}
+		for len(c.Children) == 1 && c.Children[0].Deleted {
+			c.Children = c.Children[0].Children
+		}
+		for _, child := range c.Children {
+			child.ParentID = &c.ID
+		}
+		kept = append(kept, c)
+	}
+	return kept
+}
+
 func (h *ForumHandler) DeletePost(c *gin.Context) {
 	postID, err := strconv.ParseInt(c.Param("post_id"), 10, 64)
 	if err != nil || postID <= 0 {
@@ -371,10 +717,16 @@ func (h *ForumHandler) DeletePost(c *gin.Context) {
 	}
 	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
 	defer cancel()
-	var deletedID int64
-	err = h.DB.QueryRowContext(ctx,
-		`UPDATE posts SET deleted_at = CURRENT_TIMESTAMP WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL RETURNING id`,
-		postID, userID).Scan(&deletedID)
+	var deletedID, topicID int64
+	err = h.withTx(ctx, func(tx *sql.Tx) error {
+		err := tx.QueryRowContext(ctx,
+			`UPDATE posts SET deleted_at = CURRENT_TIMESTAMP WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL RETURNING id, topic_id`,
+			postID, userID).Scan(&deletedID, &topicID)
+		if err != nil {
+			return err
+		}
+		return db.EnqueueWebhooks(ctx, tx, db.WebhookPostDeleted, deletedID)
+	})
 	if err != nil {
 		if ctx.Err() == context.DeadlineExceeded {
 			log.Printf("Request timeout deleting post %d by user %d", postID, userID)
@@ -387,6 +739,7 @@ func (h *ForumHandler) DeletePost(c *gin.Context) {
 		}
 		return
 	}
+	h.publish(ctx, realtime.Event{Type: realtime.EventPostDeleted, TopicID: topicID, PostID: deletedID}, nil)
 	c.Status(http.StatusNoContent)
 }
 
@@ -408,10 +761,17 @@ func (h *ForumHandler) DeleteComment(c *gin.Context) {
 	}
 	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
 	defer cancel()
-	var deletedID int64
-	err = h.DB.QueryRowContext(ctx,
-		`UPDATE comments SET deleted_at = CURRENT_TIMESTAMP WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL RETURNING id`,
-		commentID, userID).Scan(&deletedID)
+	var deletedID, postID int64
+	err = h.withTx(ctx, func(tx *sql.Tx) error {
+		err := tx.QueryRowContext(ctx,
+			`UPDATE comments SET deleted_at = CURRENT_TIMESTAMP WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL RETURNING id, post_id`,
+			commentID, userID).Scan(&deletedID, &postID)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `UPDATE posts SET accepted_comment_id = NULL WHERE accepted_comment_id = $1`, deletedID)
+		return err
+	})
 	if err != nil {
 		if ctx.Err() == context.DeadlineExceeded {
 			log.Printf("Request timeout deleting comment %d by user %d", commentID, userID)
@@ -424,5 +784,6 @@ func (h *ForumHandler) DeleteComment(c *gin.Context) {
 		}
 		return
 	}
+	h.publish(ctx, realtime.Event{Type: realtime.EventCommentDeleted, PostID: postID, CommentID: deletedID}, nil)
 	c.Status(http.StatusNoContent)
 }
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/v1-nce/threadtalk-backend/internal/models"
+)
+
+// node builds a comment; a deleted one is marked in the rendered tree with *.
+func node(id int64, deleted bool, children ...*models.Comment) *models.Comment {
+	c := &models.Comment{ID: id, Deleted: deleted, Children: children}
+	for _, child := range children {
+		child.ParentID = &c.ID
+	}
+	return c
+}
+
+// renderTree writes comments as "1*(2,3)" and fails if a child's ParentID
+// does not point at the comment it is listed under.
+func renderTree(t *testing.T, comments []*models.Comment, parent *models.Comment) string {
+	t.Helper()
+	parts := make([]string, len(comments))
+	for i, c := range comments {
+		if parent != nil && (c.ParentID == nil || *c.ParentID != parent.ID) {
+			t.Errorf("comment %d has parent %v, want %d", c.ID, c.ParentID, parent.ID)
+		}
+		s := strconv.FormatInt(c.ID, 10)
+		if c.Deleted {
+			s += "*"
+		}
+		if len(c.Children) > 0 {
+			s += "(" + renderTree(t, c.Children, c) + ")"
+		}
+		parts[i] = s
+	}
+	return strings.Join(parts, ",")
+}
+
+func TestPruneDeletedComments(t *testing.T) {
+	tests := []struct {
+		name     string
+		comments []*models.Comment
+		want     string
+	}{
+		{"live comments stay", []*models.Comment{node(1, false, node(2, false)), node(3, false)}, "1(2),3"},
+		{"deleted leaf is dropped", []*models.Comment{node(1, true), node(2, false)}, "2"},
+		{"deleted reply under live comment is dropped", []*models.Comment{node(1, false, node(2, true))}, "1"},
+		{"deleted parent with live child is a placeholder", []*models.Comment{node(1, true, node(2, false))}, "1*(2)"},
+		{"deleted subtree without live replies is dropped", []*models.Comment{node(1, true, node(2, true), node(3, true, node(4, true)))}, ""},
+		{"deleted chain collapses into the top", []*models.Comment{node(1, true, node(2, true, node(3, true, node(4, false))))}, "1*(4)"},
+		{"chain below a live comment collapses", []*models.Comment{node(1, false, node(2, true, node(3, true, node(4, false), node(5, false))))}, "1(2*(4,5))"},
+		{"branching deleted comments are kept", []*models.Comment{node(1, true, node(2, true, node(4, false)), node(3, true, node(5, false)))}, "1*(2*(4),3*(5))"},
+		{"dropped sibling leaves the branch alone", []*models.Comment{node(1, true, node(2, true), node(3, true, node(4, false)))}, "1*(4)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := renderTree(t, pruneDeletedComments(tt.comments), nil); got != tt.want {
+				t.Errorf("pruned tree = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
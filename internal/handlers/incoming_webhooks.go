@@ -0,0 +1,499 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/v1-nce/threadtalk-backend/internal/db"
+	"github.com/v1-nce/threadtalk-backend/internal/markdown"
+	"github.com/v1-nce/threadtalk-backend/internal/models"
+	"github.com/v1-nce/threadtalk-backend/internal/realtime"
+	"github.com/v1-nce/threadtalk-backend/internal/webhooks"
+	"golang.org/x/time/rate"
+)
+
+const (
+	defaultIncomingRateLimit = 30
+	incomingMaxBody          = 64 << 10
+	postTitleMax             = 250
+	postContentMax           = 600
+	commentContentMax        = 2000
+)
+
+const incomingWebhookSelect = `
+	SELECT w.id, w.topic_id, w.name, u.username, w.title_template, w.content_template, w.rate_limit, w.active,
+		w.last_used_at, w.created_at, w.updated_at
+	FROM incoming_webhooks w
+	JOIN users u ON u.id = w.bot_user_id`
+
+type hookLimiter struct {
+	limiter   *rate.Limiter
+	perMinute int
+}
+
+// allowIncoming applies a webhook's rate limit: perMinute requests a minute
+// with bursts of up to a tenth of that. Limits are kept per replica.
+func (h *ForumHandler) allowIncoming(webhookID int64, perMinute int) (bool, time.Duration) {
+	h.hookMu.Lock()
+	defer h.hookMu.Unlock()
+	if h.hookLimiters == nil {
+		h.hookLimiters = make(map[int64]*hookLimiter)
+	}
+	l := h.hookLimiters[webhookID]
+	if l == nil || l.perMinute != perMinute {
+		burst := int(math.Max(1, float64(perMinute)/10))
+		l = &hookLimiter{limiter: rate.NewLimiter(rate.Limit(float64(perMinute)/60), burst), perMinute: perMinute}
+		h.hookLimiters[webhookID] = l
+	}
+	r := l.limiter.Reserve()
+	if delay := r.Delay(); delay > 0 {
+		r.Cancel()
+		return false, delay
+	}
+	return true, 0
+}
+
+func incomingWebhookErrorResponse(c *gin.Context, ctx context.Context, err error, action string, webhookID int64) {
+	switch {
+	case ctx.Err() == context.DeadlineExceeded:
+		log.Printf("WARN: Request timeout trying to %s for incoming webhook %d", action, webhookID)
+		c.JSON(http.StatusRequestTimeout, gin.H{"error": "Request timeout"})
+	case err == sql.ErrNoRows, errors.Is(err, errTopicNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+	case errors.Is(err, errPostNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Post not found"})
+	case errors.Is(err, errTopicArchived):
+		c.JSON(http.StatusLocked, gin.H{"error": "This topic is archived"})
+	case errors.Is(err, errPostLocked):
+		c.JSON(http.StatusLocked, gin.H{"error": "This thread is locked"})
+	default:
+		log.Printf("ERROR: Failed to %s for incoming webhook %d: %v", action, webhookID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to " + action})
+	}
+}
+
+func scanIncomingWebhook(row interface{ Scan(...interface{}) error }, w *models.IncomingWebhook) error {
+	return row.Scan(&w.ID, &w.TopicID, &w.Name, &w.BotUsername, &w.TitleTemplate, &w.ContentTemplate, &w.RateLimit,
+		&w.Active, &w.LastUsedAt, &w.CreatedAt, &w.UpdatedAt)
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func incomingWebhookURL(token string) string {
+	return strings.TrimRight(os.Getenv("BACKEND_URL"), "/") + "/hooks/" + token
+}
+
+func validTemplates(templates ...string) bool {
+	for _, t := range templates {
+		if _, err := webhooks.ParseTemplate(t); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// GetIncomingWebhooks lists incoming webhooks; topic_id narrows the list.
+func (h *ForumHandler) GetIncomingWebhooks(c *gin.Context) {
+	query := incomingWebhookSelect
+	var args []interface{}
+	if s := c.Query("topic_id"); s != "" {
+		topicID, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || topicID <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid topic ID"})
+			return
+		}
+		query += ` WHERE w.topic_id = $1`
+		args = append(args, topicID)
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+	rows, err := h.DB.QueryContext(ctx, query+` ORDER BY w.id`, args...)
+	if err != nil {
+		incomingWebhookErrorResponse(c, ctx, err, "fetch incoming webhooks", 0)
+		return
+	}
+	defer rows.Close()
+	hooks := make([]models.IncomingWebhook, 0)
+	for rows.Next() {
+		var w models.IncomingWebhook
+		if err := scanIncomingWebhook(rows, &w); err != nil {
+			incomingWebhookErrorResponse(c, ctx, err, "fetch incoming webhooks", 0)
+			return
+		}
+		hooks = append(hooks, w)
+	}
+	if err := rows.Err(); err != nil {
+		incomingWebhookErrorResponse(c, ctx, err, "fetch incoming webhooks", 0)
+		return
+	}
+	c.JSON(http.StatusOK, hooks)
+}
+
+// CreateIncomingWebhook adds a webhook that posts into a topic as
+// bot_username, creating that bot user unless it already belongs to another
+// incoming webhook. The token and URL are only returned here and on rotation.
+func (h *ForumHandler) CreateIncomingWebhook(c *gin.Context) {
+	var input models.IncomingWebhook
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+	if !validTemplates(input.TitleTemplate, input.ContentTemplate) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid template"})
+		return
+	}
+	if input.RateLimit == 0 {
+		input.RateLimit = defaultIncomingRateLimit
+	}
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	token, err := newWebhookSecret()
+	if err != nil {
+		log.Printf("ERROR: Failed to generate incoming webhook token: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create incoming webhook"})
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+	errUsernameTaken := errors.New("username taken")
+	err = h.withTx(ctx, func(tx *sql.Tx) error {
+		var botID int64
+		err := tx.QueryRowContext(ctx, `
+			SELECT u.id FROM users u
+			WHERE u.username = $1 AND u.is_bot AND u.owner_id IS NULL
+				AND EXISTS (SELECT 1 FROM incoming_webhooks w WHERE w.bot_user_id = u.id)`,
+			input.BotUsername).Scan(&botID)
+		if err == sql.ErrNoRows {
+			err = tx.QueryRowContext(ctx, `
+				INSERT INTO users (username, password_hash, is_bot) VALUES ($1, $2, TRUE)
+				ON CONFLICT (username) DO NOTHING
+				RETURNING id`, input.BotUsername, botPasswordHash).Scan(&botID)
+			if err == sql.ErrNoRows {
+				return errUsernameTaken
+			}
+		}
+		if err != nil {
+			return err
+		}
+		return tx.QueryRowContext(ctx, `
+			INSERT INTO incoming_webhooks (topic_id, bot_user_id, name, token_hash, title_template, content_template, rate_limit, created_by)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+			RETURNING id, active, created_at, updated_at`,
+			input.TopicID, botID, input.Name, hashToken(token), input.TitleTemplate, input.ContentTemplate, input.RateLimit, userID).
+			Scan(&input.ID, &input.Active, &input.CreatedAt, &input.UpdatedAt)
+	})
+	if err != nil {
+		if errors.Is(err, errUsernameTaken) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Username already exists"})
+		} else if isPgError(err, "23503") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid topic ID"})
+		} else {
+			incomingWebhookErrorResponse(c, ctx, err, "create incoming webhook", 0)
+		}
+		return
+	}
+	input.Token = token
+	input.URL = incomingWebhookURL(token)
+	log.Printf("INFO: User %d created incoming webhook %d for topic %d as %s", userID, input.ID, input.TopicID, input.BotUsername)
+	c.JSON(http.StatusCreated, input)
+}
+
+// UpdateIncomingWebhook changes the given fields. rotate_token replaces the
+// token, invalidating the old URL, and returns the new one.
+func (h *ForumHandler) UpdateIncomingWebhook(c *gin.Context) {
+	webhookID, err := strconv.ParseInt(c.Param("incoming_webhook_id"), 10, 64)
+	if err != nil || webhookID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook ID"})
+		return
+	}
+	var input models.IncomingWebhookUpdate
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+	var sets []string
+	var args []interface{}
+	add := func(column string, value interface{}) {
+		args = append(args, value)
+		sets = append(sets, fmt.Sprintf("%s = $%d", column, len(args)))
+	}
+	if input.Name != nil {
+		add("name", *input.Name)
+	}
+	if input.TitleTemplate != nil {
+		if !validTemplates(*input.TitleTemplate) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid template"})
+			return
+		}
+		add("title_template", *input.TitleTemplate)
+	}
+	if input.ContentTemplate != nil {
+		if !validTemplates(*input.ContentTemplate) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid template"})
+			return
+		}
+		add("content_template", *input.ContentTemplate)
+	}
+	if input.RateLimit != nil {
+		add("rate_limit", *input.RateLimit)
+	}
+	if input.Active != nil {
+		add("active", *input.Active)
+	}
+	var token string
+	if input.RotateToken {
+		if token, err = newWebhookSecret(); err != nil {
+			log.Printf("ERROR: Failed to generate incoming webhook token: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update incoming webhook"})
+			return
+		}
+		add("token_hash", hashToken(token))
+	}
+	if len(sets) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No fields to update"})
+		return
+	}
+	args = append(args, webhookID)
+	query := fmt.Sprintf(`UPDATE incoming_webhooks SET %s, updated_at = CURRENT_TIMESTAMP WHERE id = $%d`, strings.Join(sets, ", "), len(args))
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+	res, err := h.DB.ExecContext(ctx, query, args...)
+	if err == nil {
+		if n, _ := res.RowsAffected(); n == 0 {
+			err = sql.ErrNoRows
+		}
+	}
+	var w models.IncomingWebhook
+	if err == nil {
+		err = scanIncomingWebhook(h.DB.QueryRowContext(ctx, incomingWebhookSelect+` WHERE w.id = $1`, webhookID), &w)
+	}
+	if err != nil {
+		incomingWebhookErrorResponse(c, ctx, err, "update incoming webhook", webhookID)
+		return
+	}
+	if token != "" {
+		w.Token = token
+		w.URL = incomingWebhookURL(token)
+	}
+	c.JSON(http.StatusOK, w)
+}
+
+// DeleteIncomingWebhook revokes a webhook. Its bot user and their posts stay.
+func (h *ForumHandler) DeleteIncomingWebhook(c *gin.Context) {
+	webhookID, err := strconv.ParseInt(c.Param("incoming_webhook_id"), 10, 64)
+	if err != nil || webhookID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook ID"})
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+	res, err := h.DB.ExecContext(ctx, `DELETE FROM incoming_webhooks WHERE id = $1`, webhookID)
+	if err == nil {
+		if n, _ := res.RowsAffected(); n == 0 {
+			err = sql.ErrNoRows
+		}
+	}
+	if err != nil {
+		incomingWebhookErrorResponse(c, ctx, err, "delete incoming webhook", webhookID)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+type incomingHook struct {
+	ID              int64
+	TopicID         int64
+	BotUserID       int64
+	BotUsername     string
+	TitleTemplate   string
+	ContentTemplate string
+	RateLimit       int
+	Active          bool
+	Archived        bool
+}
+
+// ReceiveIncomingWebhook turns a JSON payload into a post in the webhook's
+// topic, or into a comment when post_id is given, authored by the webhook's
+// bot user. The token in the URL is the only credential.
+func (h *ForumHandler) ReceiveIncomingWebhook(c *gin.Context) {
+	token := c.Param("token")
+	if !validHexToken(token) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return
+	}
+	var postID int64
+	if s := c.Query("post_id"); s != "" {
+		var err error
+		if postID, err = strconv.ParseInt(s, 10, 64); err != nil || postID <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid post ID"})
+			return
+		}
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+	var hook incomingHook
+	err := h.DB.QueryRowContext(ctx, `
+		SELECT w.id, w.topic_id, w.bot_user_id, u.username, w.title_template, w.content_template, w.rate_limit, w.active,
+			t.archived_at IS NOT NULL
+		FROM incoming_webhooks w
+		JOIN users u ON u.id = w.bot_user_id
+		JOIN topics t ON t.id = w.topic_id
+		WHERE w.token_hash = $1`, hashToken(token)).
+		Scan(&hook.ID, &hook.TopicID, &hook.BotUserID, &hook.BotUsername, &hook.TitleTemplate, &hook.ContentTemplate,
+			&hook.RateLimit, &hook.Active, &hook.Archived)
+	if err != nil {
+		incomingWebhookErrorResponse(c, ctx, err, "receive webhook", 0)
+		return
+	}
+	if !hook.Active {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Webhook is disabled"})
+		return
+	}
+	if ok, retry := h.allowIncoming(hook.ID, hook.RateLimit); !ok {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retry.Seconds()))))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Rate limit exceeded"})
+		return
+	}
+	if hook.Archived {
+		c.JSON(http.StatusLocked, gin.H{"error": "This topic is archived"})
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, incomingMaxBody))
+	if err != nil {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Payload too large"})
+		return
+	}
+	// Keep numbers as written, so IDs such as build numbers are not printed
+	// in exponent form.
+	var payload map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	if err := dec.Decode(&payload); err != nil || payload == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Payload must be a JSON object"})
+		return
+	}
+	limit := postContentMax
+	if postID != 0 {
+		limit = commentContentMax
+	}
+	content, err := webhooks.Render(hook.ContentTemplate, payload, "content", "text")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Payload does not fit the content template"})
+		return
+	}
+	content = webhooks.Truncate(content, limit)
+	if postID != 0 {
+		h.receiveComment(c, ctx, hook, postID, content)
+		return
+	}
+	title, err := webhooks.Render(hook.TitleTemplate, payload, "title")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Payload does not fit the title template"})
+		return
+	}
+	// Chat-style payloads only carry text; title the post with its first line.
+	if title == "" {
+		title, _, _ = strings.Cut(content, "\n")
+	}
+	title = webhooks.Truncate(strings.TrimSpace(title), postTitleMax)
+	if len([]rune(title)) < 5 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Title must be at least 5 characters"})
+		return
+	}
+	h.receivePost(c, ctx, hook, title, content)
+}
+
+func (h *ForumHandler) receivePost(c *gin.Context, ctx context.Context, hook incomingHook, title, content string) {
+	post := models.Post{Title: title, Content: content, UserID: hook.BotUserID, TopicID: hook.TopicID, Username: hook.BotUsername, IsBot: true, Tags: []string{}}
+	err := h.withTx(ctx, func(tx *sql.Tx) error {
+		// The topic may have been archived since the webhook was looked up.
+		if err := checkWritableTopic(ctx, tx, post.TopicID); err != nil {
+			return err
+		}
+		err := tx.QueryRowContext(ctx,
+			`INSERT INTO posts (title, content, user_id, topic_id) VALUES ($1, $2, $3, $4) RETURNING id, created_at`,
+			post.Title, post.Content, post.UserID, post.TopicID).Scan(&post.ID, &post.CreatedAt)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `UPDATE incoming_webhooks SET last_used_at = CURRENT_TIMESTAMP WHERE id = $1`, hook.ID); err != nil {
+			return err
+		}
+		return db.RecordNewPost(ctx, tx, post.ID, post.TopicID, post.UserID, post.Content)
+	})
+	if err != nil {
+		incomingWebhookErrorResponse(c, ctx, err, "create post", hook.ID)
+		return
+	}
+	post.ContentHTML = markdown.Render(post.Content)
+	h.publish(ctx, realtime.Event{Type: realtime.EventPostCreated, TopicID: post.TopicID, PostID: post.ID}, post)
+	c.JSON(http.StatusCreated, post)
+}
+
+func (h *ForumHandler) receiveComment(c *gin.Context, ctx context.Context, hook incomingHook, postID int64, content string) {
+	if content == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Content is required"})
+		return
+	}
+	comment := models.Comment{Content: content, UserID: hook.BotUserID, PostID: postID, Username: hook.BotUsername, IsBot: true, Children: []*models.Comment{}}
+	err := h.withTx(ctx, func(tx *sql.Tx) error {
+		// Locked like CreateComment, so the thread cannot be locked or the
+		// topic archived between the check and the insert.
+		var locked, archived bool
+		err := tx.QueryRowContext(ctx, `
+			SELECT p.locked_at IS NOT NULL, t.archived_at IS NOT NULL
+			FROM posts p
+			JOIN topics t ON t.id = p.topic_id
+			WHERE p.id = $1 AND p.topic_id = $2 AND p.deleted_at IS NULL AND p.redirect_post_id IS NULL
+			FOR NO KEY UPDATE OF p FOR SHARE OF t`, postID, hook.TopicID).Scan(&locked, &archived)
+		if err == sql.ErrNoRows {
+			return errPostNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if archived {
+			return errTopicArchived
+		}
+		if locked {
+			return errPostLocked
+		}
+		err = tx.QueryRowContext(ctx,
+			`INSERT INTO comments (content, user_id, post_id) VALUES ($1, $2, $3) RETURNING id, created_at`,
+			comment.Content, comment.UserID, comment.PostID).Scan(&comment.ID, &comment.CreatedAt)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `UPDATE incoming_webhooks SET last_used_at = CURRENT_TIMESTAMP WHERE id = $1`, hook.ID); err != nil {
+			return err
+		}
+		return db.RecordNewComment(ctx, tx, comment.ID, comment.PostID, nil, comment.UserID, comment.Content)
+	})
+	if err != nil {
+		incomingWebhookErrorResponse(c, ctx, err, "post comment", hook.ID)
+		return
+	}
+	comment.ContentHTML = markdown.Render(comment.Content)
+	h.publish(ctx, realtime.Event{Type: realtime.EventCommentCreated, PostID: comment.PostID, CommentID: comment.ID}, comment)
+	c.JSON(http.StatusCreated, comment)
+}
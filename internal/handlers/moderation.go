@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/v1-nce/threadtalk-backend/internal/models"
+)
+
+func currentUserID(c *gin.Context) (int64, bool) {
+	uid, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return 0, false
+	}
+	userID, ok := uid.(int64)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID"})
+		return 0, false
+	}
+	return userID, true
+}
+
+func userRole(ctx context.Context, db *sql.DB, userID int64) (string, error) {
+	var role string
+	err := db.QueryRowContext(ctx, `SELECT role FROM users WHERE id = $1`, userID).Scan(&role)
+	return role, err
+}
+
+func (h *ForumHandler) PinPost(c *gin.Context) {
+	h.setPostFlag(c, "pinned_at", true)
+}
+
+func (h *ForumHandler) UnpinPost(c *gin.Context) {
+	h.setPostFlag(c, "pinned_at", false)
+}
+
+func (h *ForumHandler) LockPost(c *gin.Context) {
+	h.setPostFlag(c, "locked_at", true)
+}
+
+func (h *ForumHandler) UnlockPost(c *gin.Context) {
+	h.setPostFlag(c, "locked_at", false)
+}
+
+// setPostFlag sets or clears a moderation timestamp column. column must be a
+// trusted constant; it is interpolated into the query.
+func (h *ForumHandler) setPostFlag(c *gin.Context, column string, on bool) {
+	postID, err := strconv.ParseInt(c.Param("post_id"), 10, 64)
+	if err != nil || postID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid post ID"})
+		return
+	}
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	value := "NULL"
+	if on {
+		value = fmt.Sprintf("COALESCE(%s, CURRENT_TIMESTAMP)", column)
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+	var post models.Post
+	query := fmt.Sprintf(`UPDATE posts SET %s = %s WHERE id = $1 AND deleted_at IS NULL RETURNING id, user_id, topic_id, pinned_at, locked_at`, column, value)
+	if err := h.DB.QueryRowContext(ctx, query, postID).Scan(&post.ID, &post.UserID, &post.TopicID, &post.PinnedAt, &post.LockedAt); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			log.Printf("WARN: Request timeout updating %s on post %d by moderator %d", column, postID, userID)
+			c.JSON(http.StatusRequestTimeout, gin.H{"error": "Request timeout"})
+		} else if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Post not found"})
+		} else {
+			log.Printf("ERROR: Failed to update %s on post %d by moderator %d: %v", column, postID, userID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update post"})
+		}
+		return
+	}
+	log.Printf("INFO: Moderator %d set %s=%t on post %d", userID, column, on, postID)
+	action := strings.TrimSuffix(column, "_at")
+	if !on {
+		action = "un" + action
+	}
+	h.notifyModeration(ctx, action, userID, post.UserID, &post.ID, nil)
+	c.JSON(http.StatusOK, gin.H{
+		"id":        strconv.FormatInt(post.ID, 10),
+		"topic_id":  strconv.FormatInt(post.TopicID, 10),
+		"pinned_at": post.PinnedAt,
+		"locked_at": post.LockedAt,
+	})
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/v1-nce/threadtalk-backend/internal/dbtest"
+)
+
+func TestSetPostFlag(t *testing.T) {
+	at := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		handler  func(h *ForumHandler) gin.HandlerFunc
+		wantStmt string
+	}{
+		{"pin keeps an earlier pin time", func(h *ForumHandler) gin.HandlerFunc { return h.PinPost }, "SET pinned_at = COALESCE(pinned_at, CURRENT_TIMESTAMP)"},
+		{"lock keeps an earlier lock time", func(h *ForumHandler) gin.HandlerFunc { return h.LockPost }, "SET locked_at = COALESCE(locked_at, CURRENT_TIMESTAMP)"},
+		{"unpin clears the pin", func(h *ForumHandler) gin.HandlerFunc { return h.UnpinPost }, "SET pinned_at = NULL"},
+		{"unlock clears the lock", func(h *ForumHandler) gin.HandlerFunc { return h.UnlockPost }, "SET locked_at = NULL"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, f := dbtest.New(t, dbtest.Row("UPDATE posts SET", int64(5), int64(2), int64(1), at, nil))
+			h := &ForumHandler{DB: db}
+			status, resp := callHandler(t, tt.handler(h), "", "post_id", "5")
+			if status != http.StatusOK || resp["id"] != "5" || resp["topic_id"] != "1" {
+				t.Errorf("status = %d %v, want 200 for post 5 in topic 1", status, resp)
+			}
+			if !f.Executed(tt.wantStmt) {
+				t.Errorf("no statement contained %q", tt.wantStmt)
+			}
+		})
+	}
+}
+
+func TestSetPostFlagMissingPost(t *testing.T) {
+	db, _ := dbtest.New(t)
+	h := &ForumHandler{DB: db}
+	if status, _ := callHandler(t, h.LockPost, "", "post_id", "5"); status != http.StatusNotFound {
+		t.Errorf("lock missing post = %d, want 404", status)
+	}
+	if status, _ := callHandler(t, h.PinPost, "", "post_id", "x"); status != http.StatusBadRequest {
+		t.Errorf("pin with bad ID = %d, want 400", status)
+	}
+}
+
+func TestCreateCommentOnLockedThread(t *testing.T) {
+	const body = `{"content": "Late reply", "post_id": "5"}`
+	postState := func(locked, archived bool) dbtest.Reply {
+		return dbtest.Row("FOR NO KEY UPDATE OF p FOR SHARE OF t", locked, archived)
+	}
+	tests := []struct {
+		name       string
+		replies    []dbtest.Reply
+		wantStatus int
+		wantInsert bool
+	}{
+		{"member on locked thread", []dbtest.Reply{postState(true, false), dbtest.Row("SELECT role FROM users", "user")}, http.StatusLocked, false},
+		{"moderator on locked thread", []dbtest.Reply{postState(true, false), dbtest.Row("SELECT role FROM users", "moderator")}, 0, true},
+		{"anyone in archived topic", []dbtest.Reply{postState(false, true), dbtest.Row("SELECT role FROM users", "admin")}, http.StatusLocked, false},
+		{"missing post", nil, http.StatusBadRequest, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, f := dbtest.New(t, tt.replies...)
+			h := &ForumHandler{DB: db}
+			status, resp := callHandler(t, h.CreateComment, body)
+			if tt.wantStatus != 0 && status != tt.wantStatus {
+				t.Errorf("status = %d %v, want %d", status, resp, tt.wantStatus)
+			}
+			if got := f.Executed("INSERT INTO comments"); got != tt.wantInsert {
+				t.Errorf("comment inserted = %t, want %t", got, tt.wantInsert)
+			}
+		})
+	}
+}
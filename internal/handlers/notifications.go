@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/v1-nce/threadtalk-backend/internal/db"
+	"github.com/v1-nce/threadtalk-backend/internal/models"
+)
+
+const notificationPageSize = 20
+
+// liveNotifications hides notifications about content that has since been
+// deleted; they come back if it is restored.
+const liveNotifications = `
+	FROM notifications n
+	LEFT JOIN posts p ON p.id = n.post_id
+	LEFT JOIN comments c ON c.id = n.comment_id
+	LEFT JOIN users u ON u.id = n.actor_id
+	WHERE n.user_id = $1 AND p.deleted_at IS NULL AND c.deleted_at IS NULL`
+
+func notificationErrorResponse(c *gin.Context, ctx context.Context, err error, action string, userID int64) {
+	switch {
+	case ctx.Err() == context.DeadlineExceeded:
+		log.Printf("WARN: Request timeout trying to %s for user %d", action, userID)
+		c.JSON(http.StatusRequestTimeout, gin.H{"error": "Request timeout"})
+	case err == sql.ErrNoRows:
+		c.JSON(http.StatusNotFound, gin.H{"error": "Notification not found"})
+	default:
+		log.Printf("ERROR: Failed to %s for user %d: %v", action, userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to " + action})
+	}
+}
+
+// GetNotifications lists the caller's notifications, newest first. With
+// unread=true only unread ones are returned.
+func (h *ForumHandler) GetNotifications(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	cursor, ok := parseCursor(c)
+	if !ok {
+		return
+	}
+	where := liveNotifications
+	args := []interface{}{userID}
+	switch c.Query("unread") {
+	case "", "false":
+	case "true":
+		where += ` AND n.read_at IS NULL`
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid unread parameter"})
+		return
+	}
+	if cursor > 0 {
+		where += ` AND n.id < $2`
+		args = append(args, cursor)
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+	rows, err := h.DB.QueryContext(ctx, `
+		SELECT n.id, n.kind, COALESCE(n.action, ''),
+			CASE WHEN n.kind = 'moderation' THEN NULL ELSE u.username END,
+			n.post_id, COALESCE(p.title, ''), n.comment_id, n.read_at IS NOT NULL, n.created_at`+
+		where+fmt.Sprintf(` ORDER BY n.id DESC LIMIT %d`, notificationPageSize+1), args...)
+	if err != nil {
+		notificationErrorResponse(c, ctx, err, "fetch notifications", userID)
+		return
+	}
+	defer rows.Close()
+	notifications := make([]models.Notification, 0, notificationPageSize)
+	for rows.Next() {
+		var n models.Notification
+		if err := rows.Scan(&n.ID, &n.Kind, &n.Action, &n.Actor, &n.PostID, &n.PostTitle, &n.CommentID, &n.Read, &n.CreatedAt); err != nil {
+			notificationErrorResponse(c, ctx, err, "fetch notifications", userID)
+			return
+		}
+		notifications = append(notifications, n)
+	}
+	if err := rows.Err(); err != nil {
+		notificationErrorResponse(c, ctx, err, "fetch notifications", userID)
+		return
+	}
+	var nextCursor string
+	if len(notifications) > notificationPageSize {
+		nextCursor = strconv.FormatInt(notifications[notificationPageSize-1].ID, 10)
+		notifications = notifications[:notificationPageSize]
+	}
+	var unread int
+	if err := h.DB.QueryRowContext(ctx, `SELECT COUNT(*)`+liveNotifications+` AND n.read_at IS NULL`, userID).Scan(&unread); err != nil {
+		notificationErrorResponse(c, ctx, err, "fetch notifications", userID)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": notifications, "next_cursor": nextCursor, "unread_count": unread})
+}
+
+func (h *ForumHandler) MarkNotificationRead(c *gin.Context) {
+	notificationID, err := strconv.ParseInt(c.Param("notification_id"), 10, 64)
+	if err != nil || notificationID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid notification ID"})
+		return
+	}
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+	res, err := h.DB.ExecContext(ctx,
+		`UPDATE notifications SET read_at = COALESCE(read_at, CURRENT_TIMESTAMP) WHERE id = $1 AND user_id = $2`,
+		notificationID, userID)
+	if err == nil {
+		if n, _ := res.RowsAffected(); n == 0 {
+			err = sql.ErrNoRows
+		}
+	}
+	if err != nil {
+		notificationErrorResponse(c, ctx, err, "mark notification read", userID)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// MarkAllNotificationsRead marks every unread notification as read. Clients
+// may pass the newest notification ID they have shown as before_id so that
+// notifications arriving in the meantime stay unread.
+func (h *ForumHandler) MarkAllNotificationsRead(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	query := `UPDATE notifications SET read_at = CURRENT_TIMESTAMP WHERE user_id = $1 AND read_at IS NULL`
+	args := []interface{}{userID}
+	if s := c.Query("before_id"); s != "" {
+		beforeID, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || beforeID <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid before_id parameter"})
+			return
+		}
+		query += ` AND id <= $2`
+		args = append(args, beforeID)
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+	res, err := h.DB.ExecContext(ctx, query, args...)
+	if err != nil {
+		notificationErrorResponse(c, ctx, err, "mark notifications read", userID)
+		return
+	}
+	updated, _ := res.RowsAffected()
+	c.JSON(http.StatusOK, gin.H{"updated": updated})
+}
+
+// notifyModeration tells the author of a post or comment that a moderator
+// acted on it. It runs after the action has been committed, so failures are
+// only logged.
+func (h *ForumHandler) notifyModeration(ctx context.Context, action string, moderatorID, authorID int64, postID, commentID *int64) {
+	n := db.Notification{Kind: db.NotificationModeration, Action: action, ActorID: moderatorID, PostID: postID, CommentID: commentID}
+	if err := db.InsertNotifications(ctx, h.DB, n, []int64{authorID}); err != nil {
+		log.Printf("WARN: Failed to notify user %d that moderator %d %s their content: %v", authorID, moderatorID, action, err)
+	}
+}
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"database/sql/driver"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/v1-nce/threadtalk-backend/internal/dbtest"
+)
+
+func TestGetNotificationsPaging(t *testing.T) {
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	page := dbtest.Reply{Match: "ORDER BY n.id DESC"}
+	for id := int64(30); id > 30-notificationPageSize-1; id-- {
+		page.Rows = append(page.Rows, []driver.Value{id, "reply", "", "alice", int64(5), "Title", nil, false, created})
+	}
+	db, f := dbtest.New(t, page, dbtest.Row("SELECT COUNT(*)", int64(7)))
+	h := &ForumHandler{DB: db}
+	status, resp := callHandlerAt(t, h.GetNotifications, "/?unread=true&cursor=31", "")
+	if status != http.StatusOK {
+		t.Fatalf("status = %d %v, want 200", status, resp)
+	}
+	data, _ := resp["data"].([]interface{})
+	if len(data) != notificationPageSize {
+		t.Errorf("got %d notifications, want %d", len(data), notificationPageSize)
+	}
+	if resp["next_cursor"] != "11" || resp["unread_count"] != float64(7) {
+		t.Errorf("next_cursor = %v, unread_count = %v, want 11 and 7", resp["next_cursor"], resp["unread_count"])
+	}
+	stmts := f.Find("ORDER BY n.id DESC")
+	if len(stmts) != 1 || len(stmts[0].Args) != 2 || stmts[0].Args[1] != int64(31) {
+		t.Fatalf("page query = %+v, want user and cursor args", stmts)
+	}
+	if !f.Executed("AND n.read_at IS NULL AND n.id < $2") {
+		t.Error("unread=true did not filter the page")
+	}
+}
+
+func TestGetNotificationsInvalidParams(t *testing.T) {
+	// Rejected before any query, so the handler needs no database.
+	h := &ForumHandler{}
+	for _, target := range []string{"/?unread=yes", "/?cursor=0", "/?cursor=abc"} {
+		if status, _ := callHandlerAt(t, h.GetNotifications, target, ""); status != http.StatusBadRequest {
+			t.Errorf("GET %s = %d, want 400", target, status)
+		}
+	}
+}
+
+func TestMarkNotificationRead(t *testing.T) {
+	tests := []struct {
+		name       string
+		affected   int64
+		wantStatus int
+	}{
+		{"own notification", 1, http.StatusNoContent},
+		{"someone else's or missing", 0, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, f := dbtest.New(t, dbtest.Reply{Match: "UPDATE notifications", Affected: tt.affected})
+			h := &ForumHandler{DB: db}
+			status, _ := callHandler(t, h.MarkNotificationRead, "", "notification_id", "9")
+			if status != tt.wantStatus {
+				t.Errorf("status = %d, want %d", status, tt.wantStatus)
+			}
+			stmts := f.Find("WHERE id = $1 AND user_id = $2")
+			if len(stmts) != 1 || stmts[0].Args[1] != int64(1) {
+				t.Errorf("update not scoped to the caller: %+v", stmts)
+			}
+		})
+	}
+}
+
+func TestMarkAllNotificationsRead(t *testing.T) {
+	db, f := dbtest.New(t, dbtest.Reply{Match: "UPDATE notifications", Affected: 3})
+	h := &ForumHandler{DB: db}
+	status, resp := callHandlerAt(t, h.MarkAllNotificationsRead, "/?before_id=40", "")
+	if status != http.StatusOK || resp["updated"] != float64(3) {
+		t.Errorf("status = %d %v, want 200 with 3 updated", status, resp)
+	}
+	stmts := f.Find("AND id <= $2")
+	if len(stmts) != 1 || stmts[0].Args[1] != int64(40) {
+		t.Errorf("newer notifications not left unread: %+v", stmts)
+	}
+	if status, _ := callHandlerAt(t, h.MarkAllNotificationsRead, "/?before_id=x", ""); status != http.StatusBadRequest {
+		t.Errorf("bad before_id = %d, want 400", status)
+	}
+}
@@ -0,0 +1,253 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/v1-nce/threadtalk-backend/internal/models"
+)
+
+var (
+	errPollNotFound    = errors.New("poll not found")
+	errPollClosed      = errors.New("poll is closed")
+	errAlreadyVoted    = errors.New("you have already voted in this poll")
+	errSingleChoice    = errors.New("this poll allows only one choice")
+	errInvalidOption   = errors.New("invalid poll option")
+	errDuplicateOption = errors.New("poll options must be unique")
+	errPollClosesAt    = errors.New("poll close time must be in the future")
+)
+
+// normalizePoll trims labels and checks a poll submitted with a new post.
+func normalizePoll(poll *models.Poll) error {
+	seen := make(map[string]bool, len(poll.Options))
+	for i := range poll.Options {
+		label := strings.TrimSpace(poll.Options[i].Label)
+		if label == "" || seen[strings.ToLower(label)] {
+			return errDuplicateOption
+		}
+		seen[strings.ToLower(label)] = true
+		poll.Options[i] = models.PollOption{Label: label}
+	}
+	poll.Question = strings.TrimSpace(poll.Question)
+	if poll.ClosesAt != nil && !poll.ClosesAt.After(time.Now()) {
+		return errPollClosesAt
+	}
+	poll.Closed, poll.Voted, poll.ResultsVisible, poll.TotalVoters = false, false, false, nil
+	return nil
+}
+
+func insertPoll(ctx context.Context, tx *sql.Tx, postID int64, poll *models.Poll) error {
+	var pollID int64
+	if err := tx.QueryRowContext(ctx, `
+		INSERT INTO polls (post_id, question, multiple_choice, public_votes, closes_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id`, postID, poll.Question, poll.MultipleChoice, poll.PublicVotes, poll.ClosesAt).Scan(&pollID); err != nil {
+		return err
+	}
+	for i := range poll.Options {
+		if err := tx.QueryRowContext(ctx,
+			`INSERT INTO poll_options (poll_id, label, position) VALUES ($1, $2, $3) RETURNING id`,
+			pollID, poll.Options[i].Label, i).Scan(&poll.Options[i].ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadPoll returns the poll on a post as seen by userID (0 for anonymous
+// callers), or nil if the post has none.
+func (h *ForumHandler) loadPoll(ctx context.Context, postID, userID int64) (*models.Poll, error) {
+	poll := &models.Poll{}
+	var pollID int64
+	var totalVoters int
+	err := h.DB.QueryRowContext(ctx, `
+		SELECT pl.id, pl.question, pl.multiple_choice, pl.public_votes, pl.closes_at,
+			pl.closes_at IS NOT NULL AND pl.closes_at <= CURRENT_TIMESTAMP,
+			EXISTS (SELECT 1 FROM poll_votes v WHERE v.poll_id = pl.id AND v.user_id = $2),
+			(SELECT COUNT(DISTINCT v.user_id) FROM poll_votes v WHERE v.poll_id = pl.id)
+		FROM polls pl
+		WHERE pl.post_id = $1`, postID, userID).
+		Scan(&pollID, &poll.Question, &poll.MultipleChoice, &poll.PublicVotes, &poll.ClosesAt, &poll.Closed, &poll.Voted, &totalVoters)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	poll.ResultsVisible = poll.Voted || poll.Closed
+	rows, err := h.DB.QueryContext(ctx, `
+		SELECT o.id, o.label, COUNT(v.user_id), COALESCE(BOOL_OR(v.user_id = $2), FALSE)
+		FROM poll_options o
+		LEFT JOIN poll_votes v ON v.option_id = o.id
+		WHERE o.poll_id = $1
+		GROUP BY o.id
+		ORDER BY o.position ASC`, pollID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	byID := make(map[int64]*models.PollOption)
+	for rows.Next() {
+		var o models.PollOption
+		var votes int
+		if err := rows.Scan(&o.ID, &o.Label, &votes, &o.Voted); err != nil {
+			return nil, err
+		}
+		if poll.ResultsVisible {
+			o.Votes = &votes
+		}
+		poll.Options = append(poll.Options, o)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if !poll.ResultsVisible {
+		return poll, nil
+	}
+	poll.TotalVoters = &totalVoters
+	if !poll.PublicVotes {
+		return poll, nil
+	}
+	for i := range poll.Options {
+		byID[poll.Options[i].ID] = &poll.Options[i]
+	}
+	voters, err := h.DB.QueryContext(ctx, `
+		SELECT v.option_id, u.username
+		FROM poll_votes v
+		JOIN users u ON u.id = v.user_id
+		WHERE v.poll_id = $1
+		ORDER BY v.created_at ASC`, pollID)
+	if err != nil {
+		return nil, err
+	}
+	defer voters.Close()
+	for voters.Next() {
+		var optionID int64
+		var username string
+		if err := voters.Scan(&optionID, &username); err != nil {
+			return nil, err
+		}
+		if o, ok := byID[optionID]; ok {
+			o.Voters = append(o.Voters, username)
+		}
+	}
+	return poll, voters.Err()
+}
+
+func (h *ForumHandler) VotePoll(c *gin.Context) {
+	postID, err := strconv.ParseInt(c.Param("post_id"), 10, 64)
+	if err != nil || postID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid post ID"})
+		return
+	}
+	var input models.PollVoteInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	seen := make(map[int64]bool, len(input.OptionIDs))
+	optionIDs := make([]int64, 0, len(input.OptionIDs))
+	for _, id := range input.OptionIDs {
+		if !seen[id] {
+			seen[id] = true
+			optionIDs = append(optionIDs, id)
+		}
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+	err = h.withTx(ctx, func(tx *sql.Tx) error {
+		// Sharing the post and topic holds off a concurrent lock or archive
+		// until the vote is in.
+		var pollID int64
+		var multiple, closed, voted, locked, archived bool
+		err := tx.QueryRowContext(ctx, `
+			SELECT pl.id, pl.multiple_choice, pl.closes_at IS NOT NULL AND pl.closes_at <= CURRENT_TIMESTAMP,
+				p.locked_at IS NOT NULL, t.archived_at IS NOT NULL
+			FROM polls pl
+			JOIN posts p ON p.id = pl.post_id
+			JOIN topics t ON t.id = p.topic_id
+			WHERE pl.post_id = $1 AND p.deleted_at IS NULL
+			FOR UPDATE OF pl FOR SHARE OF p, t`, postID).Scan(&pollID, &multiple, &closed, &locked, &archived)
+		if err == sql.ErrNoRows {
+			return errPollNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if archived {
+			return errTopicArchived
+		}
+		if locked {
+			role, err := userRole(ctx, h.DB, userID)
+			if err != nil {
+				return fmt.Errorf("load role: %w", err)
+			}
+			if !models.IsModeratorRole(role) {
+				return errPostLocked
+			}
+		}
+		if closed {
+			return errPollClosed
+		}
+		if !multiple && len(optionIDs) != 1 {
+			return errSingleChoice
+		}
+		if err := tx.QueryRowContext(ctx,
+			`SELECT EXISTS (SELECT 1 FROM poll_votes WHERE poll_id = $1 AND user_id = $2)`, pollID, userID).Scan(&voted); err != nil {
+			return err
+		}
+		if voted {
+			return errAlreadyVoted
+		}
+		res, err := tx.ExecContext(ctx, `
+			INSERT INTO poll_votes (poll_id, option_id, user_id)
+			SELECT $1, id, $2 FROM poll_options WHERE poll_id = $1 AND id = ANY($3)`, pollID, userID, optionIDs)
+		if err != nil {
+			return err
+		}
+		if n, _ := res.RowsAffected(); n != int64(len(optionIDs)) {
+			return errInvalidOption
+		}
+		return nil
+	})
+	var poll *models.Poll
+	if err == nil {
+		poll, err = h.loadPoll(ctx, postID, userID)
+	}
+	if err != nil {
+		switch {
+		case ctx.Err() == context.DeadlineExceeded:
+			log.Printf("WARN: Request timeout voting on poll for post %d by user %d", postID, userID)
+			c.JSON(http.StatusRequestTimeout, gin.H{"error": "Request timeout"})
+		case errors.Is(err, errPollNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Poll not found"})
+		case errors.Is(err, errPollClosed):
+			c.JSON(http.StatusLocked, gin.H{"error": "This poll is closed"})
+		case errors.Is(err, errTopicArchived):
+			c.JSON(http.StatusLocked, gin.H{"error": "This topic is archived"})
+		case errors.Is(err, errPostLocked):
+			c.JSON(http.StatusLocked, gin.H{"error": "This thread is locked"})
+		case errors.Is(err, errAlreadyVoted), isPgError(err, "23505"):
+			c.JSON(http.StatusConflict, gin.H{"error": errAlreadyVoted.Error()})
+		case errors.Is(err, errSingleChoice), errors.Is(err, errInvalidOption):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			log.Printf("ERROR: Failed to vote on poll for post %d by user %d: %v", postID, userID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record vote"})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, poll)
+}
@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/v1-nce/threadtalk-backend/internal/dbtest"
+	"github.com/v1-nce/threadtalk-backend/internal/models"
+)
+
+func TestNormalizePoll(t *testing.T) {
+	past := time.Now().Add(-time.Minute)
+	future := time.Now().Add(time.Hour)
+	options := func(labels ...string) []models.PollOption {
+		var opts []models.PollOption
+		for _, l := range labels {
+			opts = append(opts, models.PollOption{ID: 99, Label: l, Voted: true})
+		}
+		return opts
+	}
+	tests := []struct {
+		name       string
+		poll       models.Poll
+		wantErr    error
+		wantLabels []string
+	}{
+		{"trims labels and question", models.Poll{Question: "  Best? ", Options: options(" Go ", "Rust")}, nil, []string{"Go", "Rust"}},
+		{"duplicate labels ignoring case", models.Poll{Options: options("Go", " go")}, errDuplicateOption, nil},
+		{"blank label", models.Poll{Options: options("Go", "   ")}, errDuplicateOption, nil},
+		{"closes in the past", models.Poll{Options: options("Go", "Rust"), ClosesAt: &past}, errPollClosesAt, nil},
+		{"closes in the future", models.Poll{Options: options("Go", "Rust"), ClosesAt: &future}, nil, []string{"Go", "Rust"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			poll := tt.poll
+			poll.Closed, poll.Voted, poll.ResultsVisible = true, true, true
+			err := normalizePoll(&poll)
+			if err != tt.wantErr {
+				t.Fatalf("normalizePoll() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if poll.Question != strings.TrimSpace(tt.poll.Question) {
+				t.Errorf("question = %q, want trimmed", poll.Question)
+			}
+			for i, o := range poll.Options {
+				if o.Label != tt.wantLabels[i] || o.ID != 0 || o.Voted {
+					t.Errorf("option %d = %+v, want only label %q", i, o, tt.wantLabels[i])
+				}
+			}
+			if poll.Closed || poll.Voted || poll.ResultsVisible {
+				t.Error("client-supplied state was kept")
+			}
+		})
+	}
+}
+
+func TestPollOptionIDsAreStrings(t *testing.T) {
+	var input models.PollVoteInput
+	if err := json.Unmarshal([]byte(`{"option_ids": ["12", "9007199254740993"]}`), &input); err != nil {
+		t.Fatal(err)
+	}
+	if len(input.OptionIDs) != 2 || input.OptionIDs[1] != 9007199254740993 {
+		t.Errorf("option_ids = %v", input.OptionIDs)
+	}
+	if err := json.Unmarshal([]byte(`{"option_ids": [12]}`), &input); err == nil {
+		t.Error("numeric option ID accepted, want strings like other IDs")
+	}
+	out, _ := json.Marshal(models.PollOption{ID: 12, Label: "Go"})
+	if string(out) != `{"id":"12","label":"Go"}` {
+		t.Errorf("option = %s, want string ID", out)
+	}
+}
+
+func TestVotePollOnClosedThread(t *testing.T) {
+	const body = `{"option_ids": ["12"]}`
+	poll := func(locked, archived bool) dbtest.Reply {
+		return dbtest.Row("FROM polls pl", int64(3), false, false, locked, archived)
+	}
+	vote := []dbtest.Reply{
+		dbtest.Row("SELECT EXISTS (SELECT 1 FROM poll_votes", false),
+		{Match: "INSERT INTO poll_votes", Affected: 1},
+	}
+	tests := []struct {
+		name       string
+		replies    []dbtest.Reply
+		wantStatus int
+		wantError  string
+		wantVote   bool
+	}{
+		{"member on locked thread", []dbtest.Reply{poll(true, false), dbtest.Row("SELECT role FROM users", "user")}, http.StatusLocked, "This thread is locked", false},
+		{"moderator on locked thread", append([]dbtest.Reply{poll(true, false), dbtest.Row("SELECT role FROM users", "moderator")}, vote...), 0, "", true},
+		{"anyone in archived topic", []dbtest.Reply{poll(false, true)}, http.StatusLocked, "This topic is archived", false},
+		{"open thread", append([]dbtest.Reply{poll(false, false)}, vote...), 0, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, f := dbtest.New(t, tt.replies...)
+			h := &ForumHandler{DB: db}
+			status, resp := callHandler(t, h.VotePoll, body, "post_id", "5")
+			if tt.wantStatus != 0 && (status != tt.wantStatus || resp["error"] != tt.wantError) {
+				t.Errorf("status = %d %v, want %d %q", status, resp, tt.wantStatus, tt.wantError)
+			}
+			if got := f.Executed("INSERT INTO poll_votes"); got != tt.wantVote {
+				t.Errorf("vote inserted = %t, want %t", got, tt.wantVote)
+			}
+		})
+	}
+}
@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/v1-nce/threadtalk-backend/internal/models"
+)
+
+var defaultReactions = []string{"👍", "👎", "❤️", "😂", "🎉", "😮", "😢"}
+
+var (
+	reactionsOnce    sync.Once
+	reactionAllowed  map[string]bool
+	reactionOrdering []string
+)
+
+// allowedReactions returns the emoji users may react with. It reads the
+// comma-separated REACTIONS_ALLOWLIST once and falls back to defaultReactions.
+func allowedReactions() ([]string, map[string]bool) {
+	reactionsOnce.Do(func() {
+		list := defaultReactions
+		if env := os.Getenv("REACTIONS_ALLOWLIST"); env != "" {
+			list = nil
+			for _, e := range strings.Split(env, ",") {
+				if e = strings.TrimSpace(e); e != "" && len(e) <= 32 {
+					list = append(list, e)
+				}
+			}
+			if len(list) == 0 {
+				log.Printf("WARN: REACTIONS_ALLOWLIST has no usable entries, using defaults")
+				list = defaultReactions
+			}
+		}
+		reactionAllowed = make(map[string]bool, len(list))
+		for _, e := range list {
+			if !reactionAllowed[e] {
+				reactionAllowed[e] = true
+				reactionOrdering = append(reactionOrdering, e)
+			}
+		}
+	})
+	return reactionOrdering, reactionAllowed
+}
+
+// optionalUserID returns the caller's ID on routes where authentication is
+// optional, or 0 for anonymous requests.
+func optionalUserID(c *gin.Context) int64 {
+	userID, _ := c.Get("userID")
+	id, _ := userID.(int64)
+	return id
+}
+
+func (h *ForumHandler) GetReactions(c *gin.Context) {
+	list, _ := allowedReactions()
+	c.JSON(http.StatusOK, list)
+}
+
+func (h *ForumHandler) AddPostReaction(c *gin.Context) {
+	h.react(c, "posts", "post_id", "post", true)
+}
+
+func (h *ForumHandler) RemovePostReaction(c *gin.Context) {
+	h.react(c, "posts", "post_id", "post", false)
+}
+
+func (h *ForumHandler) AddCommentReaction(c *gin.Context) {
+	h.react(c, "comments", "comment_id", "comment", true)
+}
+
+func (h *ForumHandler) RemoveCommentReaction(c *gin.Context) {
+	h.react(c, "comments", "comment_id", "comment", false)
+}
+
+// react adds or removes the caller's emoji on a post or comment and returns
+// the updated aggregate. table and column must be trusted constants; they are
+// interpolated into the query. column doubles as the route parameter name.
+func (h *ForumHandler) react(c *gin.Context, table, column, noun string, add bool) {
+	id, err := strconv.ParseInt(c.Param(column), 10, 64)
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid %s ID", noun)})
+		return
+	}
+	emoji := c.Param("emoji")
+	if _, allowed := allowedReactions(); !allowed[emoji] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Reaction is not allowed"})
+		return
+	}
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+	if add {
+		var exists bool
+		err = h.DB.QueryRowContext(ctx, fmt.Sprintf(`SELECT EXISTS (
+			SELECT 1 FROM %s WHERE id = $1 AND deleted_at IS NULL AND redirect_post_id IS NULL)`, table), id).Scan(&exists)
+		if err == nil && !exists {
+			err = sql.ErrNoRows
+		}
+		if err == nil {
+			_, err = h.DB.ExecContext(ctx, fmt.Sprintf(`INSERT INTO reactions (user_id, %s, emoji) VALUES ($1, $2, $3)
+				ON CONFLICT DO NOTHING`, column), userID, id, emoji)
+		}
+	} else {
+		_, err = h.DB.ExecContext(ctx, fmt.Sprintf(`DELETE FROM reactions WHERE user_id = $1 AND %s = $2 AND emoji = $3`, column),
+			userID, id, emoji)
+	}
+	var reactions []models.Reaction
+	if err == nil {
+		reactions, err = h.targetReactions(ctx, column, id, userID)
+	}
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			log.Printf("WARN: Request timeout reacting to %s %d by user %d", noun, id, userID)
+			c.JSON(http.StatusRequestTimeout, gin.H{"error": "Request timeout"})
+		} else if err == sql.ErrNoRows || isPgError(err, "23503") {
+			c.JSON(http.StatusNotFound, gin.H{"error": strings.ToUpper(noun[:1]) + noun[1:] + " not found"})
+		} else {
+			log.Printf("ERROR: Failed to update reaction on %s %d by user %d: %v", noun, id, userID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update reaction"})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"reactions": reactions})
+}
+
+func (h *ForumHandler) targetReactions(ctx context.Context, column string, id, userID int64) ([]models.Reaction, error) {
+	rows, err := h.DB.QueryContext(ctx, fmt.Sprintf(`
+		SELECT emoji, COUNT(*), BOOL_OR(user_id = $2)
+		FROM reactions
+		WHERE %s = $1
+		GROUP BY emoji
+		ORDER BY COUNT(*) DESC, MIN(created_at) ASC`, column), id, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	reactions := []models.Reaction{}
+	for rows.Next() {
+		var r models.Reaction
+		if err := rows.Scan(&r.Emoji, &r.Count, &r.Reacted); err != nil {
+			return nil, err
+		}
+		reactions = append(reactions, r)
+	}
+	return reactions, rows.Err()
+}
+
+// threadReactions aggregates reactions on a post and every comment in it. The
+// post's reactions are keyed under 0.
+func (h *ForumHandler) threadReactions(ctx context.Context, postID, userID int64) (map[int64][]models.Reaction, error) {
+	rows, err := h.DB.QueryContext(ctx, `
+		SELECT 0::BIGINT, emoji, COUNT(*), BOOL_OR(user_id = $2), MIN(created_at)
+		FROM reactions
+		WHERE post_id = $1
+		GROUP BY emoji
+		UNION ALL
+		SELECT r.comment_id, r.emoji, COUNT(*), BOOL_OR(r.user_id = $2), MIN(r.created_at)
+		FROM reactions r
+		JOIN comments c ON c.id = r.comment_id
+		WHERE c.post_id = $1
+		GROUP BY r.comment_id, r.emoji
+		ORDER BY 3 DESC, 5 ASC`, postID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	byTarget := make(map[int64][]models.Reaction)
+	for rows.Next() {
+		var targetID int64
+		var r models.Reaction
+		var first time.Time
+		if err := rows.Scan(&targetID, &r.Emoji, &r.Count, &r.Reacted, &first); err != nil {
+			return nil, err
+		}
+		byTarget[targetID] = append(byTarget[targetID], r)
+	}
+	return byTarget, rows.Err()
+}
+
+func attachCommentReactions(comments []*models.Comment, byTarget map[int64][]models.Reaction) {
+	for _, c := range comments {
+		if !c.Deleted {
+			c.Reactions = byTarget[c.ID]
+		}
+		attachCommentReactions(c.Children, byTarget)
+	}
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"database/sql/driver"
+	"net/http"
+	"testing"
+
+	"github.com/v1-nce/threadtalk-backend/internal/dbtest"
+	"github.com/v1-nce/threadtalk-backend/internal/models"
+)
+
+func TestReactValidation(t *testing.T) {
+	// Rejected before any query, so the handler needs no database.
+	h := &ForumHandler{}
+	tests := []struct {
+		name   string
+		params []string
+		want   string
+	}{
+		{"emoji not in the allowlist", []string{"post_id", "5", "emoji", "🦄"}, "Reaction is not allowed"},
+		{"text instead of emoji", []string{"post_id", "5", "emoji", "like"}, "Reaction is not allowed"},
+		{"bad post ID", []string{"post_id", "x", "emoji", "👍"}, "Invalid post ID"},
+		{"zero post ID", []string{"post_id", "0", "emoji", "👍"}, "Invalid post ID"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, resp := callHandler(t, h.AddPostReaction, "", tt.params...)
+			if status != http.StatusBadRequest || resp["error"] != tt.want {
+				t.Errorf("status = %d %v, want 400 %q", status, resp, tt.want)
+			}
+		})
+	}
+}
+
+func TestAddReactionToMissingComment(t *testing.T) {
+	db, f := dbtest.New(t, dbtest.Row("SELECT EXISTS", false))
+	h := &ForumHandler{DB: db}
+	status, resp := callHandler(t, h.AddCommentReaction, "", "comment_id", "7", "emoji", "👍")
+	if status != http.StatusNotFound || resp["error"] != "Comment not found" {
+		t.Errorf("status = %d %v, want 404 Comment not found", status, resp)
+	}
+	if f.Executed("INSERT INTO reactions") {
+		t.Error("reacted to a missing comment")
+	}
+}
+
+func TestAddReaction(t *testing.T) {
+	db, f := dbtest.New(t,
+		dbtest.Row("SELECT EXISTS", true),
+		dbtest.Reply{Match: "GROUP BY emoji", Rows: [][]driver.Value{{"👍", int64(3), true}, {"🎉", int64(1), false}}},
+	)
+	h := &ForumHandler{DB: db}
+	status, resp := callHandler(t, h.AddPostReaction, "", "post_id", "5", "emoji", "👍")
+	if status != http.StatusOK {
+		t.Fatalf("status = %d %v, want 200", status, resp)
+	}
+	if !f.Executed("ON CONFLICT DO NOTHING") {
+		t.Error("reaction was not inserted")
+	}
+	reactions, _ := resp["reactions"].([]interface{})
+	if len(reactions) != 2 {
+		t.Fatalf("reactions = %v, want 2 entries", resp["reactions"])
+	}
+	first, _ := reactions[0].(map[string]interface{})
+	if first["emoji"] != "👍" || first["count"] != float64(3) || first["reacted"] != true {
+		t.Errorf("first reaction = %v, want 👍 x3 reacted", first)
+	}
+}
+
+func TestAttachCommentReactions(t *testing.T) {
+	reply := &models.Comment{ID: 3}
+	deleted := &models.Comment{ID: 2, Deleted: true, Children: []*models.Comment{reply}}
+	root := &models.Comment{ID: 1, Children: []*models.Comment{deleted}}
+	byTarget := map[int64][]models.Reaction{
+		1: {{Emoji: "👍", Count: 1}},
+		2: {{Emoji: "😂", Count: 2}},
+		3: {{Emoji: "🎉", Count: 3}},
+	}
+	attachCommentReactions([]*models.Comment{root}, byTarget)
+	if len(root.Reactions) != 1 || len(reply.Reactions) != 1 {
+		t.Errorf("reactions not attached through the tree: root %v, reply %v", root.Reactions, reply.Reactions)
+	}
+	if deleted.Reactions != nil {
+		t.Errorf("deleted comment kept reactions %v", deleted.Reactions)
+	}
+}
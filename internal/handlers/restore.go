@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/v1-nce/threadtalk-backend/internal/models"
+)
+
+// Authors may undo their own deletions within this window; moderators may
+// restore at any time.
+const restoreGracePeriod = 24 * time.Hour
+
+func (h *ForumHandler) RestorePost(c *gin.Context) {
+	h.restore(c, "posts", "post_id", "post")
+}
+
+func (h *ForumHandler) RestoreComment(c *gin.Context) {
+	h.restore(c, "comments", "comment_id", "comment")
+}
+
+// restore clears deleted_at on a row of table. table must be a trusted
+// constant; it is interpolated into the query.
+func (h *ForumHandler) restore(c *gin.Context, table, param, noun string) {
+	id, err := strconv.ParseInt(c.Param(param), 10, 64)
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid %s ID", noun)})
+		return
+	}
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	notFound := strings.ToUpper(noun[:1]) + noun[1:] + " not found"
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+	var ownerID int64
+	var withinGrace bool
+	query := fmt.Sprintf(`SELECT user_id, deleted_at > CURRENT_TIMESTAMP - make_interval(secs => $2)
+		FROM %s WHERE id = $1 AND deleted_at IS NOT NULL AND purged_at IS NULL`, table)
+	err = h.DB.QueryRowContext(ctx, query, id, restoreGracePeriod.Seconds()).Scan(&ownerID, &withinGrace)
+	if err == nil && !(ownerID == userID && withinGrace) {
+		var role string
+		role, err = userRole(ctx, h.DB, userID)
+		if err == nil && !models.IsModeratorRole(role) {
+			if ownerID == userID {
+				c.JSON(http.StatusForbidden, gin.H{"error": "Restore window has expired"})
+			} else {
+				c.JSON(http.StatusNotFound, gin.H{"error": notFound})
+			}
+			return
+		}
+	}
+	if err == nil {
+		// The retention worker may have purged the row since it was read.
+		var res sql.Result
+		res, err = h.DB.ExecContext(ctx, fmt.Sprintf(`
+			UPDATE %s SET deleted_at = NULL
+			WHERE id = $1 AND deleted_at IS NOT NULL AND purged_at IS NULL`, table), id)
+		if err == nil {
+			var n int64
+			if n, err = res.RowsAffected(); err == nil && n == 0 {
+				err = sql.ErrNoRows
+			}
+		}
+	}
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			log.Printf("WARN: Request timeout restoring %s %d by user %d", table, id, userID)
+			c.JSON(http.StatusRequestTimeout, gin.H{"error": "Request timeout"})
+		} else if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": notFound})
+		} else {
+			log.Printf("ERROR: Failed to restore %s %d by user %d: %v", table, id, userID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore " + noun})
+		}
+		return
+	}
+	if ownerID != userID {
+		log.Printf("INFO: Moderator %d restored %s %d", userID, table, id)
+		if table == "posts" {
+			h.notifyModeration(ctx, "restored", userID, ownerID, &id, nil)
+		} else {
+			h.notifyModeration(ctx, "restored", userID, ownerID, nil, &id)
+		}
+	}
+	c.Status(http.StatusNoContent)
+}
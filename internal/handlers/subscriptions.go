@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/v1-nce/threadtalk-backend/internal/models"
+)
+
+const subscriptionPageSize = 50
+
+func subscriptionErrorResponse(c *gin.Context, ctx context.Context, err error, action string, userID int64) {
+	switch {
+	case ctx.Err() == context.DeadlineExceeded:
+		log.Printf("WARN: Request timeout trying to %s for user %d", action, userID)
+		c.JSON(http.StatusRequestTimeout, gin.H{"error": "Request timeout"})
+	case err == sql.ErrNoRows:
+		c.JSON(http.StatusNotFound, gin.H{"error": "Subscription not found"})
+	default:
+		log.Printf("ERROR: Failed to %s for user %d: %v", action, userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to " + action})
+	}
+}
+
+// GetSubscriptions lists the posts and topics the caller follows or has
+// muted, newest first. kind=post or kind=topic narrows the list.
+func (h *ForumHandler) GetSubscriptions(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	cursor, ok := parseCursor(c)
+	if !ok {
+		return
+	}
+	where := ` WHERE s.user_id = $1 AND (p.id IS NULL OR p.deleted_at IS NULL)`
+	args := []interface{}{userID}
+	switch c.Query("kind") {
+	case "":
+	case "post":
+		where += ` AND s.post_id IS NOT NULL`
+	case "topic":
+		where += ` AND s.topic_id IS NOT NULL`
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid kind parameter"})
+		return
+	}
+	if cursor > 0 {
+		where += ` AND s.id < $2`
+		args = append(args, cursor)
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+	rows, err := h.DB.QueryContext(ctx, `
+		SELECT s.id, s.post_id, COALESCE(p.title, ''), s.topic_id, COALESCE(t.name, ''), s.level, s.created_at, s.updated_at
+		FROM subscriptions s
+		LEFT JOIN posts p ON p.id = s.post_id
+		LEFT JOIN topics t ON t.id = s.topic_id`+
+		where+fmt.Sprintf(` ORDER BY s.id DESC LIMIT %d`, subscriptionPageSize+1), args...)
+	if err != nil {
+		subscriptionErrorResponse(c, ctx, err, "fetch subscriptions", userID)
+		return
+	}
+	defer rows.Close()
+	subs := make([]models.Subscription, 0)
+	for rows.Next() {
+		var s models.Subscription
+		if err := rows.Scan(&s.ID, &s.PostID, &s.PostTitle, &s.TopicID, &s.TopicName, &s.Level, &s.CreatedAt, &s.UpdatedAt); err != nil {
+			subscriptionErrorResponse(c, ctx, err, "fetch subscriptions", userID)
+			return
+		}
+		subs = append(subs, s)
+	}
+	if err := rows.Err(); err != nil {
+		subscriptionErrorResponse(c, ctx, err, "fetch subscriptions", userID)
+		return
+	}
+	var nextCursor string
+	if len(subs) > subscriptionPageSize {
+		nextCursor = strconv.FormatInt(subs[subscriptionPageSize-1].ID, 10)
+		subs = subs[:subscriptionPageSize]
+	}
+	c.JSON(http.StatusOK, gin.H{"data": subs, "next_cursor": nextCursor})
+}
+
+// PutSubscription follows a post or topic at the given level, or changes the
+// level of an existing subscription.
+func (h *ForumHandler) PutSubscription(c *gin.Context) {
+	var input models.Subscription
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	if (input.PostID == nil) == (input.TopicID == nil) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Exactly one of post_id and topic_id is required"})
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+	column, id := "post_id", input.PostID
+	exists := `SELECT title FROM posts WHERE id = $1 AND deleted_at IS NULL AND redirect_post_id IS NULL`
+	if input.TopicID != nil {
+		column, id = "topic_id", input.TopicID
+		exists = `SELECT name FROM topics WHERE id = $1`
+	}
+	var name string
+	err := h.DB.QueryRowContext(ctx, exists, *id).Scan(&name)
+	if err == sql.ErrNoRows {
+		if input.PostID != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Post not found"})
+		} else {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Topic not found"})
+		}
+		return
+	}
+	if err == nil {
+		err = h.DB.QueryRowContext(ctx, fmt.Sprintf(`
+			INSERT INTO subscriptions (user_id, %[1]s, level) VALUES ($1, $2, $3)
+			ON CONFLICT (%[1]s, user_id) WHERE %[1]s IS NOT NULL
+			DO UPDATE SET level = EXCLUDED.level, updated_at = CURRENT_TIMESTAMP
+			RETURNING id, created_at, updated_at`, column),
+			userID, *id, input.Level).Scan(&input.ID, &input.CreatedAt, &input.UpdatedAt)
+	}
+	if err != nil {
+		subscriptionErrorResponse(c, ctx, err, "update subscription", userID)
+		return
+	}
+	if input.PostID != nil {
+		input.PostTitle = name
+	} else {
+		input.TopicName = name
+	}
+	c.JSON(http.StatusOK, input)
+}
+
+// DeleteSubscription removes a subscription, returning the post or topic to
+// the default: direct replies and mentions only.
+func (h *ForumHandler) DeleteSubscription(c *gin.Context) {
+	subscriptionID, err := strconv.ParseInt(c.Param("subscription_id"), 10, 64)
+	if err != nil || subscriptionID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid subscription ID"})
+		return
+	}
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+	res, err := h.DB.ExecContext(ctx, `DELETE FROM subscriptions WHERE id = $1 AND user_id = $2`, subscriptionID, userID)
+	if err == nil {
+		if n, _ := res.RowsAffected(); n == 0 {
+			err = sql.ErrNoRows
+		}
+	}
+	if err != nil {
+		subscriptionErrorResponse(c, ctx, err, "delete subscription", userID)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/v1-nce/threadtalk-backend/internal/models"
+)
+
+const (
+	maxTagsPerPost = 5
+	maxTagLength   = 30
+	maxFilterTags  = 10
+)
+
+var tagPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+func normalizeTags(raw []string) ([]string, error) {
+	tags, err := parseTags(raw)
+	if err != nil {
+		return nil, err
+	}
+	if len(tags) > maxTagsPerPost {
+		return nil, fmt.Errorf("a post can have at most %d tags", maxTagsPerPost)
+	}
+	return tags, nil
+}
+
+// parseTags lowercases tags, strips a leading "#" and drops blanks and
+// duplicates.
+func parseTags(raw []string) ([]string, error) {
+	seen := make(map[string]bool, len(raw))
+	tags := make([]string, 0, len(raw))
+	for _, t := range raw {
+		t = strings.ToLower(strings.TrimSpace(t))
+		t = strings.TrimPrefix(t, "#")
+		if t == "" || seen[t] {
+			continue
+		}
+		if len(t) > maxTagLength || !tagPattern.MatchString(t) {
+			return nil, fmt.Errorf("invalid tag %q: use up to %d lowercase letters, digits or hyphens", t, maxTagLength)
+		}
+		seen[t] = true
+		tags = append(tags, t)
+	}
+	return tags, nil
+}
+
+func splitTags(joined string) []string {
+	if joined == "" {
+		return []string{}
+	}
+	return strings.Split(joined, ",")
+}
+
+// tagFilter reads repeated or comma-separated tag query params and builds a
+// condition on posts aliased as "p". tag_mode=or matches any tag; the default
+// requires every tag.
+func tagFilter(c *gin.Context, argPos int) (string, []interface{}, error) {
+	var raw []string
+	for _, v := range c.QueryArray("tag") {
+		raw = append(raw, strings.Split(v, ",")...)
+	}
+	if len(raw) == 0 {
+		return "", nil, nil
+	}
+	tags, err := parseTags(raw)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(tags) == 0 {
+		return "", nil, nil
+	}
+	if len(tags) > maxFilterTags {
+		return "", nil, fmt.Errorf("filter by at most %d tags", maxFilterTags)
+	}
+	switch strings.ToLower(c.DefaultQuery("tag_mode", "and")) {
+	case "or":
+		return fmt.Sprintf(` AND EXISTS (SELECT 1 FROM post_tags pt JOIN tags t ON t.id = pt.tag_id WHERE pt.post_id = p.id AND t.name = ANY($%d))`, argPos),
+			[]interface{}{tags}, nil
+	case "and":
+		return fmt.Sprintf(` AND p.id IN (SELECT pt.post_id FROM post_tags pt JOIN tags t ON t.id = pt.tag_id WHERE t.name = ANY($%d) GROUP BY pt.post_id HAVING COUNT(*) = $%d)`, argPos, argPos+1),
+			[]interface{}{tags, len(tags)}, nil
+	default:
+		return "", nil, fmt.Errorf("tag_mode must be 'and' or 'or'")
+	}
+}
+
+func (h *ForumHandler) GetTags(c *gin.Context) {
+	prefix := strings.ToLower(strings.TrimSpace(c.Query("prefix")))
+	if len(prefix) > maxTagLength || (prefix != "" && !tagPattern.MatchString(prefix)) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid prefix parameter"})
+		return
+	}
+	limit := 50
+	if limitStr := c.Query("limit"); limitStr != "" {
+		l, err := strconv.Atoi(limitStr)
+		if err != nil || l <= 0 || l > 100 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit parameter"})
+			return
+		}
+		limit = l
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+	query := `SELECT t.name, COUNT(p.id)
+	FROM tags t
+	JOIN post_tags pt ON pt.tag_id = t.id
+	JOIN posts p ON p.id = pt.post_id AND p.deleted_at IS NULL
+	WHERE t.name LIKE $1
+	GROUP BY t.id, t.name
+	ORDER BY COUNT(p.id) DESC, t.name ASC
+	LIMIT $2`
+	rows, err := h.DB.QueryContext(ctx, query, prefix+"%", limit)
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			log.Printf("WARN: Request timeout fetching tags")
+			c.JSON(http.StatusRequestTimeout, gin.H{"error": "Request timeout"})
+		} else {
+			log.Printf("ERROR: Failed to fetch tags: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch tags"})
+		}
+		return
+	}
+	defer rows.Close()
+	tags := make([]models.Tag, 0)
+	for rows.Next() {
+		var t models.Tag
+		if err := rows.Scan(&t.Name, &t.PostCount); err != nil {
+			log.Printf("ERROR: Failed to scan tag row: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch tags"})
+			return
+		}
+		tags = append(tags, t)
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("ERROR: Error iterating tags: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch tags"})
+		return
+	}
+	c.JSON(http.StatusOK, tags)
+}
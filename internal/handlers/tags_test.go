@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestNormalizeTags(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     []string
+		want    []string
+		wantErr string
+	}{
+		{"empty", nil, []string{}, ""},
+		{"cleaned", []string{" Go ", "#web", "go", "", "GO"}, []string{"go", "web"}, ""},
+		{"hyphens and digits", []string{"web-3", "k8s"}, []string{"web-3", "k8s"}, ""},
+		{"at the limit", []string{"a", "b", "c", "d", "e", "a"}, []string{"a", "b", "c", "d", "e"}, ""},
+		{"over the limit", []string{"a", "b", "c", "d", "e", "f"}, nil, "a post can have at most 5 tags"},
+		{"leading hyphen", []string{"-go"}, nil, `invalid tag "-go"`},
+		{"space inside", []string{"go lang"}, nil, `invalid tag "go lang"`},
+		{"non-ASCII", []string{"café"}, nil, `invalid tag "café"`},
+		{"too long", []string{strings.Repeat("a", maxTagLength+1)}, nil, "invalid tag"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := normalizeTags(tt.raw)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("normalizeTags(%q) error = %v, want %q", tt.raw, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("normalizeTags(%q) = %q, %v; want %q", tt.raw, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestTagFilter(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		wantSQL  string
+		wantArgs []interface{}
+		wantErr  string
+	}{
+		{"no tags", "", "", nil, ""},
+		{"blank tags", "tag=,", "", nil, ""},
+		{"all tags by default", "tag=Go,web&tag=go", "HAVING COUNT(*) = $3", []interface{}{[]string{"go", "web"}, 2}, ""},
+		{"any tag", "tag=go&tag=web&tag_mode=OR", "t.name = ANY($2)", []interface{}{[]string{"go", "web"}}, ""},
+		{"bad mode", "tag=go&tag_mode=xor", "", nil, "tag_mode must be"},
+		{"invalid tag", "tag=go%20lang", "", nil, "invalid tag"},
+		{"more than a post can have", "tag=a,b,c,d,e,f,g", "HAVING", []interface{}{[]string{"a", "b", "c", "d", "e", "f", "g"}, 7}, ""},
+		{"too many", "tag=a,b,c,d,e,f,g,h,i,j,k", "", nil, "filter by at most 10 tags"},
+	}
+	gin.SetMode(gin.TestMode)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest("GET", "/posts?"+tt.query, nil)
+			sql, args, err := tagFilter(c, 2)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("tagFilter: %v", err)
+			}
+			if !strings.Contains(sql, tt.wantSQL) || (tt.wantSQL == "" && sql != "") {
+				t.Errorf("sql = %q, want it to contain %q", sql, tt.wantSQL)
+			}
+			if !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("args = %#v, want %#v", args, tt.wantArgs)
+			}
+		})
+	}
+}
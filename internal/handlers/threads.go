@@ -0,0 +1,323 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/v1-nce/threadtalk-backend/internal/markdown"
+	"github.com/v1-nce/threadtalk-backend/internal/models"
+)
+
+var (
+	errPostNotFound    = errors.New("post not found")
+	errCommentNotFound = errors.New("comment not found")
+	errTopicNotFound   = errors.New("topic not found")
+	errTopicArchived   = errors.New("topic is archived")
+	errPostLocked      = errors.New("post is locked")
+	errSamePost        = errors.New("source and target are the same post")
+	errSameTopic       = errors.New("post is already in this topic")
+)
+
+type threadPost struct {
+	ID       int64
+	Title    string
+	Content  string
+	UserID   int64
+	TopicID  int64
+	Redirect bool
+	Locked   bool
+}
+
+// lockThreadPost loads a live, non-redirect post and locks its row for the
+// rest of the transaction.
+func lockThreadPost(ctx context.Context, tx *sql.Tx, postID int64) (*threadPost, error) {
+	p := &threadPost{ID: postID}
+	err := tx.QueryRowContext(ctx, `
+		SELECT title, content, user_id, topic_id, redirect_post_id IS NOT NULL, locked_at IS NOT NULL
+		FROM posts
+		WHERE id = $1 AND deleted_at IS NULL
+		FOR UPDATE`, postID).Scan(&p.Title, &p.Content, &p.UserID, &p.TopicID, &p.Redirect, &p.Locked)
+	if err == sql.ErrNoRows || (err == nil && p.Redirect) {
+		return nil, errPostNotFound
+	}
+	return p, err
+}
+
+// checkWritableTopic fails unless the topic exists and is not archived. It
+// share-locks the topic so that it cannot be archived before the transaction
+// commits.
+func checkWritableTopic(ctx context.Context, tx *sql.Tx, topicID int64) error {
+	var archived bool
+	err := tx.QueryRowContext(ctx, `SELECT archived_at IS NOT NULL FROM topics WHERE id = $1 FOR SHARE`, topicID).Scan(&archived)
+	if err == sql.ErrNoRows {
+		return errTopicNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if archived {
+		return errTopicArchived
+	}
+	return nil
+}
+
+func threadErrorResponse(c *gin.Context, ctx context.Context, err error, action string) {
+	switch {
+	case ctx.Err() == context.DeadlineExceeded:
+		log.Printf("WARN: Request timeout during %s", action)
+		c.JSON(http.StatusRequestTimeout, gin.H{"error": "Request timeout"})
+	case errors.Is(err, errPostNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Post not found"})
+	case errors.Is(err, errCommentNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Comment not found"})
+	case errors.Is(err, errTopicNotFound):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid topic ID"})
+	case errors.Is(err, errTopicArchived):
+		c.JSON(http.StatusLocked, gin.H{"error": "This topic is archived"})
+	case errors.Is(err, errPostLocked):
+		c.JSON(http.StatusLocked, gin.H{"error": "This thread is locked"})
+	case errors.Is(err, errSamePost), errors.Is(err, errSameTopic):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		log.Printf("ERROR: Failed %s: %v", action, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed %s", action)})
+	}
+}
+
+// MovePost moves a post and its comments to another topic, leaving a locked
+// redirect post in the original topic.
+func (h *ForumHandler) MovePost(c *gin.Context) {
+	postID, err := strconv.ParseInt(c.Param("post_id"), 10, 64)
+	if err != nil || postID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid post ID"})
+		return
+	}
+	var input models.MovePostInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+	var redirectID, authorID int64
+	err = h.withTx(ctx, func(tx *sql.Tx) error {
+		post, err := lockThreadPost(ctx, tx, postID)
+		if err != nil {
+			return err
+		}
+		authorID = post.UserID
+		if post.TopicID == input.TopicID {
+			return errSameTopic
+		}
+		if err := checkWritableTopic(ctx, tx, input.TopicID); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `UPDATE posts SET topic_id = $1 WHERE id = $2`, input.TopicID, postID); err != nil {
+			return err
+		}
+		return tx.QueryRowContext(ctx, `
+			INSERT INTO posts (title, content, user_id, topic_id, locked_at, redirect_post_id)
+			VALUES ($1, '', $2, $3, CURRENT_TIMESTAMP, $4)
+			RETURNING id`, post.Title, post.UserID, post.TopicID, postID).Scan(&redirectID)
+	})
+	if err != nil {
+		threadErrorResponse(c, ctx, err, "to move post")
+		return
+	}
+	log.Printf("INFO: Moderator %d moved post %d to topic %d (redirect %d)", userID, postID, input.TopicID, redirectID)
+	h.notifyModeration(ctx, "moved", userID, authorID, &postID, nil)
+	c.JSON(http.StatusOK, gin.H{
+		"post_id":          strconv.FormatInt(postID, 10),
+		"topic_id":         strconv.FormatInt(input.TopicID, 10),
+		"redirect_post_id": strconv.FormatInt(redirectID, 10),
+	})
+}
+
+// MergePost folds the source post into the target. The source body becomes a
+// root comment on the target, the source's root comments are re-parented under
+// it, and the source is left locked as a redirect to the target.
+func (h *ForumHandler) MergePost(c *gin.Context) {
+	sourceID, err := strconv.ParseInt(c.Param("post_id"), 10, 64)
+	if err != nil || sourceID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid post ID"})
+		return
+	}
+	var input models.MergePostInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	if sourceID == input.TargetPostID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": errSamePost.Error()})
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+	var movedComments, authorID int64
+	err = h.withTx(ctx, func(tx *sql.Tx) error {
+		// Lock both rows in ID order so concurrent merges cannot deadlock.
+		first, second := sourceID, input.TargetPostID
+		if first > second {
+			first, second = second, first
+		}
+		locked := make(map[int64]*threadPost, 2)
+		for _, id := range []int64{first, second} {
+			p, err := lockThreadPost(ctx, tx, id)
+			if err != nil {
+				return err
+			}
+			locked[id] = p
+		}
+		source, target := locked[sourceID], locked[input.TargetPostID]
+		authorID = source.UserID
+		if target.Locked {
+			return errPostLocked
+		}
+		if err := checkWritableTopic(ctx, tx, target.TopicID); err != nil {
+			return err
+		}
+		var bodyID int64
+		body := fmt.Sprintf("**%s**\n\n%s", source.Title, source.Content)
+		if err := tx.QueryRowContext(ctx, `
+			INSERT INTO comments (content, user_id, post_id, parent_id, created_at)
+			SELECT $1, $2, $3, NULL, created_at FROM posts WHERE id = $4
+			RETURNING id`, body, source.UserID, target.ID, source.ID).Scan(&bodyID); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE comments SET parent_id = $1 WHERE post_id = $2 AND parent_id IS NULL`, bodyID, source.ID); err != nil {
+			return err
+		}
+		res, err := tx.ExecContext(ctx, `UPDATE comments SET post_id = $1 WHERE post_id = $2`, target.ID, source.ID)
+		if err != nil {
+			return err
+		}
+		movedComments, _ = res.RowsAffected()
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO post_tags (post_id, tag_id)
+			SELECT $1, tag_id FROM post_tags WHERE post_id = $2
+			ON CONFLICT DO NOTHING`, target.ID, source.ID); err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `
+			UPDATE posts SET redirect_post_id = $1, locked_at = COALESCE(locked_at, CURRENT_TIMESTAMP), pinned_at = NULL, accepted_comment_id = NULL
+			WHERE id = $2`, target.ID, source.ID)
+		return err
+	})
+	if err != nil {
+		threadErrorResponse(c, ctx, err, "to merge posts")
+		return
+	}
+	log.Printf("INFO: Moderator %d merged post %d into post %d (%d comments)", userID, sourceID, input.TargetPostID, movedComments)
+	h.notifyModeration(ctx, "merged", userID, authorID, &input.TargetPostID, nil)
+	c.JSON(http.StatusOK, gin.H{
+		"post_id":          strconv.FormatInt(sourceID, 10),
+		"redirect_post_id": strconv.FormatInt(input.TargetPostID, 10),
+		"moved_comments":   movedComments,
+	})
+}
+
+// SplitComment moves a comment and all of its replies into a new post. A
+// placeholder comment pointing at the new post is left where the subtree was.
+func (h *ForumHandler) SplitComment(c *gin.Context) {
+	commentID, err := strconv.ParseInt(c.Param("comment_id"), 10, 64)
+	if err != nil || commentID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid comment ID"})
+		return
+	}
+	var input models.SplitCommentInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+	var newPost models.Post
+	var movedComments int64
+	err = h.withTx(ctx, func(tx *sql.Tx) error {
+		var sourcePostID, authorID int64
+		var parentID *int64
+		var createdAt time.Time
+		// Locking the comment keeps it from being edited, deleted or moved
+		// while its subtree is split off.
+		err := tx.QueryRowContext(ctx, `
+			SELECT post_id, user_id, parent_id, created_at FROM comments
+			WHERE id = $1 AND redirect_post_id IS NULL AND deleted_at IS NULL
+			FOR UPDATE`, commentID).Scan(&sourcePostID, &authorID, &parentID, &createdAt)
+		if err == sql.ErrNoRows {
+			return errCommentNotFound
+		}
+		if err != nil {
+			return err
+		}
+		source, err := lockThreadPost(ctx, tx, sourcePostID)
+		if err != nil {
+			return err
+		}
+		topicID := input.TopicID
+		if topicID == 0 {
+			topicID = source.TopicID
+		}
+		if err := checkWritableTopic(ctx, tx, topicID); err != nil {
+			return err
+		}
+		newPost = models.Post{Title: input.Title, Content: input.Content, UserID: authorID, TopicID: topicID, Tags: []string{}}
+		if err := tx.QueryRowContext(ctx,
+			`INSERT INTO posts (title, content, user_id, topic_id) VALUES ($1, $2, $3, $4) RETURNING id, created_at`,
+			newPost.Title, newPost.Content, newPost.UserID, newPost.TopicID).Scan(&newPost.ID, &newPost.CreatedAt); err != nil {
+			return err
+		}
+		res, err := tx.ExecContext(ctx, `
+			WITH RECURSIVE subtree AS (
+				SELECT id FROM comments WHERE id = $1
+				UNION ALL
+				SELECT c.id FROM comments c JOIN subtree s ON c.parent_id = s.id
+			)
+			UPDATE comments SET post_id = $2 WHERE id IN (SELECT id FROM subtree)`, commentID, newPost.ID)
+		if err != nil {
+			return err
+		}
+		movedComments, _ = res.RowsAffected()
+		if _, err := tx.ExecContext(ctx, `UPDATE comments SET parent_id = NULL WHERE id = $1`, commentID); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE posts SET accepted_comment_id = NULL WHERE id = $1 AND accepted_comment_id = $2`, sourcePostID, commentID); err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO comments (content, user_id, post_id, parent_id, created_at, redirect_post_id)
+			VALUES ('[moved]', $1, $2, $3, $4, $5)`, userID, sourcePostID, parentID, createdAt, newPost.ID)
+		return err
+	})
+	if err != nil {
+		threadErrorResponse(c, ctx, err, "to split comment")
+		return
+	}
+	log.Printf("INFO: Moderator %d split comment %d into post %d (%d comments)", userID, commentID, newPost.ID, movedComments)
+	h.notifyModeration(ctx, "split", userID, newPost.UserID, &newPost.ID, &commentID)
+	newPost.ContentHTML = markdown.Render(newPost.Content)
+	c.JSON(http.StatusCreated, gin.H{
+		"post":           newPost,
+		"moved_comments": movedComments,
+	})
+}
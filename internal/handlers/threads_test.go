@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/v1-nce/threadtalk-backend/internal/dbtest"
+)
+
+// callHandler runs handler as user 1 with a JSON body and path params given
+// as name, value pairs, and returns the status and decoded body.
+func callHandler(t *testing.T, handler gin.HandlerFunc, body string, params ...string) (int, map[string]interface{}) {
+	t.Helper()
+	return callHandlerAt(t, handler, "/", body, params...)
+}
+
+// callHandlerAt is callHandler with a request target, for query parameters.
+func callHandlerAt(t *testing.T, handler gin.HandlerFunc, target, body string, params ...string) (int, map[string]interface{}) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	for i := 0; i+1 < len(params); i += 2 {
+		c.Params = append(c.Params, gin.Param{Key: params[i], Value: params[i+1]})
+	}
+	c.Set("userID", int64(1))
+	handler(c)
+	c.Writer.WriteHeaderNow()
+	var resp map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	return w.Code, resp
+}
+
+// threadPostRow answers lockThreadPost for a post in topicID.
+func threadPostRow(topicID int64, redirect, locked bool) dbtest.Reply {
+	return dbtest.Row("redirect_post_id IS NOT NULL, locked_at IS NOT NULL", "Title", "Body", int64(2), topicID, redirect, locked)
+}
+
+func TestMergePostIntoItself(t *testing.T) {
+	// Rejected before any query, so the handler needs no database.
+	h := &ForumHandler{}
+	status, resp := callHandler(t, h.MergePost, `{"target_post_id": "5"}`, "post_id", "5")
+	if status != http.StatusBadRequest || resp["error"] != errSamePost.Error() {
+		t.Errorf("merge into itself = %d %v, want 400 %q", status, resp, errSamePost.Error())
+	}
+}
+
+func TestMergePostIntoRedirect(t *testing.T) {
+	db, f := dbtest.New(t,
+		threadPostRow(1, false, false), // source 5
+		threadPostRow(1, true, true),   // target 9 is a redirect left by a move
+	)
+	h := &ForumHandler{DB: db}
+	status, resp := callHandler(t, h.MergePost, `{"target_post_id": "9"}`, "post_id", "5")
+	if status != http.StatusNotFound || resp["error"] != "Post not found" {
+		t.Errorf("merge into redirect = %d %v, want 404 Post not found", status, resp)
+	}
+	if f.Executed("INSERT INTO comments") || f.Executed("UPDATE posts SET redirect_post_id") {
+		t.Error("merge into a redirect changed data")
+	}
+}
+
+func TestMergePostIntoLockedThread(t *testing.T) {
+	db, f := dbtest.New(t,
+		threadPostRow(1, false, false),
+		threadPostRow(1, false, true),
+	)
+	h := &ForumHandler{DB: db}
+	status, _ := callHandler(t, h.MergePost, `{"target_post_id": "9"}`, "post_id", "5")
+	if status != http.StatusLocked {
+		t.Errorf("merge into locked thread = %d, want 423", status)
+	}
+	if f.Executed("INSERT INTO comments") {
+		t.Error("merge into a locked thread changed data")
+	}
+}
+
+func TestMovePostIntoArchivedTopic(t *testing.T) {
+	db, f := dbtest.New(t,
+		threadPostRow(1, false, false),
+		dbtest.Row("FROM topics WHERE id = $1 FOR SHARE", true),
+	)
+	h := &ForumHandler{DB: db}
+	status, resp := callHandler(t, h.MovePost, `{"topic_id": "2"}`, "post_id", "5")
+	if status != http.StatusLocked || resp["error"] != "This topic is archived" {
+		t.Errorf("move into archived topic = %d %v, want 423", status, resp)
+	}
+	if f.Executed("UPDATE posts SET topic_id") {
+		t.Error("move into an archived topic changed data")
+	}
+}
+
+func TestMovePostIntoSameTopic(t *testing.T) {
+	db, _ := dbtest.New(t, threadPostRow(2, false, false))
+	h := &ForumHandler{DB: db}
+	status, resp := callHandler(t, h.MovePost, `{"topic_id": "2"}`, "post_id", "5")
+	if status != http.StatusBadRequest || resp["error"] != errSameTopic.Error() {
+		t.Errorf("move into same topic = %d %v, want 400", status, resp)
+	}
+}
+
+func TestSplitRootComment(t *testing.T) {
+	// Comment 7 is the root of a reply chain: it has no parent, so both the
+	// split-off comment and the placeholder left behind are roots.
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	db, f := dbtest.New(t,
+		dbtest.Row("SELECT post_id, user_id, parent_id, created_at FROM comments", int64(10), int64(3), nil, created),
+		threadPostRow(1, false, false),
+		dbtest.Row("FROM topics WHERE id = $1 FOR SHARE", false),
+		dbtest.Row("INSERT INTO posts", int64(20), created),
+		dbtest.Reply{Match: "WITH RECURSIVE subtree", Affected: 4},
+	)
+	h := &ForumHandler{DB: db}
+	status, resp := callHandler(t, h.SplitComment, `{"title": "Split off", "content": "Body"}`, "comment_id", "7")
+	if status != http.StatusCreated {
+		t.Fatalf("split root comment = %d %v, want 201", status, resp)
+	}
+	if resp["moved_comments"] != float64(4) {
+		t.Errorf("moved_comments = %v, want 4", resp["moved_comments"])
+	}
+	post, _ := resp["post"].(map[string]interface{})
+	if post["id"] != "20" || post["topic_id"] != "1" || post["user_id"] != "3" {
+		t.Errorf("new post = %v, want post 20 in topic 1 by user 3", post)
+	}
+	for _, stmt := range []string{"UPDATE comments SET parent_id = NULL WHERE id = $1", "'[moved]'"} {
+		if !f.Executed(stmt) {
+			t.Errorf("split did not run %q", stmt)
+		}
+	}
+}
+
+func TestSplitDeletedComment(t *testing.T) {
+	db, f := dbtest.New(t) // the comment lookup finds nothing
+	h := &ForumHandler{DB: db}
+	status, _ := callHandler(t, h.SplitComment, `{"title": "Split off", "content": "Body"}`, "comment_id", "7")
+	if status != http.StatusNotFound {
+		t.Errorf("split missing comment = %d, want 404", status)
+	}
+	if f.Executed("INSERT INTO posts") {
+		t.Error("split of a missing comment created a post")
+	}
+}
@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/v1-nce/threadtalk-backend/internal/models"
+)
+
+const maxSlugLength = 50
+
+var (
+	slugInvalidChars = regexp.MustCompile(`[^a-z0-9]+`)
+	numericSlug      = regexp.MustCompile(`^[0-9]+$`)
+)
+
+// slugify derives a URL slug from a topic or category name. Slugs never look
+// like numeric IDs so /topics/:topic_id can accept either form.
+func slugify(name string) string {
+	slug := slugInvalidChars.ReplaceAllString(strings.ToLower(name), "-")
+	slug = strings.Trim(slug, "-")
+	if len(slug) > maxSlugLength {
+		slug = strings.TrimRight(slug[:maxSlugLength], "-")
+	}
+	if slug == "" || numericSlug.MatchString(slug) {
+		slug = "t-" + slug
+	}
+	return strings.TrimRight(slug, "-")
+}
+
+var errSlugTaken = errors.New("no free slug available")
+
+// insertWithUniqueSlug calls insert with base, then base-2 up to base-10, then
+// base with a random suffix, since names without ASCII letters all reduce to
+// the same slug. insert must use ON CONFLICT (slug) DO NOTHING RETURNING so
+// that a taken slug surfaces as sql.ErrNoRows without aborting an enclosing
+// transaction.
+func insertWithUniqueSlug(base string, insert func(slug string) error) error {
+	for attempt := 1; attempt <= 10; attempt++ {
+		slug := base
+		if attempt > 1 {
+			slug = fmt.Sprintf("%s-%d", base, attempt)
+		}
+		if err := insert(slug); err != sql.ErrNoRows {
+			return err
+		}
+	}
+	for attempt := 0; attempt < 3; attempt++ {
+		suffix := make([]byte, 4)
+		if _, err := rand.Read(suffix); err != nil {
+			return err
+		}
+		if err := insert(base + "-" + hex.EncodeToString(suffix)); err != sql.ErrNoRows {
+			return err
+		}
+	}
+	return errSlugTaken
+}
+
+const topicSelect = `SELECT t.id, t.name, t.slug, COALESCE(t.description, ''), t.kind, t.category_id, t.position, t.archived_at, t.created_at,
+		(SELECT COUNT(*) FROM posts p WHERE p.topic_id = t.id AND p.deleted_at IS NULL AND p.redirect_post_id IS NULL),
+		(SELECT MAX(GREATEST(p.created_at, p.last_comment_at)) FROM posts p WHERE p.topic_id = t.id AND p.deleted_at IS NULL)
+	FROM topics t`
+
+func scanTopic(row rowScanner, t *models.Topic) error {
+	return row.Scan(&t.ID, &t.Name, &t.Slug, &t.Description, &t.Kind, &t.CategoryID, &t.Position, &t.ArchivedAt, &t.CreatedAt, &t.PostCount, &t.LastActivityAt)
+}
+
+// resolveTopicID accepts either a numeric topic ID or a slug.
+func (h *ForumHandler) resolveTopicID(ctx context.Context, param string) (int64, error) {
+	if id, err := strconv.ParseInt(param, 10, 64); err == nil {
+		if id <= 0 {
+			return 0, sql.ErrNoRows
+		}
+		return id, nil
+	}
+	var id int64
+	err := h.DB.QueryRowContext(ctx, `SELECT id FROM topics WHERE slug = $1`, strings.ToLower(param)).Scan(&id)
+	return id, err
+}
+
+func (h *ForumHandler) GetTopic(c *gin.Context) {
+	param := c.Param("topic_id")
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+	var t models.Topic
+	var err error
+	if id, parseErr := strconv.ParseInt(param, 10, 64); parseErr == nil {
+		err = scanTopic(h.DB.QueryRowContext(ctx, topicSelect+` WHERE t.id = $1`, id), &t)
+	} else {
+		err = scanTopic(h.DB.QueryRowContext(ctx, topicSelect+` WHERE t.slug = $1`, strings.ToLower(param)), &t)
+	}
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			log.Printf("WARN: Request timeout fetching topic %s", param)
+			c.JSON(http.StatusRequestTimeout, gin.H{"error": "Request timeout"})
+		} else if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Topic not found"})
+		} else {
+			log.Printf("ERROR: Failed to fetch topic %s: %v", param, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch topic"})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, t)
+}
+
+func (h *ForumHandler) UpdateTopic(c *gin.Context) {
+	topicID, err := strconv.ParseInt(c.Param("topic_id"), 10, 64)
+	if err != nil || topicID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid topic ID"})
+		return
+	}
+	var input models.TopicUpdate
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+	var sets []string
+	var args []interface{}
+	add := func(column string, value interface{}) {
+		args = append(args, value)
+		sets = append(sets, fmt.Sprintf("%s = $%d", column, len(args)))
+	}
+	if input.Name != nil {
+		add("name", strings.TrimSpace(*input.Name))
+	}
+	if input.Description != nil {
+		add("description", *input.Description)
+	}
+	if input.Kind != nil {
+		add("kind", *input.Kind)
+	}
+	if input.CategoryID != nil {
+		if *input.CategoryID == 0 {
+			sets = append(sets, "category_id = NULL")
+		} else {
+			add("category_id", *input.CategoryID)
+		}
+	}
+	if input.Position != nil {
+		add("position", *input.Position)
+	}
+	if input.Archived != nil {
+		if *input.Archived {
+			sets = append(sets, "archived_at = COALESCE(archived_at, CURRENT_TIMESTAMP)")
+		} else {
+			sets = append(sets, "archived_at = NULL")
+		}
+	}
+	if len(sets) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No fields to update"})
+		return
+	}
+	args = append(args, topicID)
+	query := fmt.Sprintf(`UPDATE topics SET %s WHERE id = $%d`, strings.Join(sets, ", "), len(args))
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+	res, err := h.DB.ExecContext(ctx, query, args...)
+	if err == nil {
+		if n, _ := res.RowsAffected(); n == 0 {
+			err = sql.ErrNoRows
+		}
+	}
+	var t models.Topic
+	if err == nil {
+		err = scanTopic(h.DB.QueryRowContext(ctx, topicSelect+` WHERE t.id = $1`, topicID), &t)
+	}
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			log.Printf("WARN: Request timeout updating topic %d", topicID)
+			c.JSON(http.StatusRequestTimeout, gin.H{"error": "Request timeout"})
+		} else if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Topic not found"})
+		} else if isPgError(err, "23505") {
+			c.JSON(http.StatusConflict, gin.H{"error": "Topic name already exists"})
+		} else if isPgError(err, "23503") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid category ID"})
+		} else {
+			log.Printf("ERROR: Failed to update topic %d: %v", topicID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update topic"})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, t)
+}
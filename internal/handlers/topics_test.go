@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestSlugify(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"Go Programming", "go-programming"},
+		{"  C++ & Rust!  ", "c-rust"},
+		{"already-a-slug", "already-a-slug"},
+		{"Café Talk", "caf-talk"},
+		{"2024", "t-2024"},
+		{"42 Answers", "42-answers"},
+		{"日本語", "t"},
+		{"!!!", "t"},
+		{"", "t"},
+		{strings.Repeat("a", 49) + " b", strings.Repeat("a", 49)},
+		{strings.Repeat("ab ", 30), strings.Repeat("ab-", 16) + "ab"},
+		{strings.Repeat("a", 50) + "-b", strings.Repeat("a", 50)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := slugify(tt.name)
+			if got != tt.want {
+				t.Errorf("slugify(%q) = %q, want %q", tt.name, got, tt.want)
+			}
+			if len(got) > maxSlugLength || strings.HasSuffix(got, "-") || numericSlug.MatchString(got) {
+				t.Errorf("slugify(%q) = %q is not a valid slug", tt.name, got)
+			}
+		})
+	}
+}
+
+func TestInsertWithUniqueSlug(t *testing.T) {
+	taken := func(n int) func(string) bool {
+		return func(string) bool {
+			n--
+			return n >= 0
+		}
+	}
+	randomSuffix := regexp.MustCompile(`^t-[0-9a-f]{8}$`)
+	tests := []struct {
+		name    string
+		isTaken func(string) bool
+		check   func(t *testing.T, slug string)
+		wantErr error
+	}{
+		{"free", taken(0), func(t *testing.T, slug string) {
+			if slug != "t" {
+				t.Errorf("slug = %q, want t", slug)
+			}
+		}, nil},
+		{"numbered", taken(2), func(t *testing.T, slug string) {
+			if slug != "t-3" {
+				t.Errorf("slug = %q, want t-3", slug)
+			}
+		}, nil},
+		{"random after numbers run out", taken(10), func(t *testing.T, slug string) {
+			if !randomSuffix.MatchString(slug) {
+				t.Errorf("slug = %q, want t-<8 hex digits>", slug)
+			}
+		}, nil},
+		{"gives up", func(string) bool { return true }, nil, errSlugTaken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			err := insertWithUniqueSlug("t", func(slug string) error {
+				if tt.isTaken(slug) {
+					return sql.ErrNoRows
+				}
+				got = slug
+				return nil
+			})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.check != nil {
+				tt.check(t, got)
+			}
+		})
+	}
+}
+
+func TestInsertWithUniqueSlugStopsOnOtherErrors(t *testing.T) {
+	boom := errors.New("boom")
+	calls := 0
+	err := insertWithUniqueSlug("t", func(string) error {
+		calls++
+		return boom
+	})
+	if err != boom || calls != 1 {
+		t.Errorf("err = %v after %d calls, want boom after 1", err, calls)
+	}
+}
@@ -0,0 +1,328 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/v1-nce/threadtalk-backend/internal/models"
+)
+
+const webhookDeliveryPageSize = 50
+
+const webhookSelect = `SELECT id, url, description, array_to_string(events, ','), active, created_at, updated_at FROM webhooks`
+
+func webhookErrorResponse(c *gin.Context, ctx context.Context, err error, action string, webhookID int64) {
+	switch {
+	case ctx.Err() == context.DeadlineExceeded:
+		log.Printf("WARN: Request timeout trying to %s for webhook %d", action, webhookID)
+		c.JSON(http.StatusRequestTimeout, gin.H{"error": "Request timeout"})
+	case err == sql.ErrNoRows:
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+	default:
+		log.Printf("ERROR: Failed to %s for webhook %d: %v", action, webhookID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to " + action})
+	}
+}
+
+func scanWebhook(row interface{ Scan(...interface{}) error }, w *models.Webhook) error {
+	var events string
+	if err := row.Scan(&w.ID, &w.URL, &w.Description, &events, &w.Active, &w.CreatedAt, &w.UpdatedAt); err != nil {
+		return err
+	}
+	w.Events = strings.Split(events, ",")
+	return nil
+}
+
+// validWebhookURL accepts absolute http and https URLs only.
+func validWebhookURL(raw string) bool {
+	u, err := url.Parse(raw)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+func newWebhookSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func parseWebhookID(c *gin.Context) (int64, bool) {
+	webhookID, err := strconv.ParseInt(c.Param("webhook_id"), 10, 64)
+	if err != nil || webhookID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook ID"})
+		return 0, false
+	}
+	return webhookID, true
+}
+
+func (h *ForumHandler) GetWebhooks(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+	rows, err := h.DB.QueryContext(ctx, webhookSelect+` ORDER BY id`)
+	if err != nil {
+		webhookErrorResponse(c, ctx, err, "fetch webhooks", 0)
+		return
+	}
+	defer rows.Close()
+	hooks := make([]models.Webhook, 0)
+	for rows.Next() {
+		var w models.Webhook
+		if err := scanWebhook(rows, &w); err != nil {
+			webhookErrorResponse(c, ctx, err, "fetch webhooks", 0)
+			return
+		}
+		hooks = append(hooks, w)
+	}
+	if err := rows.Err(); err != nil {
+		webhookErrorResponse(c, ctx, err, "fetch webhooks", 0)
+		return
+	}
+	c.JSON(http.StatusOK, hooks)
+}
+
+// CreateWebhook registers an active webhook and returns its signing secret.
+// The secret is not shown again unless it is rotated.
+func (h *ForumHandler) CreateWebhook(c *gin.Context) {
+	var input models.Webhook
+	if err := c.ShouldBindJSON(&input); err != nil || !validWebhookURL(input.URL) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	secret, err := newWebhookSecret()
+	if err != nil {
+		log.Printf("ERROR: Failed to generate webhook secret: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create webhook"})
+		return
+	}
+	input.Events = dedupe(input.Events)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+	err = h.DB.QueryRowContext(ctx, `
+		INSERT INTO webhooks (url, description, secret, events, created_by) VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, active, created_at, updated_at`,
+		input.URL, input.Description, secret, input.Events, userID).
+		Scan(&input.ID, &input.Active, &input.CreatedAt, &input.UpdatedAt)
+	if err != nil {
+		webhookErrorResponse(c, ctx, err, "create webhook", 0)
+		return
+	}
+	input.Secret = secret
+	log.Printf("INFO: User %d created webhook %d for %s", userID, input.ID, input.URL)
+	c.JSON(http.StatusCreated, input)
+}
+
+// UpdateWebhook changes the given fields. rotate_secret replaces the signing
+// secret and returns the new one.
+func (h *ForumHandler) UpdateWebhook(c *gin.Context) {
+	webhookID, ok := parseWebhookID(c)
+	if !ok {
+		return
+	}
+	var input models.WebhookUpdate
+	if err := c.ShouldBindJSON(&input); err != nil || (input.URL != nil && !validWebhookURL(*input.URL)) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+	var sets []string
+	var args []interface{}
+	add := func(column string, value interface{}) {
+		args = append(args, value)
+		sets = append(sets, fmt.Sprintf("%s = $%d", column, len(args)))
+	}
+	if input.URL != nil {
+		add("url", *input.URL)
+	}
+	if input.Description != nil {
+		add("description", *input.Description)
+	}
+	if input.Events != nil {
+		add("events", dedupe(input.Events))
+	}
+	if input.Active != nil {
+		add("active", *input.Active)
+	}
+	var secret string
+	if input.RotateSecret {
+		var err error
+		if secret, err = newWebhookSecret(); err != nil {
+			log.Printf("ERROR: Failed to generate webhook secret: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update webhook"})
+			return
+		}
+		add("secret", secret)
+	}
+	if len(sets) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No fields to update"})
+		return
+	}
+	args = append(args, webhookID)
+	query := fmt.Sprintf(`UPDATE webhooks SET %s, updated_at = CURRENT_TIMESTAMP WHERE id = $%d
+		RETURNING id, url, description, array_to_string(events, ','), active, created_at, updated_at`, strings.Join(sets, ", "), len(args))
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+	var w models.Webhook
+	if err := scanWebhook(h.DB.QueryRowContext(ctx, query, args...), &w); err != nil {
+		webhookErrorResponse(c, ctx, err, "update webhook", webhookID)
+		return
+	}
+	w.Secret = secret
+	c.JSON(http.StatusOK, w)
+}
+
+// DeleteWebhook removes a webhook along with its queued deliveries and log.
+func (h *ForumHandler) DeleteWebhook(c *gin.Context) {
+	webhookID, ok := parseWebhookID(c)
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+	res, err := h.DB.ExecContext(ctx, `DELETE FROM webhooks WHERE id = $1`, webhookID)
+	if err == nil {
+		if n, _ := res.RowsAffected(); n == 0 {
+			err = sql.ErrNoRows
+		}
+	}
+	if err != nil {
+		webhookErrorResponse(c, ctx, err, "delete webhook", webhookID)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// GetWebhookDeliveries lists a webhook's deliveries, newest first.
+// status=pending, delivered or dead narrows the list.
+func (h *ForumHandler) GetWebhookDeliveries(c *gin.Context) {
+	webhookID, ok := parseWebhookID(c)
+	if !ok {
+		return
+	}
+	cursor, ok := parseCursor(c)
+	if !ok {
+		return
+	}
+	where := ` WHERE webhook_id = $1`
+	args := []interface{}{webhookID}
+	switch status := c.Query("status"); status {
+	case "":
+	case "pending", "delivered", "dead":
+		args = append(args, status)
+		where += fmt.Sprintf(` AND status = $%d`, len(args))
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status parameter"})
+		return
+	}
+	if cursor > 0 {
+		args = append(args, cursor)
+		where += fmt.Sprintf(` AND id < $%d`, len(args))
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+	var exists bool
+	err := h.DB.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM webhooks WHERE id = $1)`, webhookID).Scan(&exists)
+	if err == nil && !exists {
+		err = sql.ErrNoRows
+	}
+	if err != nil {
+		webhookErrorResponse(c, ctx, err, "fetch webhook deliveries", webhookID)
+		return
+	}
+	rows, err := h.DB.QueryContext(ctx, `
+		SELECT id, event, status, attempts, response_status, last_error,
+			CASE WHEN status = 'pending' THEN next_attempt_at END, delivered_at, created_at, payload
+		FROM webhook_deliveries`+
+		where+fmt.Sprintf(` ORDER BY id DESC LIMIT %d`, webhookDeliveryPageSize+1), args...)
+	if err != nil {
+		webhookErrorResponse(c, ctx, err, "fetch webhook deliveries", webhookID)
+		return
+	}
+	defer rows.Close()
+	deliveries := make([]models.WebhookDelivery, 0)
+	for rows.Next() {
+		var d models.WebhookDelivery
+		if err := rows.Scan(&d.ID, &d.Event, &d.Status, &d.Attempts, &d.ResponseStatus, &d.LastError,
+			&d.NextAttemptAt, &d.DeliveredAt, &d.CreatedAt, &d.Payload); err != nil {
+			webhookErrorResponse(c, ctx, err, "fetch webhook deliveries", webhookID)
+			return
+		}
+		deliveries = append(deliveries, d)
+	}
+	if err := rows.Err(); err != nil {
+		webhookErrorResponse(c, ctx, err, "fetch webhook deliveries", webhookID)
+		return
+	}
+	var nextCursor string
+	if len(deliveries) > webhookDeliveryPageSize {
+		nextCursor = strconv.FormatInt(deliveries[webhookDeliveryPageSize-1].ID, 10)
+		deliveries = deliveries[:webhookDeliveryPageSize]
+	}
+	c.JSON(http.StatusOK, gin.H{"data": deliveries, "next_cursor": nextCursor})
+}
+
+// RetryWebhookDelivery requeues a dead-lettered or pending delivery for
+// immediate delivery with a fresh set of attempts.
+func (h *ForumHandler) RetryWebhookDelivery(c *gin.Context) {
+	webhookID, ok := parseWebhookID(c)
+	if !ok {
+		return
+	}
+	deliveryID, err := strconv.ParseInt(c.Param("delivery_id"), 10, 64)
+	if err != nil || deliveryID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid delivery ID"})
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+	var status string
+	err = h.DB.QueryRowContext(ctx, `
+		WITH target AS (
+			SELECT id, status FROM webhook_deliveries WHERE id = $1 AND webhook_id = $2
+		), retried AS (
+			UPDATE webhook_deliveries d
+			SET status = 'pending', attempts = 0, next_attempt_at = CURRENT_TIMESTAMP
+			FROM target t
+			WHERE d.id = t.id AND t.status <> 'delivered'
+		)
+		SELECT status FROM target`, deliveryID, webhookID).Scan(&status)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Delivery not found"})
+		return
+	}
+	if err != nil {
+		webhookErrorResponse(c, ctx, err, "retry webhook delivery", webhookID)
+		return
+	}
+	if status == "delivered" {
+		c.JSON(http.StatusConflict, gin.H{"error": "Delivery already succeeded"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// dedupe drops repeated values, keeping the first occurrence of each.
+func dedupe(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	out := make([]string, 0, len(values))
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			out = append(out, v)
+		}
+	}
+	return out
+}
@@ -0,0 +1,418 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/v1-nce/threadtalk-backend/internal/realtime"
+	"golang.org/x/time/rate"
+)
+
+const (
+	defaultWSConnectionsPerUser = 5
+	wsMaxSubscriptions          = 50
+	wsMaxMessageBytes           = 4096
+	wsSendBuffer                = 16
+	wsWriteTimeout              = 10 * time.Second
+	wsPongTimeout               = 60 * time.Second
+	wsPingInterval              = 30 * time.Second
+	wsTypingInterval            = 3 * time.Second
+)
+
+var (
+	wsLimitOnce sync.Once
+	wsLimit     int
+)
+
+// wsConnectionsPerUser reads WS_MAX_CONNECTIONS_PER_USER once.
+func wsConnectionsPerUser() int {
+	wsLimitOnce.Do(func() {
+		wsLimit = defaultWSConnectionsPerUser
+		if s := os.Getenv("WS_MAX_CONNECTIONS_PER_USER"); s != "" {
+			if v, err := strconv.Atoi(s); err == nil && v > 0 {
+				wsLimit = v
+			} else {
+				log.Printf("WARN: Ignoring invalid WS_MAX_CONNECTIONS_PER_USER %q", s)
+			}
+		}
+	})
+	return wsLimit
+}
+
+var wsUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	CheckOrigin:     checkWSOrigin,
+}
+
+// checkWSOrigin only accepts browser connections from the frontend, since the
+// socket is authenticated by cookie. Clients that send no Origin (non-browser)
+// are allowed.
+func checkWSOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if frontend := os.Getenv("FRONTEND_URL"); frontend != "" && strings.EqualFold(origin, strings.TrimRight(frontend, "/")) {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
+
+type WebSocketHandler struct {
+	DB       *sql.DB
+	Hub      *realtime.Hub
+	Presence *realtime.Presence
+
+	mu    sync.Mutex
+	conns map[int64]int
+}
+
+type wsInbound struct {
+	Type   string `json:"type"`
+	Stream string `json:"stream"`
+	ID     int64  `json:"id,string"`
+}
+
+type wsOutbound struct {
+	Type     string          `json:"type"`
+	Stream   string          `json:"stream,omitempty"`
+	ID       int64           `json:"id,string,omitempty"`
+	Event    *realtime.Event `json:"event,omitempty"`
+	Username string          `json:"username,omitempty"`
+	Viewers  *int            `json:"viewers,omitempty"`
+	Error    string          `json:"error,omitempty"`
+}
+
+func (h *WebSocketHandler) acquire(userID int64) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.conns == nil {
+		h.conns = make(map[int64]int)
+	}
+	if h.conns[userID] >= wsConnectionsPerUser() {
+		return false
+	}
+	h.conns[userID]++
+	return true
+}
+
+func (h *WebSocketHandler) release(userID int64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.conns[userID]--; h.conns[userID] <= 0 {
+		delete(h.conns, userID)
+	}
+}
+
+// Serve upgrades to a WebSocket on which the client subscribes to posts and
+// topics, receives their events, and exchanges typing indicators and
+// presence counts for posts.
+func (h *WebSocketHandler) Serve(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	var username string
+	err := h.DB.QueryRowContext(ctx, `SELECT username FROM users WHERE id = $1`, userID).Scan(&username)
+	cancel()
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		} else {
+			log.Printf("ERROR: Failed to load user %d for websocket: %v", userID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to open websocket"})
+		}
+		return
+	}
+	if !h.acquire(userID) {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many open connections"})
+		return
+	}
+	defer h.release(userID)
+	sub, err := h.Hub.Subscribe()
+	if errors.Is(err, realtime.ErrTooManySubscribers) {
+		c.Header("Retry-After", "30")
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Too many live connections, try again later"})
+		return
+	}
+	conn, err := wsUpgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// The upgrader has already written an error response.
+		sub.Close()
+		return
+	}
+	cl := &wsClient{
+		h:        h,
+		conn:     conn,
+		userID:   userID,
+		username: username,
+		sub:      sub,
+		streams:  make(map[realtime.Stream]bool),
+		typing:   make(map[int64]time.Time),
+		send:     make(chan wsOutbound, wsSendBuffer),
+		done:     make(chan struct{}),
+		limiter:  rate.NewLimiter(10, 20),
+	}
+	go cl.writeLoop()
+	cl.readLoop()
+}
+
+// wsClient is one connection. readLoop handles client messages; writeLoop is
+// the only writer to the socket. If the client reads too slowly, its hub
+// subscription lags and is replaced, and the client is told to resync.
+type wsClient struct {
+	h        *WebSocketHandler
+	conn     *websocket.Conn
+	userID   int64
+	username string
+
+	mu      sync.Mutex
+	sub     *realtime.Subscription
+	streams map[realtime.Stream]bool
+	closed  bool
+
+	typing  map[int64]time.Time
+	send    chan wsOutbound
+	done    chan struct{}
+	limiter *rate.Limiter
+}
+
+func (cl *wsClient) readLoop() {
+	defer func() {
+		close(cl.done)
+		cl.mu.Lock()
+		cl.closed = true
+		cl.sub.Close()
+		var posts []int64
+		for st := range cl.streams {
+			if st.Kind == realtime.StreamPost {
+				posts = append(posts, st.ID)
+			}
+		}
+		cl.mu.Unlock()
+		if cl.h.Presence != nil {
+			for _, postID := range posts {
+				cl.h.Presence.Leave(postID, cl.userID)
+			}
+		}
+	}()
+	cl.conn.SetReadLimit(wsMaxMessageBytes)
+	cl.conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+	cl.conn.SetPongHandler(func(string) error {
+		return cl.conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+	})
+	for {
+		_, data, err := cl.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		if !cl.limiter.Allow() {
+			cl.reply(wsOutbound{Type: "error", Error: "Rate limit exceeded"})
+			continue
+		}
+		var msg wsInbound
+		if err := json.Unmarshal(data, &msg); err != nil {
+			cl.reply(wsOutbound{Type: "error", Error: "Invalid message format"})
+			continue
+		}
+		cl.handle(msg)
+	}
+}
+
+// reply queues a message for the client. A client that is not reading its
+// replies is disconnected.
+func (cl *wsClient) reply(msg wsOutbound) {
+	select {
+	case cl.send <- msg:
+	default:
+		cl.conn.Close()
+	}
+}
+
+func (cl *wsClient) handle(msg wsInbound) {
+	st := realtime.Stream{Kind: msg.Stream, ID: msg.ID}
+	switch msg.Type {
+	case "subscribe":
+		if err := cl.subscribe(st); err != "" {
+			cl.reply(wsOutbound{Type: "error", Stream: st.Kind, ID: st.ID, Error: err})
+			return
+		}
+		cl.reply(wsOutbound{Type: "subscribed", Stream: st.Kind, ID: st.ID})
+		if st.Kind == realtime.StreamPost && cl.h.Presence != nil {
+			viewers := cl.h.Presence.Viewers(st.ID)
+			cl.reply(wsOutbound{Type: "presence", Stream: st.Kind, ID: st.ID, Viewers: &viewers})
+		}
+	case "unsubscribe":
+		cl.mu.Lock()
+		if cl.streams[st] {
+			delete(cl.streams, st)
+			cl.sub.Unwatch(st)
+			if st.Kind == realtime.StreamPost && cl.h.Presence != nil {
+				cl.h.Presence.Leave(st.ID, cl.userID)
+			}
+		}
+		cl.mu.Unlock()
+		cl.reply(wsOutbound{Type: "unsubscribed", Stream: st.Kind, ID: st.ID})
+	case "typing":
+		cl.mu.Lock()
+		subscribed := st.Kind == realtime.StreamPost && cl.streams[st]
+		cl.mu.Unlock()
+		if !subscribed {
+			cl.reply(wsOutbound{Type: "error", Stream: st.Kind, ID: st.ID, Error: "Subscribe to the post before sending typing indicators"})
+			return
+		}
+		if time.Since(cl.typing[st.ID]) < wsTypingInterval {
+			return
+		}
+		cl.typing[st.ID] = time.Now()
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		ev := realtime.Event{Type: realtime.EventTyping, PostID: st.ID}
+		if err := realtime.Publish(ctx, cl.h.DB, ev, realtime.TypingData{UserID: cl.userID, Username: cl.username}); err != nil {
+			log.Printf("WARN: Failed to publish typing indicator for post %d: %v", st.ID, err)
+		}
+	case "ping":
+		cl.reply(wsOutbound{Type: "pong"})
+	default:
+		cl.reply(wsOutbound{Type: "error", Error: "Unknown message type"})
+	}
+}
+
+// subscribe validates and adds a stream, returning a client-facing error.
+func (cl *wsClient) subscribe(st realtime.Stream) string {
+	if st.ID <= 0 || (st.Kind != realtime.StreamPost && st.Kind != realtime.StreamTopic) {
+		return "Invalid stream"
+	}
+	cl.mu.Lock()
+	already, count := cl.streams[st], len(cl.streams)
+	cl.mu.Unlock()
+	if already {
+		return ""
+	}
+	if count >= wsMaxSubscriptions {
+		return "Too many subscriptions"
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	query := `SELECT EXISTS (SELECT 1 FROM topics WHERE id = $1)`
+	if st.Kind == realtime.StreamPost {
+		query = `SELECT EXISTS (SELECT 1 FROM posts WHERE id = $1 AND deleted_at IS NULL AND redirect_post_id IS NULL)`
+	}
+	var exists bool
+	if err := cl.h.DB.QueryRowContext(ctx, query, st.ID).Scan(&exists); err != nil {
+		log.Printf("ERROR: Failed to check %s %d for websocket subscription: %v", st.Kind, st.ID, err)
+		return "Failed to subscribe"
+	}
+	if !exists {
+		return strings.ToUpper(st.Kind[:1]) + st.Kind[1:] + " not found"
+	}
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	if !cl.streams[st] {
+		cl.streams[st] = true
+		cl.sub.Watch(st)
+		if st.Kind == realtime.StreamPost && cl.h.Presence != nil {
+			cl.h.Presence.Join(st.ID, cl.userID)
+		}
+	}
+	return ""
+}
+
+// resubscribe replaces a lagged hub subscription, keeping the client's
+// streams.
+func (cl *wsClient) resubscribe() bool {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	if cl.closed {
+		return false
+	}
+	streams := make([]realtime.Stream, 0, len(cl.streams))
+	for st := range cl.streams {
+		streams = append(streams, st)
+	}
+	sub, err := cl.h.Hub.Subscribe(streams...)
+	if err != nil {
+		return false
+	}
+	cl.sub = sub
+	return true
+}
+
+func (cl *wsClient) events() <-chan realtime.Event {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	return cl.sub.C
+}
+
+func (cl *wsClient) writeLoop() {
+	ping := time.NewTicker(wsPingInterval)
+	defer func() {
+		ping.Stop()
+		cl.conn.Close()
+	}()
+	for {
+		var msg wsOutbound
+		select {
+		case <-cl.done:
+			return
+		case <-ping.C:
+			cl.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+			if err := cl.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+			continue
+		case msg = <-cl.send:
+		case ev, ok := <-cl.events():
+			if !ok {
+				if !cl.resubscribe() {
+					return
+				}
+				msg = wsOutbound{Type: "resync"}
+			} else if m, ok := cl.outbound(ev); ok {
+				msg = m
+			} else {
+				continue
+			}
+		}
+		cl.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+		if err := cl.conn.WriteJSON(msg); err != nil {
+			return
+		}
+	}
+}
+
+// outbound turns a hub event into a client message. Clients do not receive
+// their own typing indicators.
+func (cl *wsClient) outbound(ev realtime.Event) (wsOutbound, bool) {
+	switch ev.Type {
+	case realtime.EventTyping:
+		var d realtime.TypingData
+		if json.Unmarshal(ev.Data, &d) != nil || d.UserID == cl.userID {
+			return wsOutbound{}, false
+		}
+		return wsOutbound{Type: "typing", Stream: realtime.StreamPost, ID: ev.PostID, Username: d.Username}, true
+	case realtime.EventPresence:
+		var d realtime.PresenceData
+		if json.Unmarshal(ev.Data, &d) != nil {
+			return wsOutbound{}, false
+		}
+		return wsOutbound{Type: "presence", Stream: realtime.StreamPost, ID: ev.PostID, Viewers: &d.Viewers}, true
+	default:
+		return wsOutbound{Type: "event", Event: &ev}, true
+	}
+}
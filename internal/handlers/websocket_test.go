@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/v1-nce/threadtalk-backend/internal/dbtest"
+	"github.com/v1-nce/threadtalk-backend/internal/realtime"
+)
+
+// dialWS serves h as user 1 and opens a WebSocket to it.
+func dialWS(t *testing.T, h *WebSocketHandler) *websocket.Conn {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/ws", func(c *gin.Context) { c.Set("userID", int64(1)) }, h.Serve)
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/ws", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func sendWS(t *testing.T, conn *websocket.Conn, msg string) {
+	t.Helper()
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(msg)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readWS(t *testing.T, conn *websocket.Conn) wsOutbound {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var msg wsOutbound
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Fatal(err)
+	}
+	return msg
+}
+
+func TestWebSocketSubscribe(t *testing.T) {
+	db, f := dbtest.New(t,
+		dbtest.Row("SELECT username FROM users", "alice"),
+		dbtest.Row("SELECT EXISTS (SELECT 1 FROM posts", true),
+	)
+	hub := realtime.NewHub(realtime.Config{})
+	h := &WebSocketHandler{DB: db, Hub: hub, Presence: realtime.NewPresence(db, hub)}
+	conn := dialWS(t, h)
+
+	sendWS(t, conn, `{"type": "subscribe", "stream": "post", "id": "5"}`)
+	if msg := readWS(t, conn); msg.Type != "subscribed" || msg.ID != 5 {
+		t.Fatalf("got %+v, want subscribed to post 5", msg)
+	}
+	if msg := readWS(t, conn); msg.Type != "presence" || msg.Viewers == nil {
+		t.Fatalf("got %+v, want a presence count", msg)
+	}
+	if !f.Executed("pg_notify") {
+		t.Error("joining the post was not announced")
+	}
+
+	hub.Deliver(realtime.Event{Type: realtime.EventCommentCreated, PostID: 5, CommentID: 9})
+	if msg := readWS(t, conn); msg.Type != "event" || msg.Event == nil || msg.Event.CommentID != 9 {
+		t.Fatalf("got %+v, want the comment event", msg)
+	}
+
+	// After the hub drops the subscription the client is told to resync and
+	// keeps its streams.
+	hub.Reset()
+	if msg := readWS(t, conn); msg.Type != "resync" {
+		t.Fatalf("got %+v, want resync", msg)
+	}
+	hub.Deliver(realtime.Event{Type: realtime.EventCommentCreated, PostID: 5, CommentID: 10})
+	if msg := readWS(t, conn); msg.Type != "event" || msg.Event.CommentID != 10 {
+		t.Fatalf("got %+v after resync, want the next comment event", msg)
+	}
+
+	sendWS(t, conn, `{"type": "typing", "stream": "post", "id": "5"}`)
+	sendWS(t, conn, `{"type": "ping"}`)
+	if msg := readWS(t, conn); msg.Type != "pong" {
+		t.Fatalf("got %+v, want pong", msg)
+	}
+	var typing int
+	for _, stmt := range f.Find("pg_notify") {
+		if strings.Contains(stmt.Args[1].(string), `"type":"typing"`) {
+			typing++
+		}
+	}
+	if typing != 1 {
+		t.Errorf("published %d typing indicators, want 1", typing)
+	}
+}
+
+func TestWebSocketErrors(t *testing.T) {
+	db, _ := dbtest.New(t,
+		dbtest.Row("SELECT username FROM users", "alice"),
+		dbtest.Row("SELECT EXISTS (SELECT 1 FROM topics", false),
+	)
+	h := &WebSocketHandler{DB: db, Hub: realtime.NewHub(realtime.Config{})}
+	conn := dialWS(t, h)
+	tests := []struct {
+		msg  string
+		want string
+	}{
+		{`not json`, "Invalid message format"},
+		{`{"type": "shout"}`, "Unknown message type"},
+		{`{"type": "subscribe", "stream": "user", "id": "1"}`, "Invalid stream"},
+		{`{"type": "subscribe", "stream": "topic", "id": "0"}`, "Invalid stream"},
+		{`{"type": "subscribe", "stream": "topic", "id": "2"}`, "Topic not found"},
+		{`{"type": "typing", "stream": "post", "id": "5"}`, "Subscribe to the post before sending typing indicators"},
+	}
+	for _, tt := range tests {
+		sendWS(t, conn, tt.msg)
+		if msg := readWS(t, conn); msg.Type != "error" || msg.Error != tt.want {
+			t.Errorf("%s: got %+v, want error %q", tt.msg, msg, tt.want)
+		}
+	}
+}
+
+func TestWebSocketOutbound(t *testing.T) {
+	cl := &wsClient{userID: 1}
+	typing := func(userID int64) realtime.Event {
+		ev := realtime.Event{Type: realtime.EventTyping, PostID: 5}
+		ev.Data = []byte(`{"user_id": "` + strconv.FormatInt(userID, 10) + `", "username": "bob"}`)
+		return ev
+	}
+	if _, ok := cl.outbound(typing(1)); ok {
+		t.Error("client received its own typing indicator")
+	}
+	if msg, ok := cl.outbound(typing(2)); !ok || msg.Type != "typing" || msg.Username != "bob" {
+		t.Errorf("typing from another user = %+v, %t", msg, ok)
+	}
+	if _, ok := cl.outbound(realtime.Event{Type: realtime.EventPresence, Data: []byte(`nope`)}); ok {
+		t.Error("malformed presence event was sent")
+	}
+}
+
+func TestCheckWSOrigin(t *testing.T) {
+	t.Setenv("FRONTEND_URL", "https://app.example.com/")
+	tests := []struct {
+		origin string
+		want   bool
+	}{
+		{"", true},
+		{"https://app.example.com", true},
+		{"https://api.example.com", true}, // same host as the request
+		{"https://evil.example.com", false},
+		{"null", false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "https://api.example.com/ws", nil)
+		if tt.origin != "" {
+			r.Header.Set("Origin", tt.origin)
+		}
+		if got := checkWSOrigin(r); got != tt.want {
+			t.Errorf("origin %q: allowed = %t, want %t", tt.origin, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,469 @@
+package mailer
+
+import (
+	"context"
+	"database/sql"
+	"expvar"
+	"fmt"
+	"log"
+	"net/mail"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// digestLimit caps how many notifications are listed in one digest; the rest
+// are summarised as a count.
+const digestLimit = 50
+
+type Config struct {
+	Interval    time.Duration
+	BatchSize   int
+	MaxAttempts int
+	Retention   time.Duration
+	From        string
+	FrontendURL string
+	BackendURL  string
+	SMTP        SMTPSender
+}
+
+// ConfigFromEnv reads MAIL_INTERVAL (0 disables the worker), MAIL_BATCH_SIZE,
+// MAIL_MAX_ATTEMPTS, MAIL_RETENTION, MAIL_FROM and SMTP_ADDR, SMTP_USERNAME
+// and SMTP_PASSWORD. Without SMTP_ADDR the worker is disabled. Links in
+// emails point at FRONTEND_URL, and unsubscribe and verification links at
+// BACKEND_URL.
+func ConfigFromEnv() Config {
+	cfg := Config{
+		Interval:    time.Minute,
+		BatchSize:   100,
+		MaxAttempts: 5,
+		Retention:   30 * 24 * time.Hour,
+		From:        "ThreadTalk <no-reply@threadtalk.local>",
+		FrontendURL: strings.TrimRight(os.Getenv("FRONTEND_URL"), "/"),
+		BackendURL:  strings.TrimRight(os.Getenv("BACKEND_URL"), "/"),
+		SMTP: SMTPSender{
+			Addr:     os.Getenv("SMTP_ADDR"),
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+		},
+	}
+	if s := os.Getenv("MAIL_INTERVAL"); s != "" {
+		if v, err := time.ParseDuration(s); err == nil && v >= 0 {
+			cfg.Interval = v
+		} else {
+			log.Printf("WARN: Ignoring invalid MAIL_INTERVAL %q", s)
+		}
+	}
+	if cfg.SMTP.Addr == "" {
+		cfg.Interval = 0
+	}
+	if v, err := strconv.Atoi(os.Getenv("MAIL_BATCH_SIZE")); err == nil && v > 0 {
+		cfg.BatchSize = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("MAIL_MAX_ATTEMPTS")); err == nil && v > 0 {
+		cfg.MaxAttempts = v
+	}
+	if v, err := time.ParseDuration(os.Getenv("MAIL_RETENTION")); err == nil && v > 0 {
+		cfg.Retention = v
+	}
+	if s := os.Getenv("MAIL_FROM"); s != "" {
+		cfg.From = s
+	}
+	return cfg
+}
+
+type Result struct {
+	Sent          int64
+	Digests       int64
+	Verifications int64
+	Skipped       int64
+	Failed        int64
+	Pruned        int64
+}
+
+var metrics = expvar.NewMap("mailer")
+
+// Mailer drains the email outbox that InsertNotifications fills. Users on the
+// immediate frequency get one email per notification; daily and weekly users
+// get a digest once their period has passed. Notifications that were read,
+// whose content was deleted, or whose recipient turned email off or changed
+// to an unverified address in the meantime are skipped. Failed sends are
+// retried with exponential backoff until MaxAttempts. The mailer also sends
+// address verification links and prunes finished outbox rows after
+// Retention.
+type Mailer struct {
+	db       *sql.DB
+	cfg      Config
+	sender   Sender
+	stopChan chan struct{}
+	stopped  sync.Once
+	done     chan struct{}
+}
+
+func NewMailer(db *sql.DB, cfg Config) *Mailer {
+	return &Mailer{
+		db:       db,
+		cfg:      cfg,
+		sender:   cfg.SMTP,
+		stopChan: make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+func (m *Mailer) Start() {
+	if m.cfg.Interval == 0 {
+		log.Println("Mailer disabled")
+		close(m.done)
+		return
+	}
+	log.Printf("Mailer started (smtp %s, interval %s)", m.cfg.SMTP.Addr, m.cfg.Interval)
+	if m.cfg.BackendURL == "" {
+		log.Println("WARN: BACKEND_URL is not set, so no verification emails can be sent")
+	}
+	go m.loop()
+}
+
+func (m *Mailer) Stop() {
+	m.stopped.Do(func() {
+		close(m.stopChan)
+	})
+	<-m.done
+}
+
+func (m *Mailer) loop() {
+	defer close(m.done)
+	ticker := time.NewTicker(m.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		m.runLogged()
+		select {
+		case <-m.stopChan:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (m *Mailer) runLogged() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	go func() {
+		select {
+		case <-m.stopChan:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	res, err := m.RunOnce(ctx)
+	metrics.Add("runs", 1)
+	metrics.Add("sent", res.Sent)
+	metrics.Add("digests", res.Digests)
+	metrics.Add("verifications", res.Verifications)
+	metrics.Add("skipped", res.Skipped)
+	metrics.Add("failed", res.Failed)
+	metrics.Add("pruned", res.Pruned)
+	last := new(expvar.Int)
+	last.Set(time.Now().Unix())
+	metrics.Set("last_run_unix", last)
+	lastErr := new(expvar.String)
+	if err != nil {
+		lastErr.Set(err.Error())
+		log.Printf("ERROR: Mailer run failed: %v", err)
+	}
+	metrics.Set("last_error", lastErr)
+	if res != (Result{}) {
+		log.Printf("Mailer sent %d emails, %d digests and %d verification links, skipped %d, gave up on %d, pruned %d",
+			res.Sent, res.Digests, res.Verifications, res.Skipped, res.Failed, res.Pruned)
+	}
+}
+
+// RunOnce skips stale outbox rows, then sends up to BatchSize verification
+// links, immediate emails and digests each, and prunes old finished rows.
+func (m *Mailer) RunOnce(ctx context.Context) (Result, error) {
+	var res Result
+	r, err := m.db.ExecContext(ctx, `
+		UPDATE email_outbox o SET status = 'skipped'
+		FROM notifications n, email_settings e
+		WHERE o.status = 'pending' AND n.id = o.notification_id AND e.user_id = o.user_id
+			AND (e.frequency = 'off' OR e.verified_at IS NULL OR n.read_at IS NOT NULL
+				OR EXISTS (SELECT 1 FROM posts p WHERE p.id = n.post_id AND p.deleted_at IS NOT NULL)
+				OR EXISTS (SELECT 1 FROM comments c WHERE c.id = n.comment_id AND c.deleted_at IS NOT NULL))`)
+	if err != nil {
+		return res, fmt.Errorf("skip stale emails: %w", err)
+	}
+	res.Skipped, _ = r.RowsAffected()
+	for i := 0; i < m.cfg.BatchSize && m.cfg.BackendURL != ""; i++ {
+		found, err := m.sendVerification(ctx, &res)
+		if err != nil {
+			return res, fmt.Errorf("send verification: %w", err)
+		}
+		if !found {
+			break
+		}
+	}
+	for i := 0; i < m.cfg.BatchSize; i++ {
+		found, err := m.sendImmediate(ctx, &res)
+		if err != nil {
+			return res, fmt.Errorf("send email: %w", err)
+		}
+		if !found {
+			break
+		}
+	}
+	for i := 0; i < m.cfg.BatchSize; i++ {
+		found, err := m.sendDigest(ctx, &res)
+		if err != nil {
+			return res, fmt.Errorf("send digest: %w", err)
+		}
+		if !found {
+			break
+		}
+	}
+	cutoff := time.Now().Add(-m.cfg.Retention)
+	for {
+		r, err := m.db.ExecContext(ctx, `
+			DELETE FROM email_outbox WHERE id IN (
+				SELECT id FROM email_outbox
+				WHERE status <> 'pending' AND created_at < $1
+				LIMIT $2
+			)`, cutoff, m.cfg.BatchSize)
+		if err != nil {
+			return res, fmt.Errorf("prune emails: %w", err)
+		}
+		n, _ := r.RowsAffected()
+		res.Pruned += n
+		if n < int64(m.cfg.BatchSize) {
+			break
+		}
+	}
+	return res, nil
+}
+
+// itemColumns selects an item for each outbox row o joined to its
+// notification n.
+const itemColumns = `
+	n.kind, COALESCE(n.action, ''), COALESCE(a.username, ''), n.post_id, COALESCE(p.title, ''), n.created_at
+	FROM email_outbox o
+	JOIN notifications n ON n.id = o.notification_id
+	LEFT JOIN users a ON a.id = n.actor_id
+	LEFT JOIN posts p ON p.id = n.post_id`
+
+// sendImmediate claims one due outbox row of an immediate-frequency user and
+// sends it. The row stays locked until the outcome is recorded, so replicas
+// never send the same email twice.
+func (m *Mailer) sendImmediate(ctx context.Context, res *Result) (bool, error) {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+	var id int64
+	var to, token string
+	var it item
+	err = tx.QueryRowContext(ctx, `
+		SELECT o.id, e.email, e.unsubscribe_token,`+itemColumns+`
+		JOIN email_settings e ON e.user_id = o.user_id
+		WHERE o.status = 'pending' AND o.next_attempt_at <= CURRENT_TIMESTAMP
+			AND e.frequency = 'immediate' AND e.verified_at IS NOT NULL
+		ORDER BY o.id
+		LIMIT 1
+		FOR UPDATE OF o SKIP LOCKED`).Scan(&id, &to, &token, &it.Kind, &it.Action, &it.Actor, &it.PostID, &it.PostTitle, &it.CreatedAt)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	unsubscribe := m.unsubscribeURL(token)
+	sendErr := m.send(ctx, to, immediateMessage(m.cfg.From, to, m.cfg.FrontendURL, unsubscribe, it))
+	if err := m.record(ctx, tx, []int64{id}, sendErr, res); err != nil {
+		return false, err
+	}
+	if sendErr == nil {
+		res.Sent++
+	}
+	return true, tx.Commit()
+}
+
+// sendVerification claims one address waiting for its verification link and
+// mails it. Failed sends back off like outbox rows; after MaxAttempts the
+// address is left unverified until the user saves it again.
+func (m *Mailer) sendVerification(ctx context.Context, res *Result) (bool, error) {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+	var userID int64
+	var to, token string
+	err = tx.QueryRowContext(ctx, `
+		SELECT user_id, email, verification_token
+		FROM email_settings
+		WHERE verification_token IS NOT NULL AND verification_sent_at IS NULL
+			AND verification_attempts < $1 AND verification_next_attempt_at <= CURRENT_TIMESTAMP
+		ORDER BY user_id
+		LIMIT 1
+		FOR UPDATE SKIP LOCKED`, m.cfg.MaxAttempts).Scan(&userID, &to, &token)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	sendErr := m.send(ctx, to, verificationMessage(m.cfg.From, to, m.cfg.BackendURL+"/email/verify?token="+token))
+	if sendErr == nil {
+		res.Verifications++
+		_, err = tx.ExecContext(ctx, `
+			UPDATE email_settings SET verification_sent_at = CURRENT_TIMESTAMP, verification_attempts = verification_attempts + 1
+			WHERE user_id = $1`, userID)
+	} else {
+		log.Printf("WARN: Failed to send verification email for user %d: %v", userID, sendErr)
+		_, err = tx.ExecContext(ctx, `
+			UPDATE email_settings SET
+				verification_attempts = verification_attempts + 1,
+				verification_next_attempt_at = CURRENT_TIMESTAMP + LEAST(INTERVAL '1 minute' * power(2, verification_attempts), INTERVAL '6 hours')
+			WHERE user_id = $1`, userID)
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// sendDigest claims one daily or weekly user whose period has passed and who
+// has due notifications, and sends them a digest.
+func (m *Mailer) sendDigest(ctx context.Context, res *Result) (bool, error) {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+	var userID int64
+	var to, token, frequency string
+	err = tx.QueryRowContext(ctx, `
+		SELECT e.user_id, e.email, e.unsubscribe_token, e.frequency
+		FROM email_settings e
+		WHERE e.verified_at IS NOT NULL
+		AND ((e.frequency = 'daily' AND (e.last_digest_at IS NULL OR e.last_digest_at <= CURRENT_TIMESTAMP - INTERVAL '1 day'))
+			OR (e.frequency = 'weekly' AND (e.last_digest_at IS NULL OR e.last_digest_at <= CURRENT_TIMESTAMP - INTERVAL '7 days')))
+		AND EXISTS (
+			SELECT 1 FROM email_outbox o
+			WHERE o.user_id = e.user_id AND o.status = 'pending' AND o.next_attempt_at <= CURRENT_TIMESTAMP
+		)
+		ORDER BY e.user_id
+		LIMIT 1
+		FOR UPDATE OF e SKIP LOCKED`).Scan(&userID, &to, &token, &frequency)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	rows, err := tx.QueryContext(ctx, `
+		SELECT o.id,`+itemColumns+`
+		WHERE o.user_id = $1 AND o.status = 'pending'
+		ORDER BY o.id
+		FOR UPDATE OF o`, userID)
+	if err != nil {
+		return false, err
+	}
+	var ids []int64
+	var items []item
+	for rows.Next() {
+		var id int64
+		var it item
+		if err := rows.Scan(&id, &it.Kind, &it.Action, &it.Actor, &it.PostID, &it.PostTitle, &it.CreatedAt); err != nil {
+			rows.Close()
+			return false, err
+		}
+		ids = append(ids, id)
+		items = append(items, it)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return false, err
+	}
+	if len(items) == 0 {
+		return true, tx.Commit()
+	}
+	more := 0
+	if len(items) > digestLimit {
+		more = len(items) - digestLimit
+		items = items[:digestLimit]
+	}
+	unsubscribe := m.unsubscribeURL(token)
+	msg := digestMessage(m.cfg.From, to, m.cfg.FrontendURL, unsubscribe, frequency, items, more)
+	sendErr := m.send(ctx, to, msg)
+	if err := m.record(ctx, tx, ids, sendErr, res); err != nil {
+		return false, err
+	}
+	if sendErr == nil {
+		res.Digests++
+		if _, err := tx.ExecContext(ctx, `UPDATE email_settings SET last_digest_at = CURRENT_TIMESTAMP WHERE user_id = $1`, userID); err != nil {
+			return false, err
+		}
+	}
+	return true, tx.Commit()
+}
+
+func (m *Mailer) send(ctx context.Context, to string, msg message) error {
+	body, err := msg.bytes(time.Now())
+	if err != nil {
+		return err
+	}
+	from, err := mail.ParseAddress(m.cfg.From)
+	if err != nil {
+		return err
+	}
+	return m.sender.Send(ctx, from.Address, to, body)
+}
+
+// record marks the outbox rows sent, or schedules a retry after a failed
+// send. Retries back off exponentially from one minute up to six hours, and
+// rows that reach MaxAttempts are marked failed.
+func (m *Mailer) record(ctx context.Context, tx *sql.Tx, ids []int64, sendErr error, res *Result) error {
+	if sendErr == nil {
+		_, err := tx.ExecContext(ctx, `
+			UPDATE email_outbox SET status = 'sent', sent_at = CURRENT_TIMESTAMP, attempts = attempts + 1, last_error = ''
+			WHERE id = ANY($1)`, ids)
+		return err
+	}
+	msg := errorText(sendErr)
+	log.Printf("WARN: Failed to send email for outbox rows %v: %v", ids, sendErr)
+	var failed int64
+	err := tx.QueryRowContext(ctx, `
+		WITH updated AS (
+			UPDATE email_outbox SET
+				attempts = attempts + 1,
+				last_error = $2,
+				status = CASE WHEN attempts + 1 >= $3 THEN 'failed' ELSE status END,
+				next_attempt_at = CURRENT_TIMESTAMP + LEAST(INTERVAL '1 minute' * power(2, attempts), INTERVAL '6 hours')
+			WHERE id = ANY($1)
+			RETURNING status
+		)
+		SELECT COUNT(*) FROM updated WHERE status = 'failed'`, ids, msg, m.cfg.MaxAttempts).Scan(&failed)
+	if err != nil {
+		return err
+	}
+	res.Failed += failed
+	return nil
+}
+
+// errorText is err's message cut to 500 bytes. Invalid UTF-8, including a
+// sequence split by the cut, is dropped since the database would reject it.
+func errorText(err error) string {
+	msg := err.Error()
+	if len(msg) > 500 {
+		msg = msg[:500]
+	}
+	return strings.ToValidUTF8(msg, "")
+}
+
+func (m *Mailer) unsubscribeURL(token string) string {
+	if m.cfg.BackendURL == "" {
+		return ""
+	}
+	return m.cfg.BackendURL + "/email/unsubscribe?token=" + token
+}
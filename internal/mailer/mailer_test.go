@@ -0,0 +1,78 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+type sentMail struct {
+	from, to string
+	msg      []byte
+}
+
+// fakeSender records messages instead of delivering them.
+type fakeSender struct {
+	sent []sentMail
+	err  error
+}
+
+func (f *fakeSender) Send(ctx context.Context, from, to string, msg []byte) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.sent = append(f.sent, sentMail{from, to, msg})
+	return nil
+}
+
+func TestSend(t *testing.T) {
+	fake := &fakeSender{}
+	m := &Mailer{cfg: Config{From: "ThreadTalk <no-reply@example.com>"}, sender: fake}
+	msg := verificationMessage(m.cfg.From, "bob@example.com", "https://api.example/email/verify?token=t")
+	if err := m.send(context.Background(), "bob@example.com", msg); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if len(fake.sent) != 1 {
+		t.Fatalf("sent %d messages, want 1", len(fake.sent))
+	}
+	got := fake.sent[0]
+	if got.from != "no-reply@example.com" || got.to != "bob@example.com" {
+		t.Errorf("envelope = %q -> %q, want no-reply@example.com -> bob@example.com", got.from, got.to)
+	}
+	if !strings.Contains(string(got.msg), "Subject: Confirm your email address for ThreadTalk\r\n") {
+		t.Errorf("message has no subject:\n%s", got.msg)
+	}
+}
+
+func TestSendErrors(t *testing.T) {
+	boom := errors.New("connection refused")
+	m := &Mailer{cfg: Config{From: "no-reply@example.com"}, sender: &fakeSender{err: boom}}
+	if err := m.send(context.Background(), "bob@example.com", message{From: m.cfg.From, To: "bob@example.com"}); !errors.Is(err, boom) {
+		t.Errorf("send error = %v, want %v", err, boom)
+	}
+	m = &Mailer{cfg: Config{From: "bad"}, sender: &fakeSender{}}
+	if err := m.send(context.Background(), "bob@example.com", message{From: m.cfg.From, To: "bob@example.com"}); err == nil {
+		t.Error("send accepted an invalid From address")
+	}
+}
+
+func TestErrorText(t *testing.T) {
+	short := errors.New("550 mailbox unavailable")
+	if got := errorText(short); got != short.Error() {
+		t.Errorf("errorText = %q, want %q", got, short.Error())
+	}
+	// 499 ASCII bytes then a two-byte rune straddling the cut.
+	long := errors.New(strings.Repeat("a", 499) + "é" + strings.Repeat("b", 10))
+	got := errorText(long)
+	if !utf8.ValidString(got) {
+		t.Errorf("errorText returned invalid UTF-8 %q", got[490:])
+	}
+	if got != strings.Repeat("a", 499) {
+		t.Errorf("errorText kept %d bytes, want the 499 before the split rune", len(got))
+	}
+	if got := errorText(errors.New("bad \xff byte")); got != "bad  byte" {
+		t.Errorf("errorText = %q, want invalid bytes dropped", got)
+	}
+}
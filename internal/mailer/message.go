@@ -0,0 +1,161 @@
+package mailer
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net/mail"
+	"strings"
+	"time"
+)
+
+// item is one notification as it appears in an email.
+type item struct {
+	Kind      string
+	Action    string
+	Actor     string
+	PostID    *int64
+	PostTitle string
+	CreatedAt time.Time
+}
+
+// summary is a one-line description of the notification.
+func (it item) summary() string {
+	actor := it.Actor
+	if actor == "" {
+		actor = "Someone"
+	}
+	title := strings.Join(strings.Fields(it.PostTitle), " ")
+	if title == "" {
+		title = "a thread"
+	} else {
+		title = "“" + title + "”"
+	}
+	switch it.Kind {
+	case "reply":
+		return fmt.Sprintf("%s replied to you in %s", actor, title)
+	case "mention":
+		return fmt.Sprintf("%s mentioned you in %s", actor, title)
+	case "new_comment":
+		return fmt.Sprintf("%s commented in %s", actor, title)
+	case "new_post":
+		return fmt.Sprintf("%s posted %s", actor, title)
+	case "moderation":
+		return fmt.Sprintf("A moderator %s your content in %s", it.Action, title)
+	default:
+		return fmt.Sprintf("New activity in %s", title)
+	}
+}
+
+func (it item) link(frontendURL string) string {
+	if it.PostID == nil {
+		return frontendURL
+	}
+	return fmt.Sprintf("%s/posts/%d", frontendURL, *it.PostID)
+}
+
+type message struct {
+	From           string
+	To             string
+	Subject        string
+	Body           string
+	UnsubscribeURL string
+}
+
+// headerValue drops line breaks so user-controlled text such as post titles
+// cannot inject headers, then encodes non-ASCII text.
+func headerValue(s string) string {
+	s = strings.NewReplacer("\r", " ", "\n", " ").Replace(s)
+	return mime.QEncoding.Encode("utf-8", s)
+}
+
+// bytes renders the message as RFC 5322 text with a quoted-printable body
+// and one-click unsubscribe headers (RFC 8058).
+func (m message) bytes(now time.Time) ([]byte, error) {
+	from, err := mail.ParseAddress(m.From)
+	if err != nil {
+		return nil, fmt.Errorf("invalid MAIL_FROM: %w", err)
+	}
+	id := make([]byte, 16)
+	rand.Read(id)
+	domain := "threadtalk"
+	if at := strings.LastIndex(from.Address, "@"); at >= 0 {
+		domain = from.Address[at+1:]
+	}
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", from.String())
+	fmt.Fprintf(&b, "To: %s\r\n", (&mail.Address{Address: m.To}).String())
+	fmt.Fprintf(&b, "Subject: %s\r\n", headerValue(m.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	fmt.Fprintf(&b, "Message-ID: <%s@%s>\r\n", hex.EncodeToString(id), domain)
+	if m.UnsubscribeURL != "" {
+		fmt.Fprintf(&b, "List-Unsubscribe: <%s>\r\n", m.UnsubscribeURL)
+		b.WriteString("List-Unsubscribe-Post: List-Unsubscribe=One-Click\r\n")
+	}
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+	qp := quotedprintable.NewWriter(&b)
+	if _, err := qp.Write([]byte(strings.ReplaceAll(m.Body, "\n", "\r\n"))); err != nil {
+		return nil, err
+	}
+	if err := qp.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+func footer(unsubscribeURL string) string {
+	if unsubscribeURL == "" {
+		return ""
+	}
+	return "\n--\nYou are receiving this because email notifications are on for your ThreadTalk account.\nUnsubscribe: " + unsubscribeURL + "\n"
+}
+
+func immediateMessage(from, to, frontendURL, unsubscribeURL string, it item) message {
+	return message{
+		From:           from,
+		To:             to,
+		Subject:        it.summary(),
+		Body:           it.summary() + "\n\n" + it.link(frontendURL) + "\n" + footer(unsubscribeURL),
+		UnsubscribeURL: unsubscribeURL,
+	}
+}
+
+func digestMessage(from, to, frontendURL, unsubscribeURL, period string, items []item, more int) message {
+	var body strings.Builder
+	fmt.Fprintf(&body, "Here is what happened on ThreadTalk since your last %s digest:\n\n", period)
+	for _, it := range items {
+		fmt.Fprintf(&body, "- %s\n  %s\n", it.summary(), it.link(frontendURL))
+	}
+	if more > 0 {
+		fmt.Fprintf(&body, "\n…and %d more in your notification center.\n", more)
+	}
+	body.WriteString(footer(unsubscribeURL))
+	total := len(items) + more
+	noun := "notifications"
+	if total == 1 {
+		noun = "notification"
+	}
+	return message{
+		From:           from,
+		To:             to,
+		Subject:        fmt.Sprintf("Your %s ThreadTalk digest: %d new %s", period, total, noun),
+		Body:           body.String(),
+		UnsubscribeURL: unsubscribeURL,
+	}
+}
+
+func verificationMessage(from, to, verifyURL string) message {
+	return message{
+		From:    from,
+		To:      to,
+		Subject: "Confirm your email address for ThreadTalk",
+		Body: "Someone asked for ThreadTalk notifications to be sent to this address.\n\n" +
+			"Confirm it here to start receiving them:\n" + verifyURL + "\n\n" +
+			"If this wasn't you, ignore this email and nothing will be sent.\n",
+	}
+}
@@ -0,0 +1,103 @@
+package mailer
+
+import (
+	"io"
+	"mime/quotedprintable"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestItemSummary(t *testing.T) {
+	post := int64(7)
+	tests := []struct {
+		name string
+		it   item
+		want string
+	}{
+		{"reply", item{Kind: "reply", Actor: "alice", PostTitle: "Go  generics\n"}, "alice replied to you in “Go generics”"},
+		{"mention without actor", item{Kind: "mention", PostTitle: "Hi"}, "Someone mentioned you in “Hi”"},
+		{"moderation", item{Kind: "moderation", Action: "locked", PostID: &post}, "A moderator locked your content in a thread"},
+		{"unknown kind", item{Kind: "other"}, "New activity in a thread"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.it.summary(); got != tt.want {
+				t.Errorf("summary() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMessageBytes(t *testing.T) {
+	post := int64(7)
+	it := item{Kind: "reply", Actor: "alice", PostID: &post, PostTitle: "Evil\r\nBcc: victim@example.com"}
+	msg := immediateMessage("ThreadTalk <no-reply@example.com>", "bob@example.com", "https://forum.example", "https://api.example/email/unsubscribe?token=t", it)
+	raw, err := msg.bytes(time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("bytes: %v", err)
+	}
+	header, body, ok := strings.Cut(string(raw), "\r\n\r\n")
+	if !ok {
+		t.Fatalf("no header/body separator in %q", raw)
+	}
+	for _, want := range []string{
+		"From: \"ThreadTalk\" <no-reply@example.com>\r\n",
+		"To: <bob@example.com>\r\n",
+		"Date: Fri, 02 Jan 2026 03:04:05 +0000\r\n",
+		"Message-ID: <",
+		"@example.com>\r\n",
+		"List-Unsubscribe: <https://api.example/email/unsubscribe?token=t>\r\n",
+		"List-Unsubscribe-Post: List-Unsubscribe=One-Click\r\n",
+	} {
+		if !strings.Contains(header, want) {
+			t.Errorf("header missing %q:\n%s", want, header)
+		}
+	}
+	if strings.Contains(header, "\r\nBcc:") {
+		t.Errorf("post title injected a header:\n%s", header)
+	}
+	decoded, err := io.ReadAll(quotedprintable.NewReader(strings.NewReader(body)))
+	if err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+	for _, want := range []string{"https://forum.example/posts/7", "Unsubscribe: https://api.example/email/unsubscribe?token=t"} {
+		if !strings.Contains(string(decoded), want) {
+			t.Errorf("body missing %q:\n%s", want, decoded)
+		}
+	}
+}
+
+func TestMessageBytesInvalidFrom(t *testing.T) {
+	if _, err := (message{From: "not an address", To: "bob@example.com"}).bytes(time.Now()); err == nil {
+		t.Error("bytes accepted an invalid From address")
+	}
+}
+
+func TestDigestMessage(t *testing.T) {
+	msg := digestMessage("a@example.com", "b@example.com", "https://forum.example", "", "daily",
+		[]item{{Kind: "reply", Actor: "alice"}}, 3)
+	if msg.Subject != "Your daily ThreadTalk digest: 4 new notifications" {
+		t.Errorf("subject = %q", msg.Subject)
+	}
+	if !strings.Contains(msg.Body, "…and 3 more") {
+		t.Errorf("body does not mention the remaining notifications:\n%s", msg.Body)
+	}
+	if strings.Contains(msg.Body, "Unsubscribe") {
+		t.Errorf("body has an unsubscribe footer without a URL:\n%s", msg.Body)
+	}
+	one := digestMessage("a@example.com", "b@example.com", "", "", "weekly", []item{{Kind: "reply"}}, 0)
+	if one.Subject != "Your weekly ThreadTalk digest: 1 new notification" {
+		t.Errorf("subject = %q", one.Subject)
+	}
+}
+
+func TestVerificationMessage(t *testing.T) {
+	msg := verificationMessage("a@example.com", "b@example.com", "https://api.example/email/verify?token=t")
+	if !strings.Contains(msg.Body, "https://api.example/email/verify?token=t") {
+		t.Errorf("body has no verification link:\n%s", msg.Body)
+	}
+	if msg.UnsubscribeURL != "" {
+		t.Errorf("verification email has an unsubscribe URL %q", msg.UnsubscribeURL)
+	}
+}
@@ -0,0 +1,106 @@
+package mailer
+
+import (
+	"context"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+)
+
+// smtpSession is what the test server received.
+type smtpSession struct {
+	from, to, data string
+}
+
+// serveSMTP accepts one connection and speaks just enough SMTP for
+// SMTPSender, without STARTTLS or AUTH. rcptReply overrides the reply to
+// RCPT TO.
+func serveSMTP(t *testing.T, rcptReply string) (string, <-chan smtpSession) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	done := make(chan smtpSession, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+		tp := textproto.NewConn(conn)
+		var s smtpSession
+		defer func() { done <- s }()
+		tp.PrintfLine("220 test ESMTP")
+		for {
+			line, err := tp.ReadLine()
+			if err != nil {
+				return
+			}
+			cmd := strings.ToUpper(line)
+			switch {
+			case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+				tp.PrintfLine("250 test")
+			case strings.HasPrefix(cmd, "MAIL FROM:"):
+				s.from = strings.Trim(line[len("MAIL FROM:"):], "<>")
+				tp.PrintfLine("250 OK")
+			case strings.HasPrefix(cmd, "RCPT TO:"):
+				s.to = strings.Trim(line[len("RCPT TO:"):], "<>")
+				if rcptReply != "" {
+					tp.PrintfLine("%s", rcptReply)
+				} else {
+					tp.PrintfLine("250 OK")
+				}
+			case cmd == "DATA":
+				tp.PrintfLine("354 Go ahead")
+				data, err := tp.ReadDotBytes()
+				if err != nil {
+					return
+				}
+				s.data = string(data)
+				tp.PrintfLine("250 Queued")
+			case cmd == "QUIT":
+				tp.PrintfLine("221 Bye")
+				return
+			default:
+				tp.PrintfLine("502 Not implemented")
+			}
+		}
+	}()
+	return ln.Addr().String(), done
+}
+
+func TestSMTPSender(t *testing.T) {
+	addr, done := serveSMTP(t, "")
+	msg := "Subject: Hi\r\n\r\nHello\r\n.leading dot\r\n"
+	err := SMTPSender{Addr: addr}.Send(context.Background(), "no-reply@example.com", "bob@example.com", []byte(msg))
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	s := <-done
+	if s.from != "no-reply@example.com" || s.to != "bob@example.com" {
+		t.Errorf("envelope = %q -> %q", s.from, s.to)
+	}
+	// ReadDotBytes undoes dot-stuffing and normalises line endings.
+	if want := "Subject: Hi\n\nHello\n.leading dot\n"; s.data != want {
+		t.Errorf("data = %q, want %q", s.data, want)
+	}
+}
+
+func TestSMTPSenderRejectedRecipient(t *testing.T) {
+	addr, _ := serveSMTP(t, "550 No such user")
+	err := SMTPSender{Addr: addr}.Send(context.Background(), "no-reply@example.com", "nobody@example.com", []byte("x"))
+	if err == nil || !strings.Contains(err.Error(), "No such user") {
+		t.Errorf("Send error = %v, want the 550 reply", err)
+	}
+}
+
+func TestSMTPSenderBadAddr(t *testing.T) {
+	if err := (SMTPSender{Addr: "no-port"}).Send(context.Background(), "a@example.com", "b@example.com", nil); err == nil {
+		t.Error("Send accepted an address without a port")
+	}
+}
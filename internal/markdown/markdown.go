@@ -0,0 +1,166 @@
+package markdown
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"log"
+	"regexp"
+	"sync"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+const defaultCacheSize = 4096
+
+// attachmentSrc matches the URLs attachments are served from. Only those are
+// embedded as images, so that viewing a post does not load anything from a
+// host the author picked.
+var attachmentSrc = regexp.MustCompile(`^/attachments/[0-9]+$`)
+
+// Renderer converts CommonMark with GitHub extensions (tables,
+// strikethrough, autolinks, task lists) into sanitized HTML. Output is cached
+// per content revision, keyed by a hash of the source.
+type Renderer struct {
+	md     goldmark.Markdown
+	policy *bluemonday.Policy
+
+	mu    sync.Mutex
+	size  int
+	order *list.List
+	cache map[[sha256.Size]byte]*list.Element
+}
+
+type cacheEntry struct {
+	key  [sha256.Size]byte
+	html string
+}
+
+func NewRenderer(cacheSize int) *Renderer {
+	return &Renderer{
+		md: goldmark.New(
+			goldmark.WithExtensions(
+				extension.NewTable(extension.WithTableCellAlignMethod(extension.TableCellAlignAttribute)),
+				extension.Strikethrough,
+				extension.Linkify,
+				extension.TaskList,
+			),
+			goldmark.WithParserOptions(parser.WithASTTransformers(util.Prioritized(externalImages{}, 100))),
+			// Raw HTML in the source is escaped; the sanitizer is a second line of defence.
+			goldmark.WithRendererOptions(html.WithHardWraps()),
+		),
+		policy: newPolicy(),
+		size:   cacheSize,
+		order:  list.New(),
+		cache:  make(map[[sha256.Size]byte]*list.Element),
+	}
+}
+
+// newPolicy allows only the elements the Markdown renderer produces.
+func newPolicy() *bluemonday.Policy {
+	p := bluemonday.NewPolicy()
+	p.AllowElements("p", "br", "hr", "h1", "h2", "h3", "h4", "h5", "h6",
+		"strong", "em", "del", "code", "pre", "blockquote", "ul", "ol", "li",
+		"table", "thead", "tbody", "tr", "th", "td")
+	p.AllowAttrs("start").Matching(bluemonday.Integer).OnElements("ol")
+	p.AllowAttrs("align").Matching(regexp.MustCompile(`^(left|center|right)$`)).OnElements("th", "td")
+	p.AllowAttrs("type").Matching(regexp.MustCompile(`^checkbox$`)).OnElements("input")
+	p.AllowAttrs("checked", "disabled").OnElements("input")
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^language-[\w+#-]+$`)).OnElements("code")
+	p.AllowAttrs("href").OnElements("a")
+	p.AllowAttrs("src").Matching(attachmentSrc).OnElements("img")
+	p.AllowAttrs("alt", "title").OnElements("img")
+	p.AllowAttrs("title").OnElements("a")
+	p.AllowURLSchemes("http", "https", "mailto")
+	p.AllowRelativeURLs(true)
+	p.RequireParseableURLs(true)
+	p.RequireNoFollowOnLinks(true)
+	p.AddTargetBlankToFullyQualifiedLinks(true)
+	return p
+}
+
+// externalImages turns images that are not attachments into links to the
+// image, keeping the alt text as the link text. Inside a link, where another
+// link cannot nest, only the alt text is kept.
+type externalImages struct{}
+
+func (externalImages) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	var images []*ast.Image
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if img, ok := n.(*ast.Image); ok && entering && !attachmentSrc.Match(img.Destination) {
+			images = append(images, img)
+		}
+		return ast.WalkContinue, nil
+	})
+	for _, img := range images {
+		parent := img.Parent()
+		if inLink(img) {
+			for child := img.FirstChild(); child != nil; child = img.FirstChild() {
+				parent.InsertBefore(parent, img, child)
+			}
+			parent.RemoveChild(parent, img)
+			continue
+		}
+		link := ast.NewLink()
+		link.Destination = img.Destination
+		link.Title = img.Title
+		for child := img.FirstChild(); child != nil; child = img.FirstChild() {
+			link.AppendChild(link, child)
+		}
+		parent.ReplaceChild(parent, img, link)
+	}
+}
+
+func inLink(n ast.Node) bool {
+	for p := n.Parent(); p != nil; p = p.Parent() {
+		if p.Kind() == ast.KindLink || p.Kind() == ast.KindAutoLink {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Renderer) Render(source string) string {
+	key := sha256.Sum256([]byte(source))
+	r.mu.Lock()
+	if el, ok := r.cache[key]; ok {
+		r.order.MoveToFront(el)
+		html := el.Value.(*cacheEntry).html
+		r.mu.Unlock()
+		return html
+	}
+	r.mu.Unlock()
+
+	var buf bytes.Buffer
+	if err := r.md.Convert([]byte(source), &buf); err != nil {
+		log.Printf("WARN: Failed to render markdown: %v", err)
+		return r.policy.Sanitize("<p>" + bluemonday.StrictPolicy().Sanitize(source) + "</p>")
+	}
+	html := r.policy.SanitizeBytes(buf.Bytes())
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.cache[key]; !ok && r.size > 0 {
+		r.cache[key] = r.order.PushFront(&cacheEntry{key: key, html: string(html)})
+		if r.order.Len() > r.size {
+			oldest := r.order.Back()
+			r.order.Remove(oldest)
+			delete(r.cache, oldest.Value.(*cacheEntry).key)
+		}
+	}
+	return string(html)
+}
+
+var defaultRenderer = NewRenderer(defaultCacheSize)
+
+// Render uses a shared renderer with the default cache size.
+func Render(source string) string {
+	return defaultRenderer.Render(source)
+}
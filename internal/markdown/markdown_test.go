@@ -0,0 +1,76 @@
+package markdown
+
+import (
+	"crypto/sha256"
+	"strings"
+	"testing"
+)
+
+func TestRenderSanitizes(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  string
+		want    []string
+		notWant []string
+	}{
+		{"raw script is escaped", "<script>alert(1)</script>", nil, []string{"<script"}},
+		{"inline event handler", `<img src="x" onerror="alert(1)">`, nil, []string{"onerror", "<img"}},
+		{"javascript link", "[click](javascript:alert(1))", []string{"click"}, []string{"javascript:"}},
+		{"data image", "![x](data:image/png;base64,AAAA)", nil, []string{"data:"}},
+		{"attachment image", `![chart](/attachments/12 "Q3")`, []string{`<img src="/attachments/12" alt="chart" title="Q3">`}, nil},
+		{"external image becomes a link", "![cat](https://example.com/cat.png)", []string{`<a href="https://example.com/cat.png"`, ">cat</a>"}, []string{"<img"}},
+		{"relative image outside attachments", "![x](/api/users/me)", []string{`href="/api/users/me"`}, []string{"<img"}},
+		{"attachment path traversal", "![x](/attachments/12/../../api/users/me)", nil, []string{"<img"}},
+		{"linked image keeps its alt text", "[![cat](https://example.com/cat.png)](https://example.com)", []string{`<a href="https://example.com" rel="nofollow noopener" target="_blank">cat</a>`}, []string{"<img", "cat.png"}},
+		{"external link", "[go](https://go.dev)", []string{`href="https://go.dev"`, `rel="nofollow noopener"`, `target="_blank"`}, nil},
+		{"relative link stays in tab", "[post](/posts/1)", []string{`href="/posts/1"`, `rel="nofollow"`}, []string{"_blank"}},
+		{"autolink", "see https://example.com", []string{`<a href="https://example.com"`}, nil},
+		{"code class", "```go\nfmt.Println()\n```", []string{`<code class="language-go">`}, nil},
+		{"code class injection", "```go\" onclick=\"x\n```", nil, []string{"onclick"}},
+		{"table alignment", "| a |\n|:-:|\n| b |", []string{`<th align="center">`, `<td align="center">`}, nil},
+		{"task list", "- [x] done", []string{`<input checked="" disabled="" type="checkbox"`}, nil},
+		{"strikethrough", "~~gone~~", []string{"<del>gone</del>"}, nil},
+		{"hard wraps", "a\nb", []string{"a<br>"}, nil},
+		{"style attribute", `<p style="color:red">x</p>`, nil, []string{"style"}},
+	}
+	r := NewRenderer(0)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := r.Render(tt.source)
+			for _, w := range tt.want {
+				if !strings.Contains(got, w) {
+					t.Errorf("Render(%q) = %q, want it to contain %q", tt.source, got, w)
+				}
+			}
+			for _, w := range tt.notWant {
+				if strings.Contains(got, w) {
+					t.Errorf("Render(%q) = %q, must not contain %q", tt.source, got, w)
+				}
+			}
+		})
+	}
+}
+
+func TestRenderCache(t *testing.T) {
+	r := NewRenderer(2)
+	first := r.Render("*a*")
+	if got := r.Render("*a*"); got != first {
+		t.Errorf("cached render = %q, want %q", got, first)
+	}
+	r.Render("*b*")
+	r.Render("*c*")
+	if r.order.Len() != 2 {
+		t.Errorf("cache holds %d entries, want 2", r.order.Len())
+	}
+	if _, ok := r.cache[sha256.Sum256([]byte("*a*"))]; ok {
+		t.Error("least recently used entry was not evicted")
+	}
+}
+
+func TestRenderWithoutCache(t *testing.T) {
+	r := NewRenderer(0)
+	r.Render("*a*")
+	if r.order.Len() != 0 {
+		t.Errorf("cache holds %d entries, want 0", r.order.Len())
+	}
+}
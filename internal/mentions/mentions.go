@@ -0,0 +1,140 @@
+package mentions
+
+import (
+	"regexp"
+	"strings"
+)
+
+// MaxPerContent caps how many distinct users one post or comment can mention,
+// so a single message cannot notify the whole forum.
+const MaxPerContent = 20
+
+// mentionPattern matches @username where the @ is not part of a word, email
+// address or URL path. Usernames that use other characters cannot be mentioned.
+var mentionPattern = regexp.MustCompile(`(^|[^\w@/.])@([A-Za-z0-9_][A-Za-z0-9_.-]{2,49})`)
+
+// Parse returns the distinct usernames mentioned in Markdown content, in order
+// of first appearance. Mentions inside fenced code blocks, indented code
+// blocks and inline code spans are ignored. It has no side effects, so edits
+// can re-parse the new content and compare.
+func Parse(content string) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, line := range proseLines(content) {
+		for _, m := range mentionPattern.FindAllStringSubmatch(stripCodeSpans(line), -1) {
+			name := strings.TrimRight(m[2], ".-")
+			if len(name) < 3 || seen[name] {
+				continue
+			}
+			seen[name] = true
+			names = append(names, name)
+			if len(names) == MaxPerContent {
+				return names
+			}
+		}
+	}
+	return names
+}
+
+// proseLines returns the lines of content that are outside code blocks.
+func proseLines(content string) []string {
+	var (
+		out      []string
+		fence    string
+		prevText bool
+	)
+	for _, line := range strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n") {
+		trimmed := strings.TrimLeft(line, " ")
+		indent := len(line) - len(trimmed)
+		if fence != "" {
+			if indent < 4 && strings.HasPrefix(trimmed, fence) && strings.Trim(trimmed, fence[:1]+" \t") == "" {
+				fence = ""
+			}
+			continue
+		}
+		if indent < 4 {
+			if f := fenceOpener(trimmed); f != "" {
+				fence = f
+				prevText = false
+				continue
+			}
+		}
+		if strings.TrimSpace(line) == "" {
+			prevText = false
+			continue
+		}
+		// Indented lines only start a code block when they don't continue a
+		// paragraph.
+		if !prevText && (indent >= 4 || strings.HasPrefix(trimmed, "\t")) {
+			continue
+		}
+		out = append(out, line)
+		prevText = true
+	}
+	return out
+}
+
+// fenceOpener returns the fence a line opens (a run of at least three
+// backticks or tildes), or "".
+func fenceOpener(line string) string {
+	for _, ch := range []string{"`", "~"} {
+		n := 0
+		for n < len(line) && line[n] == ch[0] {
+			n++
+		}
+		if n >= 3 {
+			// Backtick fences cannot have backticks in their info string.
+			if ch == "`" && strings.Contains(line[n:], "`") {
+				return ""
+			}
+			return line[:n]
+		}
+	}
+	return ""
+}
+
+// stripCodeSpans blanks out inline code spans. A span opened by a run of N
+// backticks closes at the next run of exactly N; unmatched runs are literal.
+func stripCodeSpans(line string) string {
+	if !strings.Contains(line, "`") {
+		return line
+	}
+	b := []byte(line)
+	for i := 0; i < len(b); {
+		if b[i] != '`' {
+			i++
+			continue
+		}
+		n := runLength(b, i)
+		closeAt := -1
+		for j := i + n; j < len(b); {
+			if b[j] != '`' {
+				j++
+				continue
+			}
+			m := runLength(b, j)
+			if m == n {
+				closeAt = j
+				break
+			}
+			j += m
+		}
+		if closeAt < 0 {
+			i += n
+			continue
+		}
+		for k := i; k < closeAt+n; k++ {
+			b[k] = ' '
+		}
+		i = closeAt + n
+	}
+	return string(b)
+}
+
+func runLength(b []byte, i int) int {
+	n := 0
+	for i+n < len(b) && b[i+n] == '`' {
+		n++
+	}
+	return n
+}
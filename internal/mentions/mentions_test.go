@@ -0,0 +1,57 @@
+package mentions
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{"none", "hello world", nil},
+		{"single", "thanks @alice!", []string{"alice"}},
+		{"start of content", "@bob see above", []string{"bob"}},
+		{"distinct in order", "@carol and @dave, then @carol again", []string{"carol", "dave"}},
+		{"trailing punctuation", "ask @erin. Or @frank-", []string{"erin", "frank"}},
+		{"dots inside name", "cc @j.doe", []string{"j.doe"}},
+		{"too short", "@ab is not a user", nil},
+		{"email address", "mail me at me@example.com", nil},
+		{"url path", "see https://example.com/@alice", nil},
+		{"double at", "@@alice", nil},
+		{"inline code", "run `@alice` or ``x ` @bob`` but ping @carol", []string{"carol"}},
+		{"unmatched backtick", "a ` @alice", []string{"alice"}},
+		{"fenced code", "```\n@alice\n```\n@bob", []string{"bob"}},
+		{"tilde fence", "~~~go\n@alice\n~~~\n@bob", []string{"bob"}},
+		{"unclosed fence hides the rest", "```\n@alice", nil},
+		{"shorter fence does not close", "````\n```\n@alice\n````\n@bob", []string{"bob"}},
+		{"indented code block", "text\n\n    @alice\n\n@bob", []string{"bob"}},
+		{"indented paragraph continuation", "text\n    @alice", []string{"alice"}},
+		{"crlf", "```\r\n@alice\r\n```\r\n@bob", []string{"bob"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Parse(tt.content); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse(%q) = %q, want %q", tt.content, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCapsMentions(t *testing.T) {
+	var b strings.Builder
+	for i := 0; i < MaxPerContent+5; i++ {
+		fmt.Fprintf(&b, "@user%d ", i)
+	}
+	got := Parse(b.String())
+	if len(got) != MaxPerContent {
+		t.Fatalf("Parse returned %d names, want %d", len(got), MaxPerContent)
+	}
+	if got[0] != "user0" || got[MaxPerContent-1] != fmt.Sprintf("user%d", MaxPerContent-1) {
+		t.Errorf("Parse kept %q, want the first %d mentions", got, MaxPerContent)
+	}
+}
@@ -1,14 +1,43 @@
 package middleware
 
 import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"log"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/v1-nce/threadtalk-backend/internal/utils"
 )
 
-func AuthMiddleware() gin.HandlerFunc {
+var errInvalidBotToken = errors.New("invalid bot token")
+
+// AuthMiddleware accepts a user's auth cookie or a bot's token in an
+// "Authorization: Bearer" header. Bots set isBot as well as userID.
+func AuthMiddleware(db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		if token, ok := bearerToken(c); ok {
+			botID, err := lookupBotToken(c.Request.Context(), db, token)
+			if err != nil {
+				if err != errInvalidBotToken {
+					log.Printf("ERROR: Failed to look up bot token: %v", err)
+					c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Authentication failed"})
+					return
+				}
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+				return
+			}
+			c.Set("userID", botID)
+			c.Set("isBot", true)
+			c.Next()
+			return
+		}
+
 		tokenString, err := c.Cookie("auth_token")
 		if err != nil {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authorization cookie missing"})
@@ -25,4 +54,62 @@ func AuthMiddleware() gin.HandlerFunc {
 		c.Set("userID", userID)
 		c.Next()
 	}
-}
\ No newline at end of file
+}
+
+// OptionalAuthMiddleware sets userID when a valid auth cookie or bot token is
+// present but lets anonymous requests through, for public routes that
+// personalise their response.
+func OptionalAuthMiddleware(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token, ok := bearerToken(c); ok {
+			if botID, err := lookupBotToken(c.Request.Context(), db, token); err == nil {
+				c.Set("userID", botID)
+				c.Set("isBot", true)
+			}
+		} else if tokenString, err := c.Cookie("auth_token"); err == nil {
+			if userID, err := utils.ParseToken(tokenString); err == nil {
+				c.Set("userID", userID)
+			}
+		}
+		c.Next()
+	}
+}
+
+func bearerToken(c *gin.Context) (string, bool) {
+	header := c.GetHeader("Authorization")
+	if len(header) < 7 || !strings.EqualFold(header[:7], "Bearer ") {
+		return "", false
+	}
+	return strings.TrimSpace(header[7:]), true
+}
+
+// lookupBotToken returns the bot a token belongs to. Tokens are stored as
+// SHA-256 hashes; last_used_at is refreshed at most once a minute so that
+// busy bots do not write on every request.
+func lookupBotToken(ctx context.Context, db *sql.DB, token string) (int64, error) {
+	if len(token) != 64 {
+		return 0, errInvalidBotToken
+	}
+	if _, err := hex.DecodeString(token); err != nil {
+		return 0, errInvalidBotToken
+	}
+	sum := sha256.Sum256([]byte(token))
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	var botID int64
+	err := db.QueryRowContext(ctx, `
+		WITH t AS (
+			SELECT t.id, t.bot_user_id, t.last_used_at FROM bot_tokens t
+			JOIN users u ON u.id = t.bot_user_id AND u.disabled_at IS NULL
+			WHERE t.token_hash = $1
+		), touched AS (
+			UPDATE bot_tokens b SET last_used_at = CURRENT_TIMESTAMP
+			FROM t
+			WHERE b.id = t.id AND (t.last_used_at IS NULL OR t.last_used_at < CURRENT_TIMESTAMP - INTERVAL '1 minute')
+		)
+		SELECT bot_user_id FROM t`, hex.EncodeToString(sum[:])).Scan(&botID)
+	if err == sql.ErrNoRows {
+		return 0, errInvalidBotToken
+	}
+	return botID, err
+}
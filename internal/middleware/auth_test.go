@@ -0,0 +1,83 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func testContext(header string) (*gin.Context, *httptest.ResponseRecorder) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/api/posts", nil)
+	if header != "" {
+		c.Request.Header.Set("Authorization", header)
+	}
+	return c, w
+}
+
+func TestBearerToken(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+		ok     bool
+	}{
+		{"", "", false},
+		{"Basic dXNlcjpwYXNz", "", false},
+		{"Bearer", "", false},
+		{"Bearer abc", "abc", true},
+		{"bearer  abc ", "abc", true},
+		{"BEARER abc", "abc", true},
+	}
+	for _, tt := range tests {
+		c, _ := testContext(tt.header)
+		got, ok := bearerToken(c)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("bearerToken(%q) = %q, %t; want %q, %t", tt.header, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestLookupBotTokenRejectsMalformedTokens(t *testing.T) {
+	// Malformed tokens are rejected before the database is consulted, so a
+	// nil *sql.DB is never touched.
+	for _, token := range []string{
+		"",
+		"abc",
+		strings.Repeat("a", 63),
+		strings.Repeat("a", 65),
+		strings.Repeat("g", 64),
+	} {
+		if _, err := lookupBotToken(context.Background(), nil, token); err != errInvalidBotToken {
+			t.Errorf("lookupBotToken(%q) error = %v, want errInvalidBotToken", token, err)
+		}
+	}
+}
+
+func TestAuthMiddlewareRejectsMalformedBearer(t *testing.T) {
+	c, w := testContext("Bearer not-a-token")
+	c.Request.AddCookie(&http.Cookie{Name: "auth_token", Value: "ignored"})
+	AuthMiddleware(nil)(c)
+	if w.Code != http.StatusUnauthorized || !c.IsAborted() {
+		t.Errorf("status = %d, aborted = %t; want 401 and aborted", w.Code, c.IsAborted())
+	}
+	if _, ok := c.Get("userID"); ok {
+		t.Error("userID set for a rejected token")
+	}
+}
+
+func TestOptionalAuthMiddlewareIgnoresMalformedBearer(t *testing.T) {
+	c, w := testContext("Bearer not-a-token")
+	OptionalAuthMiddleware(nil)(c)
+	if c.IsAborted() || w.Code != http.StatusOK {
+		t.Errorf("status = %d, aborted = %t; want the request to continue", w.Code, c.IsAborted())
+	}
+	if _, ok := c.Get("userID"); ok || c.GetBool("isBot") {
+		t.Error("identity set for a malformed token")
+	}
+}
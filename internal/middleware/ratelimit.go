@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"fmt"
 	"net/http"
 	"sync"
 	"time"
@@ -10,7 +11,9 @@ import (
 )
 
 type RateLimiter struct {
-	ips      sync.Map
+	visitors sync.Map
+	key      func(c *gin.Context) string
+	bots     bool
 	limit    rate.Limit
 	burst    int
 	stopChan chan struct{}
@@ -23,7 +26,37 @@ type visitor struct {
 }
 
 func NewRateLimiter(r rate.Limit, b int) *RateLimiter {
+	return newRateLimiter(r, b, func(c *gin.Context) string {
+		return c.ClientIP()
+	})
+}
+
+// NewUserRateLimiter limits each authenticated user separately, falling back
+// to the client IP when no user is set. It must run after AuthMiddleware.
+func NewUserRateLimiter(r rate.Limit, b int) *RateLimiter {
+	return newRateLimiter(r, b, func(c *gin.Context) string {
+		if userID, ok := c.Get("userID"); ok {
+			return fmt.Sprintf("user:%v", userID)
+		}
+		return c.ClientIP()
+	})
+}
+
+// NewBotRateLimiter limits each bot separately. Bots are exempt from the
+// other limiters, which are sized for people, so this one sets their budget.
+// It must run after AuthMiddleware.
+func NewBotRateLimiter(r rate.Limit, b int) *RateLimiter {
+	rl := newRateLimiter(r, b, func(c *gin.Context) string {
+		userID, _ := c.Get("userID")
+		return fmt.Sprintf("bot:%v", userID)
+	})
+	rl.bots = true
+	return rl
+}
+
+func newRateLimiter(r rate.Limit, b int, key func(c *gin.Context) string) *RateLimiter {
 	rl := &RateLimiter{
+		key:      key,
 		limit:    r,
 		burst:    b,
 		stopChan: make(chan struct{}),
@@ -40,13 +73,17 @@ func (rl *RateLimiter) Stop() {
 
 func (rl *RateLimiter) Middleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		ip := c.ClientIP()
-		v, ok := rl.ips.Load(ip)
+		if c.GetBool("isBot") != rl.bots {
+			c.Next()
+			return
+		}
+		key := rl.key(c)
+		v, ok := rl.visitors.Load(key)
 
 		if !ok {
 			limiter := rate.NewLimiter(rl.limit, rl.burst)
 			v = &visitor{limiter: limiter, lastSeen: time.Now()}
-			rl.ips.Store(ip, v)
+			rl.visitors.Store(key, v)
 		}
 
 		vis := v.(*visitor)
@@ -72,10 +109,10 @@ func (rl *RateLimiter) cleanup() {
 		case <-rl.stopChan:
 			return
 		case <-ticker.C:
-			rl.ips.Range(func(key, value interface{}) bool {
+			rl.visitors.Range(func(key, value interface{}) bool {
 				v := value.(*visitor)
 				if time.Since(v.lastSeen) > 3*time.Minute {
-					rl.ips.Delete(key)
+					rl.visitors.Delete(key)
 				}
 				return true
 			})
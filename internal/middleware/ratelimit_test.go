@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"net/http"
+	"testing"
+)
+
+// hit runs one request through rl as the given user and reports its status.
+func hit(rl *RateLimiter, userID int64, bot bool) int {
+	c, w := testContext("")
+	c.Set("userID", userID)
+	if bot {
+		c.Set("isBot", true)
+	}
+	rl.Middleware()(c)
+	if c.IsAborted() {
+		return w.Code
+	}
+	return http.StatusOK
+}
+
+func TestRateLimitersSplitBotsFromPeople(t *testing.T) {
+	users := NewUserRateLimiter(0, 1)
+	defer users.Stop()
+	bots := NewBotRateLimiter(0, 1)
+	defer bots.Stop()
+
+	// Each limiter allows one request per key and ignores the other kind.
+	for i := 0; i < 3; i++ {
+		if got := hit(users, 1, true); got != http.StatusOK {
+			t.Fatalf("user limiter limited a bot: %d", got)
+		}
+		if got := hit(bots, 1, false); got != http.StatusOK {
+			t.Fatalf("bot limiter limited a person: %d", got)
+		}
+	}
+	if got := hit(bots, 7, true); got != http.StatusOK {
+		t.Fatalf("first bot request = %d, want 200", got)
+	}
+	if got := hit(bots, 7, true); got != http.StatusTooManyRequests {
+		t.Errorf("second bot request = %d, want 429", got)
+	}
+	if got := hit(bots, 8, true); got != http.StatusOK {
+		t.Errorf("another bot shares the budget: %d", got)
+	}
+	if got := hit(users, 7, false); got != http.StatusOK {
+		t.Errorf("person with the bot's ID shares its budget: %d", got)
+	}
+	if got := hit(users, 7, false); got != http.StatusTooManyRequests {
+		t.Errorf("second user request = %d, want 429", got)
+	}
+}
@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/v1-nce/threadtalk-backend/internal/models"
+)
+
+// ModeratorMiddleware must run after AuthMiddleware. It loads the caller's
+// role and rejects anyone who is not a moderator or admin.
+func ModeratorMiddleware(db *sql.DB) gin.HandlerFunc {
+	return requireRole(db, models.IsModeratorRole, "Moderator access required")
+}
+
+// AdminMiddleware must run after AuthMiddleware. It rejects anyone who is not
+// an admin.
+func AdminMiddleware(db *sql.DB) gin.HandlerFunc {
+	return requireRole(db, func(role string) bool { return role == models.RoleAdmin }, "Admin access required")
+}
+
+func requireRole(db *sql.DB, allowed func(role string) bool, denied string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := c.Get("userID")
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 2*time.Second)
+		defer cancel()
+		var role string
+		if err := db.QueryRowContext(ctx, `SELECT role FROM users WHERE id = $1`, userID).Scan(&role); err != nil {
+			if err == sql.ErrNoRows {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			} else {
+				log.Printf("ERROR: Failed to load role for user %v: %v", userID, err)
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify permissions"})
+			}
+			return
+		}
+		if !allowed(role) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": denied})
+			return
+		}
+		c.Set("userRole", role)
+		c.Next()
+	}
+}
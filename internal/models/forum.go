@@ -1,36 +1,248 @@
 package models
 
 import (
+	"encoding/json"
+	"strconv"
 	"time"
 )
 
 type Topic struct {
-	ID          int64     `json:"id"`
-	Name        string    `json:"name" binding:"required,max=50"`
-	Description string    `json:"description" binding:"max=600"`
-	CreatedAt   time.Time `json:"created_at"`
+	ID             int64      `json:"id"`
+	Name           string     `json:"name" binding:"required,max=50"`
+	Slug           string     `json:"slug"`
+	Description    string     `json:"description" binding:"max=600"`
+	Kind           string     `json:"kind" binding:"omitempty,oneof=discussion question"`
+	CategoryID     *int64     `json:"category_id"`
+	Position       int        `json:"position"`
+	PostCount      int        `json:"post_count"`
+	LastActivityAt *time.Time `json:"last_activity_at"`
+	ArchivedAt     *time.Time `json:"archived_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+const (
+	TopicKindDiscussion = "discussion"
+	TopicKindQuestion   = "question"
+)
+
+type TopicUpdate struct {
+	Name        *string `json:"name" binding:"omitempty,min=1,max=50"`
+	Description *string `json:"description" binding:"omitempty,max=600"`
+	Kind        *string `json:"kind" binding:"omitempty,oneof=discussion question"`
+	CategoryID  *int64  `json:"category_id"`
+	Position    *int    `json:"position"`
+	Archived    *bool   `json:"archived"`
 }
 
 type Post struct {
-	ID           int64      `json:"id,string"`
-	Title        string     `json:"title" binding:"required,min=5,max=250"`
-	Content      string     `json:"content" binding:"max=600"`
-	UserID       int64      `json:"user_id,string"`
-	TopicID      int64      `json:"topic_id,string" binding:"required"`
-	CreatedAt    time.Time  `json:"created_at"`
-	Username     string     `json:"username,omitempty"`
-	CommentCount int        `json:"comment_count"`
-	DeletedAt    *time.Time `json:"deleted_at,omitempty"`
+	ID                int64        `json:"id,string"`
+	Title             string       `json:"title" binding:"required,min=5,max=250"`
+	Content           string       `json:"content" binding:"max=600"`
+	ContentHTML       string       `json:"content_html"`
+	UserID            int64        `json:"user_id,string"`
+	TopicID           int64        `json:"topic_id,string" binding:"required"`
+	CreatedAt         time.Time    `json:"created_at"`
+	Username          string       `json:"username,omitempty"`
+	IsBot             bool         `json:"is_bot"`
+	CommentCount      int          `json:"comment_count"`
+	LastCommentAt     *time.Time   `json:"last_comment_at"`
+	Tags              []string     `json:"tags" binding:"max=5"`
+	PinnedAt          *time.Time   `json:"pinned_at,omitempty"`
+	LockedAt          *time.Time   `json:"locked_at,omitempty"`
+	RedirectPostID    *int64       `json:"redirect_post_id,string,omitempty"`
+	Deleted           bool         `json:"deleted,omitempty"`
+	DeletedAt         *time.Time   `json:"deleted_at,omitempty"`
+	Reactions         []Reaction   `json:"reactions,omitempty"`
+	AcceptedCommentID *int64       `json:"accepted_comment_id,string,omitempty"`
+	Poll              *Poll        `json:"poll,omitempty"`
+	DraftID           *int64       `json:"draft_id,string,omitempty"`
+	Attachments       []Attachment `json:"attachments,omitempty"`
 }
 
 type Comment struct {
-	ID        int64      `json:"id,string"`
-	Content   string     `json:"content" binding:"required,max=2000"`
-	UserID    int64      `json:"user_id,string"`
-	PostID    int64      `json:"post_id,string" binding:"required"`
-	ParentID  *int64     `json:"parent_id,string"`
-	CreatedAt time.Time  `json:"created_at"`
-	Username  string     `json:"username,omitempty"`
-	Children  []*Comment `json:"children,omitempty"`
-	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	ID             int64        `json:"id,string"`
+	Content        string       `json:"content" binding:"required,max=2000"`
+	ContentHTML    string       `json:"content_html"`
+	UserID         int64        `json:"user_id,string"`
+	PostID         int64        `json:"post_id,string" binding:"required"`
+	ParentID       *int64       `json:"parent_id,string"`
+	CreatedAt      time.Time    `json:"created_at"`
+	Username       string       `json:"username,omitempty"`
+	IsBot          bool         `json:"is_bot"`
+	Children       []*Comment   `json:"children,omitempty"`
+	RedirectPostID *int64       `json:"redirect_post_id,string,omitempty"`
+	Deleted        bool         `json:"deleted,omitempty"`
+	DeletedAt      *time.Time   `json:"deleted_at,omitempty"`
+	Reactions      []Reaction   `json:"reactions,omitempty"`
+	Accepted       bool         `json:"accepted,omitempty"`
+	DraftID        *int64       `json:"draft_id,string,omitempty"`
+	Attachments    []Attachment `json:"attachments,omitempty"`
+}
+
+// Reaction is the aggregate for one emoji on a post or comment. Reacted is
+// true when the caller is among the users who added it.
+type Reaction struct {
+	Emoji   string `json:"emoji"`
+	Count   int    `json:"count"`
+	Reacted bool   `json:"reacted"`
+}
+
+// Poll is attached to at most one post. Vote counts are only filled in once
+// ResultsVisible is set: after the caller has voted or the poll has closed.
+type Poll struct {
+	Question       string       `json:"question" binding:"required,max=250"`
+	Options        []PollOption `json:"options" binding:"required,min=2,max=10,dive"`
+	MultipleChoice bool         `json:"multiple_choice"`
+	PublicVotes    bool         `json:"public_votes"`
+	ClosesAt       *time.Time   `json:"closes_at"`
+	Closed         bool         `json:"closed"`
+	Voted          bool         `json:"voted"`
+	ResultsVisible bool         `json:"results_visible"`
+	TotalVoters    *int         `json:"total_voters,omitempty"`
+}
+
+type PollOption struct {
+	ID     int64    `json:"id,string"`
+	Label  string   `json:"label" binding:"required,max=100"`
+	Votes  *int     `json:"votes,omitempty"`
+	Voted  bool     `json:"voted,omitempty"`
+	Voters []string `json:"voters,omitempty"`
+}
+
+type PollVoteInput struct {
+	OptionIDs StringIDs `json:"option_ids" binding:"required,min=1,max=10"`
+}
+
+// StringIDs is a list of IDs encoded as JSON strings, like the single IDs
+// tagged ",string" elsewhere.
+type StringIDs []int64
+
+func (ids StringIDs) MarshalJSON() ([]byte, error) {
+	strs := make([]string, len(ids))
+	for i, id := range ids {
+		strs[i] = strconv.FormatInt(id, 10)
+	}
+	return json.Marshal(strs)
+}
+
+func (ids *StringIDs) UnmarshalJSON(data []byte) error {
+	var strs []string
+	if err := json.Unmarshal(data, &strs); err != nil {
+		return err
+	}
+	if strs == nil {
+		*ids = nil
+		return nil
+	}
+	parsed := make(StringIDs, len(strs))
+	for i, s := range strs {
+		id, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return err
+		}
+		parsed[i] = id
+	}
+	*ids = parsed
+	return nil
+}
+
+const (
+	DraftKindPost    = "post"
+	DraftKindComment = "comment"
+)
+
+// Draft is an unfinished post or comment. Post drafts with ScheduledAt set are
+// published by the background publisher once that time passes.
+type Draft struct {
+	ID          int64      `json:"id,string"`
+	Kind        string     `json:"kind" binding:"required,oneof=post comment"`
+	Title       string     `json:"title" binding:"max=250"`
+	Content     string     `json:"content" binding:"max=2000"`
+	TopicID     *int64     `json:"topic_id,string"`
+	PostID      *int64     `json:"post_id,string"`
+	ParentID    *int64     `json:"parent_id,string"`
+	Tags        []string   `json:"tags" binding:"max=5"`
+	ScheduledAt *time.Time `json:"scheduled_at"`
+	LastError   string     `json:"last_error,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+type Attachment struct {
+	ID          int64     `json:"id,string"`
+	Filename    string    `json:"filename"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	URL         string    `json:"url"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type Tag struct {
+	Name      string `json:"name"`
+	PostCount int    `json:"post_count"`
+}
+
+type Category struct {
+	ID              int64       `json:"id"`
+	Name            string      `json:"name" binding:"required,max=50"`
+	Slug            string      `json:"slug"`
+	Description     string      `json:"description" binding:"max=600"`
+	ParentID        *int64      `json:"parent_id"`
+	Position        int         `json:"position"`
+	TopicCount      int         `json:"topic_count"`
+	TotalTopicCount int         `json:"total_topic_count"`
+	Children        []*Category `json:"children"`
+	CreatedAt       time.Time   `json:"created_at"`
+}
+
+type CategoryUpdate struct {
+	Name        *string `json:"name" binding:"omitempty,min=1,max=50"`
+	Description *string `json:"description" binding:"omitempty,max=600"`
+	ParentID    *int64  `json:"parent_id"`
+	Position    *int    `json:"position"`
+}
+
+type MovePostInput struct {
+	TopicID int64 `json:"topic_id,string" binding:"required"`
+}
+
+type MergePostInput struct {
+	TargetPostID int64 `json:"target_post_id,string" binding:"required"`
+}
+
+type AcceptAnswerInput struct {
+	CommentID int64 `json:"comment_id,string" binding:"required"`
+}
+
+type SplitCommentInput struct {
+	Title   string `json:"title" binding:"required,min=5,max=250"`
+	Content string `json:"content" binding:"max=600"`
+	TopicID int64  `json:"topic_id,string"`
+}
+
+// Notification is an entry in a user's notification center. Actor is null
+// for moderation notices and for accounts that no longer exist.
+type Notification struct {
+	ID        int64     `json:"id,string"`
+	Kind      string    `json:"kind"`
+	Action    string    `json:"action,omitempty"`
+	Actor     *string   `json:"actor"`
+	PostID    *int64    `json:"post_id,string,omitempty"`
+	PostTitle string    `json:"post_title,omitempty"`
+	CommentID *int64    `json:"comment_id,string,omitempty"`
+	Read      bool      `json:"read"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Subscription follows a post or a topic. Exactly one of PostID and TopicID
+// is set.
+type Subscription struct {
+	ID        int64     `json:"id,string"`
+	PostID    *int64    `json:"post_id,string,omitempty"`
+	PostTitle string    `json:"post_title,omitempty"`
+	TopicID   *int64    `json:"topic_id,string,omitempty"`
+	TopicName string    `json:"topic_name,omitempty"`
+	Level     string    `json:"level" binding:"required,oneof=all replies muted"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
 }
@@ -6,11 +6,54 @@ type User struct {
 	ID int64 `json:"id"`
 	Username string `json:"username"`
 	Password string `json:"-"`
+	Role string `json:"role"`
+	IsBot bool `json:"is_bot"`
+	AcceptedAnswers int `json:"accepted_answers"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
 
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+func IsModeratorRole(role string) bool {
+	return role == RoleModerator || role == RoleAdmin
+}
+
 type AuthInput struct {
 	Username string `json:"username" binding:"required"`
 	Password string `json:"password" binding:"required"`
-}
\ No newline at end of file
+}
+type BlockedUser struct {
+	Username string `json:"username"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type EmailPreferences struct {
+	Email string `json:"email" binding:"required,email,max=254"`
+	Frequency string `json:"frequency" binding:"required,oneof=off immediate daily weekly"`
+	Verified bool `json:"verified"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+}
+
+// Bot is an account that acts through API tokens instead of a password. Bots
+// created by a user are managed by that user; bots behind incoming webhooks
+// have no owner.
+type Bot struct {
+	ID int64 `json:"id,string"`
+	Username string `json:"username" binding:"required,min=3,max=50"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// BotToken authenticates a bot as "Authorization: Bearer <token>". Token is
+// only returned when it is created.
+type BotToken struct {
+	ID int64 `json:"id,string"`
+	Name string `json:"name" binding:"required,max=100"`
+	Token string `json:"token,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
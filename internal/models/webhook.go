@@ -0,0 +1,71 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Webhook is an admin-configured endpoint that receives signed event
+// payloads. Secret is only returned when it is created or rotated.
+type Webhook struct {
+	ID          int64     `json:"id,string"`
+	URL         string    `json:"url" binding:"required,url,max=2000"`
+	Description string    `json:"description" binding:"max=200"`
+	Events      []string  `json:"events" binding:"required,min=1,dive,oneof=post.created post.deleted comment.created"`
+	Active      bool      `json:"active"`
+	Secret      string    `json:"secret,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+type WebhookUpdate struct {
+	URL          *string  `json:"url" binding:"omitempty,url,max=2000"`
+	Description  *string  `json:"description" binding:"omitempty,max=200"`
+	Events       []string `json:"events" binding:"omitempty,min=1,dive,oneof=post.created post.deleted comment.created"`
+	Active       *bool    `json:"active"`
+	RotateSecret bool     `json:"rotate_secret"`
+}
+
+// WebhookDelivery is an entry in a webhook's delivery log. Status is
+// "pending" (queued or waiting to retry), "delivered" or "dead" (gave up
+// after the maximum number of attempts).
+type WebhookDelivery struct {
+	ID             int64           `json:"id,string"`
+	Event          string          `json:"event"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	ResponseStatus *int            `json:"response_status"`
+	LastError      string          `json:"last_error,omitempty"`
+	NextAttemptAt  *time.Time      `json:"next_attempt_at,omitempty"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	Payload        json.RawMessage `json:"payload"`
+}
+
+// IncomingWebhook lets an external system post into a topic as a bot user.
+// Token and URL are only returned when the webhook is created or its token
+// is rotated.
+type IncomingWebhook struct {
+	ID              int64      `json:"id,string"`
+	TopicID         int64      `json:"topic_id,string" binding:"required"`
+	Name            string     `json:"name" binding:"required,max=100"`
+	BotUsername     string     `json:"bot_username" binding:"required,min=3,max=50"`
+	TitleTemplate   string     `json:"title_template" binding:"max=2000"`
+	ContentTemplate string     `json:"content_template" binding:"max=2000"`
+	RateLimit       int        `json:"rate_limit" binding:"omitempty,min=1,max=600"`
+	Active          bool       `json:"active"`
+	Token           string     `json:"token,omitempty"`
+	URL             string     `json:"url,omitempty"`
+	LastUsedAt      *time.Time `json:"last_used_at"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+type IncomingWebhookUpdate struct {
+	Name            *string `json:"name" binding:"omitempty,min=1,max=100"`
+	TitleTemplate   *string `json:"title_template" binding:"omitempty,max=2000"`
+	ContentTemplate *string `json:"content_template" binding:"omitempty,max=2000"`
+	RateLimit       *int    `json:"rate_limit" binding:"omitempty,min=1,max=600"`
+	Active          *bool   `json:"active"`
+	RotateToken     bool    `json:"rotate_token"`
+}
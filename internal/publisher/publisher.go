@@ -0,0 +1,286 @@
+package publisher
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"expvar"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/v1-nce/threadtalk-backend/internal/db"
+	"github.com/v1-nce/threadtalk-backend/internal/realtime"
+)
+
+type Config struct {
+	Interval  time.Duration
+	BatchSize int
+}
+
+// ConfigFromEnv reads PUBLISH_INTERVAL (0 disables the worker) and
+// PUBLISH_BATCH_SIZE.
+func ConfigFromEnv() Config {
+	cfg := Config{
+		Interval:  30 * time.Second,
+		BatchSize: 100,
+	}
+	if s := os.Getenv("PUBLISH_INTERVAL"); s != "" {
+		if v, err := time.ParseDuration(s); err == nil && v >= 0 {
+			cfg.Interval = v
+		} else {
+			log.Printf("WARN: Ignoring invalid PUBLISH_INTERVAL %q", s)
+		}
+	}
+	if v, err := strconv.Atoi(os.Getenv("PUBLISH_BATCH_SIZE")); err == nil && v > 0 {
+		cfg.BatchSize = v
+	}
+	return cfg
+}
+
+type Result struct {
+	Published int64
+	Failed    int64
+}
+
+var metrics = expvar.NewMap("publisher")
+
+// Publisher turns scheduled post drafts into posts once their scheduled_at
+// passes. Drafts that can no longer be published (missing or archived topic,
+// or any other error that is not transient) are unscheduled and keep the
+// reason in last_error for their author.
+type Publisher struct {
+	db       *sql.DB
+	cfg      Config
+	stopChan chan struct{}
+	stopped  sync.Once
+	done     chan struct{}
+}
+
+func NewPublisher(db *sql.DB, cfg Config) *Publisher {
+	return &Publisher{
+		db:       db,
+		cfg:      cfg,
+		stopChan: make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+func (p *Publisher) Start() {
+	if p.cfg.Interval == 0 {
+		log.Println("Draft publisher disabled")
+		close(p.done)
+		return
+	}
+	log.Printf("Draft publisher started (interval %s)", p.cfg.Interval)
+	go p.loop()
+}
+
+func (p *Publisher) Stop() {
+	p.stopped.Do(func() {
+		close(p.stopChan)
+	})
+	<-p.done
+}
+
+func (p *Publisher) loop() {
+	defer close(p.done)
+	ticker := time.NewTicker(p.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		p.runLogged()
+		select {
+		case <-p.stopChan:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *Publisher) runLogged() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	go func() {
+		select {
+		case <-p.stopChan:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	res, err := p.RunOnce(ctx)
+	metrics.Add("runs", 1)
+	metrics.Add("published", res.Published)
+	metrics.Add("failed", res.Failed)
+	lastErr := new(expvar.String)
+	if err != nil {
+		lastErr.Set(err.Error())
+		log.Printf("ERROR: Draft publisher run failed: %v", err)
+	}
+	metrics.Set("last_error", lastErr)
+	if res != (Result{}) {
+		log.Printf("Published %d scheduled drafts (%d failed)", res.Published, res.Failed)
+	}
+}
+
+// RunOnce publishes up to BatchSize due drafts, each in its own transaction.
+// It stops early only on transient errors; other failures count as Failed.
+func (p *Publisher) RunOnce(ctx context.Context) (Result, error) {
+	var res Result
+	for i := 0; i < p.cfg.BatchSize; i++ {
+		found, published, err := p.publishNext(ctx)
+		if err != nil {
+			return res, err
+		}
+		if !found {
+			break
+		}
+		if published {
+			res.Published++
+		} else {
+			res.Failed++
+		}
+	}
+	return res, nil
+}
+
+type draft struct {
+	ID      int64
+	UserID  int64
+	Title   string
+	Content string
+	TopicID *int64
+	Tags    string
+}
+
+func (p *Publisher) publishNext(ctx context.Context) (found, published bool, err error) {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, false, err
+	}
+	defer tx.Rollback()
+	var d draft
+	err = tx.QueryRowContext(ctx, `
+		SELECT id, user_id, title, content, topic_id, tags
+		FROM drafts
+		WHERE scheduled_at <= CURRENT_TIMESTAMP
+		ORDER BY scheduled_at ASC
+		LIMIT 1
+		FOR UPDATE SKIP LOCKED`).Scan(&d.ID, &d.UserID, &d.Title, &d.Content, &d.TopicID, &d.Tags)
+	if err == sql.ErrNoRows {
+		return false, false, nil
+	}
+	if err != nil {
+		return false, false, fmt.Errorf("load draft: %w", err)
+	}
+	reason, err := checkTopic(ctx, tx, d.TopicID)
+	if err != nil {
+		return true, false, fmt.Errorf("check topic for draft %d: %w", d.ID, err)
+	}
+	if reason != "" {
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE drafts SET scheduled_at = NULL, last_error = $2, updated_at = CURRENT_TIMESTAMP WHERE id = $1`, d.ID, reason); err != nil {
+			return true, false, fmt.Errorf("unschedule draft %d: %w", d.ID, err)
+		}
+		log.Printf("WARN: Could not publish draft %d for user %d: %s", d.ID, d.UserID, reason)
+		return true, false, tx.Commit()
+	}
+	postID, err := publish(ctx, tx, d)
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		tx.Rollback()
+		if transient(ctx, err) {
+			return true, false, fmt.Errorf("publish draft %d: %w", d.ID, err)
+		}
+		// Anything else will fail again on the next run, and the draft would
+		// stay first in line and hold up every draft behind it.
+		log.Printf("ERROR: Could not publish draft %d for user %d: %v", d.ID, d.UserID, err)
+		if _, err := p.db.ExecContext(ctx,
+			`UPDATE drafts SET scheduled_at = NULL, last_error = $2, updated_at = CURRENT_TIMESTAMP
+			 WHERE id = $1 AND scheduled_at <= CURRENT_TIMESTAMP`, d.ID, "publishing failed"); err != nil {
+			return true, false, fmt.Errorf("unschedule draft %d: %w", d.ID, err)
+		}
+		return true, false, nil
+	}
+	log.Printf("INFO: Published draft %d as post %d for user %d", d.ID, postID, d.UserID)
+	return true, true, nil
+}
+
+// publish inserts d as a post and removes the draft.
+func publish(ctx context.Context, tx *sql.Tx, d draft) (int64, error) {
+	var postID int64
+	if err := tx.QueryRowContext(ctx,
+		`INSERT INTO posts (title, content, user_id, topic_id) VALUES ($1, $2, $3, $4) RETURNING id`,
+		d.Title, d.Content, d.UserID, *d.TopicID).Scan(&postID); err != nil {
+		return 0, err
+	}
+	var tags []string
+	if d.Tags != "" {
+		tags = strings.Split(d.Tags, ",")
+	}
+	if err := db.InsertPostTags(ctx, tx, postID, tags); err != nil {
+		return 0, err
+	}
+	if err := db.RecordNewPost(ctx, tx, postID, *d.TopicID, d.UserID, d.Content); err != nil {
+		return 0, err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM drafts WHERE id = $1`, d.ID); err != nil {
+		return 0, err
+	}
+	if err := realtime.Publish(ctx, tx, realtime.Event{Type: realtime.EventPostCreated, TopicID: *d.TopicID, PostID: postID}, nil); err != nil {
+		return 0, err
+	}
+	return postID, nil
+}
+
+// transient reports whether err may go away on a later run: the run was
+// cancelled, the connection failed, or the server asked for a retry.
+func transient(ctx context.Context, err error) bool {
+	if ctx.Err() != nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, driver.ErrBadConn) || errors.Is(err, sql.ErrConnDone) {
+		return true
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		class := pgErr.Code
+		if len(class) > 2 {
+			class = class[:2]
+		}
+		switch class {
+		case "08", "40", "53", "57": // connection, rollback, resources, operator intervention
+			return true
+		}
+		return false
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || pgconn.SafeToRetry(err)
+}
+
+// checkTopic returns why a draft cannot be published into topicID, or "" if
+// it can. The topic row stays share-locked until tx ends so it cannot be
+// archived while the post is inserted.
+func checkTopic(ctx context.Context, tx *sql.Tx, topicID *int64) (string, error) {
+	if topicID == nil {
+		return "topic no longer exists", nil
+	}
+	var archived bool
+	err := tx.QueryRowContext(ctx, `SELECT archived_at IS NOT NULL FROM topics WHERE id = $1 FOR SHARE`, *topicID).Scan(&archived)
+	if err == sql.ErrNoRows {
+		return "topic no longer exists", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if archived {
+		return "topic is archived", nil
+	}
+	return "", nil
+}
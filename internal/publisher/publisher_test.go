@@ -0,0 +1,41 @@
+package publisher
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestTransient(t *testing.T) {
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	tests := []struct {
+		name string
+		ctx  context.Context
+		err  error
+		want bool
+	}{
+		{"cancelled run", cancelled, errors.New("insert failed"), true},
+		{"deadline", context.Background(), fmt.Errorf("publish: %w", context.DeadlineExceeded), true},
+		{"bad connection", context.Background(), driver.ErrBadConn, true},
+		{"closed connection", context.Background(), sql.ErrConnDone, true},
+		{"network", context.Background(), &net.OpError{Op: "read", Err: errors.New("connection reset")}, true},
+		{"serialization failure", context.Background(), &pgconn.PgError{Code: "40001"}, true},
+		{"deadlock", context.Background(), &pgconn.PgError{Code: "40P01"}, true},
+		{"admin shutdown", context.Background(), &pgconn.PgError{Code: "57P01"}, true},
+		{"check violation", context.Background(), fmt.Errorf("publish: %w", &pgconn.PgError{Code: "23514"}), false},
+		{"value too long", context.Background(), &pgconn.PgError{Code: "22001"}, false},
+		{"unknown error", context.Background(), errors.New("too many tags"), false},
+	}
+	for _, tt := range tests {
+		if got := transient(tt.ctx, tt.err); got != tt.want {
+			t.Errorf("%s: transient = %t, want %t", tt.name, got, tt.want)
+		}
+	}
+}
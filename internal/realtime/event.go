@@ -0,0 +1,60 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/v1-nce/threadtalk-backend/internal/db"
+)
+
+// notifyChannel is the Postgres channel every replica listens on.
+const notifyChannel = "threadtalk_events"
+
+// Postgres rejects NOTIFY payloads of 8000 bytes or more.
+const maxPayload = 7900
+
+const (
+	EventPostCreated    = "post_created"
+	EventPostDeleted    = "post_deleted"
+	EventCommentCreated = "comment_created"
+	EventCommentDeleted = "comment_deleted"
+)
+
+// Event is a change to a thread. Post events carry TopicID and reach topic
+// streams as well as the post's own stream; comment events only reach the
+// post's stream. Data holds the created post or comment and is left out when
+// it is too large to send, in which case clients fetch it themselves.
+type Event struct {
+	Type      string          `json:"type"`
+	TopicID   int64           `json:"topic_id,string,omitempty"`
+	PostID    int64           `json:"post_id,string"`
+	CommentID int64           `json:"comment_id,string,omitempty"`
+	Data      json.RawMessage `json:"data,omitempty"`
+}
+
+// Publish broadcasts ev to every replica, including this one, via Postgres
+// NOTIFY. Run inside a transaction, the event is only sent if it commits.
+func Publish(ctx context.Context, ex db.Execer, ev Event, data interface{}) error {
+	if data != nil {
+		raw, err := json.Marshal(data)
+		if err != nil {
+			return fmt.Errorf("encode event data: %w", err)
+		}
+		ev.Data = raw
+	}
+	payload, err := json.Marshal(ev)
+	if err != nil {
+		return fmt.Errorf("encode event: %w", err)
+	}
+	if len(payload) > maxPayload {
+		ev.Data = nil
+		if payload, err = json.Marshal(ev); err != nil {
+			return fmt.Errorf("encode event: %w", err)
+		}
+	}
+	if _, err := ex.ExecContext(ctx, `SELECT pg_notify($1, $2)`, notifyChannel, string(payload)); err != nil {
+		return fmt.Errorf("publish %s event: %w", ev.Type, err)
+	}
+	return nil
+}
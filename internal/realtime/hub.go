@@ -0,0 +1,209 @@
+package realtime
+
+import (
+	"errors"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+)
+
+const (
+	StreamPost  = "post"
+	StreamTopic = "topic"
+)
+
+// subscriptionBuffer is how many events may queue for a subscriber before it
+// is considered too slow and dropped.
+const subscriptionBuffer = 64
+
+var ErrTooManySubscribers = errors.New("too many subscribers")
+
+// Stream identifies what a subscriber is watching: one post or one topic.
+type Stream struct {
+	Kind string
+	ID   int64
+}
+
+// Hub fans events out to the subscribers on this replica. Delivery never
+// blocks: a subscriber whose buffer is full is dropped and its channel closed
+// with Lagged set, so it can tell clients to refetch.
+type Hub struct {
+	mu       sync.Mutex
+	streams  map[Stream]map[*Subscription]struct{}
+	count    int
+	max      int
+	presence *Presence
+}
+
+type Subscription struct {
+	C       <-chan Event
+	c       chan Event
+	hub     *Hub
+	streams []Stream
+	closed  bool
+	lagged  bool
+}
+
+type Config struct {
+	MaxSubscribers int
+}
+
+// ConfigFromEnv reads REALTIME_MAX_SUBSCRIBERS, the number of live streams
+// one replica will serve (0 means no limit).
+func ConfigFromEnv() Config {
+	cfg := Config{MaxSubscribers: 1000}
+	if s := os.Getenv("REALTIME_MAX_SUBSCRIBERS"); s != "" {
+		if v, err := strconv.Atoi(s); err == nil && v >= 0 {
+			cfg.MaxSubscribers = v
+		} else {
+			log.Printf("WARN: Ignoring invalid REALTIME_MAX_SUBSCRIBERS %q", s)
+		}
+	}
+	return cfg
+}
+
+func NewHub(cfg Config) *Hub {
+	return &Hub{
+		streams: make(map[Stream]map[*Subscription]struct{}),
+		max:     cfg.MaxSubscribers,
+	}
+}
+
+func (h *Hub) Subscribe(streams ...Stream) (*Subscription, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.max > 0 && h.count >= h.max {
+		return nil, ErrTooManySubscribers
+	}
+	ch := make(chan Event, subscriptionBuffer)
+	s := &Subscription{C: ch, c: ch, hub: h}
+	h.count++
+	metrics.Add("subscribers", 1)
+	for _, st := range streams {
+		h.add(s, st)
+	}
+	return s, nil
+}
+
+// Watch adds a stream to an open subscription.
+func (s *Subscription) Watch(st Stream) {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	if !s.closed {
+		s.hub.add(s, st)
+	}
+}
+
+// Unwatch removes a stream from an open subscription.
+func (s *Subscription) Unwatch(st Stream) {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	subs := s.hub.streams[st]
+	if _, ok := subs[s]; !ok {
+		return
+	}
+	delete(subs, s)
+	if len(subs) == 0 {
+		delete(s.hub.streams, st)
+	}
+	for i, w := range s.streams {
+		if w == st {
+			s.streams = append(s.streams[:i], s.streams[i+1:]...)
+			break
+		}
+	}
+}
+
+// Lagged reports whether the subscription was dropped for falling behind or
+// because events may have been missed. Only meaningful once C is closed.
+func (s *Subscription) Lagged() bool {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	return s.lagged
+}
+
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	s.hub.remove(s)
+}
+
+func (h *Hub) add(s *Subscription, st Stream) {
+	subs := h.streams[st]
+	if subs == nil {
+		subs = make(map[*Subscription]struct{})
+		h.streams[st] = subs
+	}
+	if _, ok := subs[s]; !ok {
+		subs[s] = struct{}{}
+		s.streams = append(s.streams, st)
+	}
+}
+
+// remove must be called with h.mu held.
+func (h *Hub) remove(s *Subscription) {
+	if s.closed {
+		return
+	}
+	s.closed = true
+	for _, st := range s.streams {
+		subs := h.streams[st]
+		delete(subs, s)
+		if len(subs) == 0 {
+			delete(h.streams, st)
+		}
+	}
+	close(s.c)
+	h.count--
+	metrics.Add("subscribers", -1)
+}
+
+// Deliver sends ev to every subscriber of the streams it belongs to.
+func (h *Hub) Deliver(ev Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	metrics.Add("events", 1)
+	if ev.Type == EventPresence {
+		if h.presence == nil {
+			return
+		}
+		var ok bool
+		if ev, ok = h.presence.merge(ev); !ok {
+			return
+		}
+	}
+	targets := []Stream{{Kind: StreamPost, ID: ev.PostID}}
+	if ev.TopicID != 0 {
+		targets = append(targets, Stream{Kind: StreamTopic, ID: ev.TopicID})
+	}
+	seen := make(map[*Subscription]bool)
+	for _, st := range targets {
+		for s := range h.streams[st] {
+			if seen[s] {
+				continue
+			}
+			seen[s] = true
+			select {
+			case s.c <- ev:
+			default:
+				s.lagged = true
+				h.remove(s)
+				metrics.Add("dropped_subscribers", 1)
+			}
+		}
+	}
+}
+
+// Reset drops every subscriber as lagged. It is used after the hub may have
+// missed events, so clients resynchronize instead of showing stale threads.
+func (h *Hub) Reset() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, subs := range h.streams {
+		for s := range subs {
+			s.lagged = true
+			h.remove(s)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package realtime
+
+import (
+	"testing"
+)
+
+// drain returns the events queued on s without blocking.
+func drain(s *Subscription) []Event {
+	var evs []Event
+	for {
+		select {
+		case ev, ok := <-s.C:
+			if !ok {
+				return evs
+			}
+			evs = append(evs, ev)
+		default:
+			return evs
+		}
+	}
+}
+
+// closed reports whether s.C is closed once its queued events are read.
+func closed(s *Subscription) bool {
+	for {
+		select {
+		case _, ok := <-s.C:
+			if !ok {
+				return true
+			}
+		default:
+			return false
+		}
+	}
+}
+
+func TestDeliverRouting(t *testing.T) {
+	h := NewHub(Config{})
+	post, _ := h.Subscribe(Stream{StreamPost, 5})
+	topic, _ := h.Subscribe(Stream{StreamTopic, 2})
+	both, _ := h.Subscribe(Stream{StreamPost, 5}, Stream{StreamTopic, 2})
+	other, _ := h.Subscribe(Stream{StreamPost, 6})
+
+	h.Deliver(Event{Type: EventPostCreated, TopicID: 2, PostID: 5})
+	h.Deliver(Event{Type: EventCommentCreated, PostID: 5, CommentID: 9})
+
+	tests := []struct {
+		name string
+		sub  *Subscription
+		want []string
+	}{
+		{"post stream gets post and comment events", post, []string{EventPostCreated, EventCommentCreated}},
+		{"topic stream gets only post events", topic, []string{EventPostCreated}},
+		{"both streams get each event once", both, []string{EventPostCreated, EventCommentCreated}},
+		{"other post gets nothing", other, nil},
+	}
+	for _, tt := range tests {
+		got := drain(tt.sub)
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: got %d events, want %d", tt.name, len(got), len(tt.want))
+			continue
+		}
+		for i, ev := range got {
+			if ev.Type != tt.want[i] {
+				t.Errorf("%s: event %d = %s, want %s", tt.name, i, ev.Type, tt.want[i])
+			}
+		}
+	}
+}
+
+func TestDeliverDropsLaggingSubscriber(t *testing.T) {
+	h := NewHub(Config{})
+	slow, _ := h.Subscribe(Stream{StreamPost, 5})
+	fast, _ := h.Subscribe(Stream{StreamPost, 5})
+	for i := 0; i < subscriptionBuffer; i++ {
+		h.Deliver(Event{Type: EventCommentCreated, PostID: 5})
+		drain(fast)
+	}
+	h.Deliver(Event{Type: EventCommentCreated, PostID: 5})
+
+	if !closed(slow) || !slow.Lagged() {
+		t.Error("full subscriber was not dropped as lagged")
+	}
+	if got := len(drain(fast)); got != 1 {
+		t.Errorf("fast subscriber got %d events, want 1", got)
+	}
+	if closed(fast) || fast.Lagged() {
+		t.Error("subscriber that kept up was dropped")
+	}
+	if h.count != 1 {
+		t.Errorf("hub counts %d subscribers, want 1", h.count)
+	}
+	// Delivering again must not touch the dropped subscription.
+	h.Deliver(Event{Type: EventCommentCreated, PostID: 5})
+	slow.Close()
+}
+
+func TestReset(t *testing.T) {
+	h := NewHub(Config{MaxSubscribers: 2})
+	a, _ := h.Subscribe(Stream{StreamPost, 5})
+	b, _ := h.Subscribe(Stream{StreamPost, 5}, Stream{StreamTopic, 2})
+	h.Reset()
+	for name, s := range map[string]*Subscription{"a": a, "b": b} {
+		if !closed(s) || !s.Lagged() {
+			t.Errorf("subscription %s was not dropped as lagged", name)
+		}
+	}
+	if len(h.streams) != 0 || h.count != 0 {
+		t.Errorf("hub kept %d streams and %d subscribers", len(h.streams), h.count)
+	}
+	// Closing after a reset is a no-op, and the freed slots can be reused.
+	a.Close()
+	for i := 0; i < 2; i++ {
+		if _, err := h.Subscribe(Stream{StreamPost, 5}); err != nil {
+			t.Fatalf("subscribe after reset: %v", err)
+		}
+	}
+}
+
+func TestSubscriberLimit(t *testing.T) {
+	h := NewHub(Config{MaxSubscribers: 1})
+	s, err := h.Subscribe(Stream{StreamPost, 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.Subscribe(Stream{StreamPost, 6}); err != ErrTooManySubscribers {
+		t.Errorf("second subscribe error = %v, want ErrTooManySubscribers", err)
+	}
+	s.Close()
+	s.Close()
+	if !closed(s) || s.Lagged() {
+		t.Error("closed subscription should be closed and not lagged")
+	}
+	if _, err := h.Subscribe(Stream{StreamPost, 6}); err != nil {
+		t.Errorf("subscribe after close: %v", err)
+	}
+}
+
+func TestWatchAndUnwatch(t *testing.T) {
+	h := NewHub(Config{})
+	s, _ := h.Subscribe()
+	s.Watch(Stream{StreamTopic, 2})
+	s.Watch(Stream{StreamTopic, 2})
+	h.Deliver(Event{Type: EventPostCreated, TopicID: 2, PostID: 5})
+	if got := len(drain(s)); got != 1 {
+		t.Errorf("got %d events after watching twice, want 1", got)
+	}
+	s.Unwatch(Stream{StreamTopic, 2})
+	h.Deliver(Event{Type: EventPostCreated, TopicID: 2, PostID: 6})
+	if got := len(drain(s)); got != 0 {
+		t.Errorf("got %d events after unwatching, want 0", got)
+	}
+	if len(h.streams) != 0 {
+		t.Errorf("hub kept %d empty streams", len(h.streams))
+	}
+	s.Close()
+	s.Watch(Stream{StreamTopic, 2})
+	if len(h.streams) != 0 {
+		t.Error("closed subscription started watching")
+	}
+}
+
+func TestHubConfigFromEnv(t *testing.T) {
+	tests := []struct {
+		env  string
+		want int
+	}{
+		{"", 1000},
+		{"0", 0},
+		{"50", 50},
+		{"-1", 1000},
+		{"many", 1000},
+	}
+	for _, tt := range tests {
+		t.Setenv("REALTIME_MAX_SUBSCRIBERS", tt.env)
+		if got := ConfigFromEnv().MaxSubscribers; got != tt.want {
+			t.Errorf("REALTIME_MAX_SUBSCRIBERS=%q: MaxSubscribers = %d, want %d", tt.env, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"expvar"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var metrics = expvar.NewMap("realtime")
+
+// Listener receives events from Postgres NOTIFY on a dedicated connection
+// and delivers them to the hub. Every event, including those published by
+// this replica, arrives this way, so all replicas see the same order.
+type Listener struct {
+	dsn      string
+	hub      *Hub
+	stopChan chan struct{}
+	stopped  sync.Once
+	done     chan struct{}
+}
+
+func NewListener(dsn string, hub *Hub) *Listener {
+	return &Listener{
+		dsn:      dsn,
+		hub:      hub,
+		stopChan: make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+func (l *Listener) Start() {
+	log.Println("Realtime listener started")
+	go l.loop()
+}
+
+func (l *Listener) Stop() {
+	l.stopped.Do(func() {
+		close(l.stopChan)
+	})
+	<-l.done
+}
+
+// loop keeps a LISTEN connection open, reconnecting with backoff. Events sent
+// while disconnected are lost, so subscribers are reset after a reconnect.
+func (l *Listener) loop() {
+	defer close(l.done)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-l.stopChan
+		cancel()
+	}()
+	backoff := time.Second
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 {
+			l.hub.Reset()
+		}
+		err := l.listen(ctx, func() { backoff = time.Second })
+		if ctx.Err() != nil {
+			return
+		}
+		metrics.Add("reconnects", 1)
+		log.Printf("WARN: Realtime listener disconnected, retrying in %s: %v", backoff, err)
+		select {
+		case <-l.stopChan:
+			return
+		case <-time.After(backoff):
+		}
+		if backoff < 30*time.Second {
+			backoff *= 2
+		}
+	}
+}
+
+func (l *Listener) listen(ctx context.Context, connected func()) error {
+	conn, err := pgx.Connect(ctx, l.dsn)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+	if _, err := conn.Exec(ctx, "LISTEN "+notifyChannel); err != nil {
+		return err
+	}
+	connected()
+	for {
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		var ev Event
+		if err := json.Unmarshal([]byte(n.Payload), &ev); err != nil {
+			log.Printf("WARN: Ignoring malformed realtime event: %v", err)
+			continue
+		}
+		l.hub.Deliver(ev)
+	}
+}
@@ -0,0 +1,222 @@
+package realtime
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	EventTyping   = "typing"
+	EventPresence = "presence"
+)
+
+const (
+	// presenceInterval is how often each replica re-announces its viewer
+	// counts; counts not refreshed within presenceTTL are ignored, so a
+	// replica that dies stops counting shortly after.
+	presenceInterval = 30 * time.Second
+	presenceTTL      = 75 * time.Second
+)
+
+// Ephemeral reports whether an event is a typing indicator or presence
+// update rather than a change to stored content.
+func (ev Event) Ephemeral() bool {
+	return ev.Type == EventTyping || ev.Type == EventPresence
+}
+
+type TypingData struct {
+	UserID   int64  `json:"user_id,string"`
+	Username string `json:"username"`
+}
+
+// PresenceData is what one replica announces about a post, and, once merged
+// by the hub, the total across replicas (Replica is then empty).
+type PresenceData struct {
+	Replica string `json:"replica,omitempty"`
+	Viewers int    `json:"viewers"`
+}
+
+type replicaCount struct {
+	viewers int
+	seen    time.Time
+}
+
+// Presence counts the distinct users viewing each post. Each replica tracks
+// its own viewers and announces the count over NOTIFY; the hub merges the
+// announcements so every replica reports the same total. A user connected to
+// two replicas is counted twice.
+type Presence struct {
+	db      *sql.DB
+	replica string
+
+	mu     sync.Mutex
+	local  map[int64]map[int64]int
+	remote map[int64]map[string]replicaCount
+
+	stopChan chan struct{}
+	stopped  sync.Once
+	done     chan struct{}
+}
+
+// NewPresence creates the tracker and attaches it to hub, which hands it
+// presence announcements from other replicas.
+func NewPresence(db *sql.DB, hub *Hub) *Presence {
+	id := make([]byte, 8)
+	rand.Read(id)
+	p := &Presence{
+		db:       db,
+		replica:  hex.EncodeToString(id),
+		local:    make(map[int64]map[int64]int),
+		remote:   make(map[int64]map[string]replicaCount),
+		stopChan: make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	hub.mu.Lock()
+	hub.presence = p
+	hub.mu.Unlock()
+	return p
+}
+
+func (p *Presence) Start() {
+	go p.loop()
+}
+
+func (p *Presence) Stop() {
+	p.stopped.Do(func() {
+		close(p.stopChan)
+	})
+	<-p.done
+}
+
+func (p *Presence) loop() {
+	defer close(p.done)
+	ticker := time.NewTicker(presenceInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.stopChan:
+			return
+		case <-ticker.C:
+		}
+		p.mu.Lock()
+		counts := make(map[int64]int, len(p.local))
+		for postID, users := range p.local {
+			counts[postID] = len(users)
+		}
+		now := time.Now()
+		for postID, replicas := range p.remote {
+			for replica, rc := range replicas {
+				if now.Sub(rc.seen) > presenceTTL {
+					delete(replicas, replica)
+				}
+			}
+			if len(replicas) == 0 {
+				delete(p.remote, postID)
+			}
+		}
+		p.mu.Unlock()
+		for postID, n := range counts {
+			p.announce(postID, n)
+		}
+	}
+}
+
+// Join records that userID started viewing postID on this replica.
+func (p *Presence) Join(postID, userID int64) {
+	p.mu.Lock()
+	users := p.local[postID]
+	if users == nil {
+		users = make(map[int64]int)
+		p.local[postID] = users
+	}
+	users[userID]++
+	first, n := users[userID] == 1, len(users)
+	p.mu.Unlock()
+	if first {
+		p.announce(postID, n)
+	}
+}
+
+// Leave undoes one Join.
+func (p *Presence) Leave(postID, userID int64) {
+	p.mu.Lock()
+	users := p.local[postID]
+	if users[userID] == 0 {
+		p.mu.Unlock()
+		return
+	}
+	users[userID]--
+	last := users[userID] == 0
+	if last {
+		delete(users, userID)
+	}
+	n := len(users)
+	if n == 0 {
+		delete(p.local, postID)
+	}
+	p.mu.Unlock()
+	if last {
+		p.announce(postID, n)
+	}
+}
+
+// Viewers returns the current total for postID across replicas.
+func (p *Presence) Viewers(postID int64) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.total(postID)
+}
+
+func (p *Presence) announce(postID int64, viewers int) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	ev := Event{Type: EventPresence, PostID: postID}
+	if err := Publish(ctx, p.db, ev, PresenceData{Replica: p.replica, Viewers: viewers}); err != nil {
+		log.Printf("WARN: Failed to announce presence for post %d: %v", postID, err)
+	}
+}
+
+// merge applies a replica's announcement and returns the event to deliver to
+// local subscribers, carrying the new total. ok is false for malformed events.
+func (p *Presence) merge(ev Event) (Event, bool) {
+	var d PresenceData
+	if err := json.Unmarshal(ev.Data, &d); err != nil || d.Replica == "" {
+		return ev, false
+	}
+	p.mu.Lock()
+	replicas := p.remote[ev.PostID]
+	if replicas == nil {
+		replicas = make(map[string]replicaCount)
+		p.remote[ev.PostID] = replicas
+	}
+	if d.Viewers == 0 {
+		delete(replicas, d.Replica)
+	} else {
+		replicas[d.Replica] = replicaCount{viewers: d.Viewers, seen: time.Now()}
+	}
+	total := p.total(ev.PostID)
+	if len(replicas) == 0 {
+		delete(p.remote, ev.PostID)
+	}
+	p.mu.Unlock()
+	ev.Data, _ = json.Marshal(PresenceData{Viewers: total})
+	return ev, true
+}
+
+// total must be called with p.mu held.
+func (p *Presence) total(postID int64) int {
+	total := 0
+	now := time.Now()
+	for _, rc := range p.remote[postID] {
+		if now.Sub(rc.seen) <= presenceTTL {
+			total += rc.viewers
+		}
+	}
+	return total
+}
@@ -0,0 +1,105 @@
+package realtime
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/v1-nce/threadtalk-backend/internal/dbtest"
+)
+
+// announced returns the viewer counts p has announced, in order.
+func announced(t *testing.T, f *dbtest.DB) []int {
+	t.Helper()
+	var counts []int
+	for _, stmt := range f.Find("pg_notify") {
+		var ev Event
+		var d PresenceData
+		if err := json.Unmarshal([]byte(stmt.Args[1].(string)), &ev); err != nil {
+			t.Fatal(err)
+		}
+		if err := json.Unmarshal(ev.Data, &d); err != nil {
+			t.Fatal(err)
+		}
+		counts = append(counts, d.Viewers)
+	}
+	return counts
+}
+
+func TestPresenceJoinLeave(t *testing.T) {
+	db, f := dbtest.New(t)
+	p := NewPresence(db, NewHub(Config{}))
+	p.Join(5, 1)
+	p.Join(5, 1) // a second tab is not a new viewer
+	p.Join(5, 2)
+	p.Leave(5, 1)
+	p.Leave(5, 1)
+	p.Leave(5, 1) // more leaves than joins are ignored
+	p.Leave(5, 2)
+
+	want := []int{1, 2, 1, 0}
+	got := announced(t, f)
+	if len(got) != len(want) {
+		t.Fatalf("announced %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("announced %v, want %v", got, want)
+			break
+		}
+	}
+	if len(p.local) != 0 {
+		t.Errorf("presence kept %d posts with no viewers", len(p.local))
+	}
+}
+
+func TestPresenceMerge(t *testing.T) {
+	db, _ := dbtest.New(t)
+	h := NewHub(Config{})
+	p := NewPresence(db, h)
+	sub, _ := h.Subscribe(Stream{StreamPost, 5})
+	announce := func(replica string, viewers int) Event {
+		data, _ := json.Marshal(PresenceData{Replica: replica, Viewers: viewers})
+		return Event{Type: EventPresence, PostID: 5, Data: data}
+	}
+
+	h.Deliver(announce("a", 2))
+	h.Deliver(announce("b", 3))
+	h.Deliver(announce("a", 0)) // replica a's viewers all left
+	h.Deliver(Event{Type: EventPresence, PostID: 5, Data: json.RawMessage(`{"viewers": 9}`)})
+
+	var totals []int
+	for _, ev := range drain(sub) {
+		var d PresenceData
+		json.Unmarshal(ev.Data, &d)
+		if d.Replica != "" {
+			t.Errorf("delivered event names replica %q", d.Replica)
+		}
+		totals = append(totals, d.Viewers)
+	}
+	want := []int{2, 5, 3}
+	if len(totals) != len(want) || totals[0] != 2 || totals[1] != 5 || totals[2] != 3 {
+		t.Errorf("delivered totals %v, want %v (malformed announcement dropped)", totals, want)
+	}
+	if got := p.Viewers(5); got != 3 {
+		t.Errorf("Viewers(5) = %d, want 3", got)
+	}
+
+	// A replica that stops announcing stops counting.
+	p.mu.Lock()
+	p.remote[5]["b"] = replicaCount{viewers: 3, seen: time.Now().Add(-presenceTTL - time.Second)}
+	p.mu.Unlock()
+	if got := p.Viewers(5); got != 0 {
+		t.Errorf("Viewers(5) with a stale replica = %d, want 0", got)
+	}
+}
+
+func TestPresenceWithoutTracker(t *testing.T) {
+	h := NewHub(Config{})
+	sub, _ := h.Subscribe(Stream{StreamPost, 5})
+	data, _ := json.Marshal(PresenceData{Replica: "a", Viewers: 2})
+	h.Deliver(Event{Type: EventPresence, PostID: 5, Data: data})
+	if got := len(drain(sub)); got != 0 {
+		t.Errorf("hub without presence delivered %d presence events", got)
+	}
+}
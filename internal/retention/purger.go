@@ -0,0 +1,324 @@
+package retention
+
+import (
+	"context"
+	"database/sql"
+	"expvar"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/v1-nce/threadtalk-backend/internal/storage"
+)
+
+type Config struct {
+	Window    time.Duration
+	Interval  time.Duration
+	BatchSize int
+	DryRun    bool
+}
+
+// ConfigFromEnv reads RETENTION_WINDOW, RETENTION_INTERVAL (0 disables the
+// worker), RETENTION_BATCH_SIZE and RETENTION_DRY_RUN.
+func ConfigFromEnv() Config {
+	cfg := Config{
+		Window:    30 * 24 * time.Hour,
+		Interval:  time.Hour,
+		BatchSize: 500,
+	}
+	if v, err := time.ParseDuration(os.Getenv("RETENTION_WINDOW")); err == nil && v > 0 {
+		cfg.Window = v
+	}
+	if s := os.Getenv("RETENTION_INTERVAL"); s != "" {
+		if v, err := time.ParseDuration(s); err == nil && v >= 0 {
+			cfg.Interval = v
+		} else {
+			log.Printf("WARN: Ignoring invalid RETENTION_INTERVAL %q", s)
+		}
+	}
+	if v, err := strconv.Atoi(os.Getenv("RETENTION_BATCH_SIZE")); err == nil && v > 0 {
+		cfg.BatchSize = v
+	}
+	cfg.DryRun, _ = strconv.ParseBool(os.Getenv("RETENTION_DRY_RUN"))
+	return cfg
+}
+
+type Result struct {
+	PostsDeleted     int64
+	PostsScrubbed    int64
+	CommentsDeleted  int64
+	CommentsScrubbed int64
+	BlobsDeleted     int64
+}
+
+var metrics = expvar.NewMap("retention")
+
+// Purger permanently removes content whose deleted_at is older than the
+// retention window. Rows that still anchor a thread (posts with comments,
+// comments with replies) are scrubbed instead of deleted so the tree keeps
+// its shape. Attachments go with the content they belong to, and stored files
+// no attachment refers to any more are deleted.
+type Purger struct {
+	db       *sql.DB
+	store    storage.BlobStore
+	cfg      Config
+	stopChan chan struct{}
+	stopped  sync.Once
+	done     chan struct{}
+}
+
+func NewPurger(db *sql.DB, store storage.BlobStore, cfg Config) *Purger {
+	return &Purger{
+		db:       db,
+		store:    store,
+		cfg:      cfg,
+		stopChan: make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+func (p *Purger) Start() {
+	if p.cfg.Interval == 0 {
+		log.Println("Retention worker disabled")
+		close(p.done)
+		return
+	}
+	log.Printf("Retention worker started (window %s, interval %s, dry run %t)", p.cfg.Window, p.cfg.Interval, p.cfg.DryRun)
+	go p.loop()
+}
+
+func (p *Purger) Stop() {
+	p.stopped.Do(func() {
+		close(p.stopChan)
+	})
+	<-p.done
+}
+
+func (p *Purger) loop() {
+	defer close(p.done)
+	ticker := time.NewTicker(p.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		p.runLogged()
+		select {
+		case <-p.stopChan:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *Purger) runLogged() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	go func() {
+		select {
+		case <-p.stopChan:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	res, err := p.RunOnce(ctx)
+	metrics.Add("runs", 1)
+	last := new(expvar.Int)
+	last.Set(time.Now().Unix())
+	metrics.Set("last_run_unix", last)
+	lastErr := new(expvar.String)
+	if err != nil {
+		lastErr.Set(err.Error())
+		log.Printf("ERROR: Retention run failed: %v", err)
+	}
+	metrics.Set("last_error", lastErr)
+	if res != (Result{}) {
+		verb := "Purged"
+		if p.cfg.DryRun {
+			verb = "Dry run: would purge"
+		}
+		log.Printf("%s %d posts (%d scrubbed), %d comments (%d scrubbed) and %d unused files",
+			verb, res.PostsDeleted+res.PostsScrubbed, res.PostsScrubbed, res.CommentsDeleted+res.CommentsScrubbed, res.CommentsScrubbed, res.BlobsDeleted)
+	}
+}
+
+// RunOnce performs a single retention sweep. In dry-run mode it only counts
+// the rows that would be affected.
+func (p *Purger) RunOnce(ctx context.Context) (Result, error) {
+	var res Result
+	cutoff := time.Now().Add(-p.cfg.Window)
+	if p.cfg.DryRun {
+		err := p.db.QueryRowContext(ctx, `
+			SELECT
+				COUNT(*) FILTER (WHERE NOT EXISTS (SELECT 1 FROM comments c WHERE c.post_id = p.id)),
+				COUNT(*) FILTER (WHERE EXISTS (SELECT 1 FROM comments c WHERE c.post_id = p.id))
+			FROM posts p
+			WHERE p.deleted_at < $1 AND p.purged_at IS NULL`, cutoff).Scan(&res.PostsDeleted, &res.PostsScrubbed)
+		if err != nil {
+			return res, fmt.Errorf("count posts: %w", err)
+		}
+		err = p.db.QueryRowContext(ctx, `
+			SELECT
+				COUNT(*) FILTER (WHERE NOT EXISTS (SELECT 1 FROM comments r WHERE r.parent_id = c.id)),
+				COUNT(*) FILTER (WHERE EXISTS (SELECT 1 FROM comments r WHERE r.parent_id = c.id))
+			FROM comments c
+			WHERE c.deleted_at < $1 AND c.purged_at IS NULL`, cutoff).Scan(&res.CommentsDeleted, &res.CommentsScrubbed)
+		if err != nil {
+			return res, fmt.Errorf("count comments: %w", err)
+		}
+		err = p.db.QueryRowContext(ctx, `
+			SELECT COUNT(*) FROM blobs b
+			WHERE NOT EXISTS (SELECT 1 FROM attachments a WHERE a.blob_sha256 = b.sha256)`).Scan(&res.BlobsDeleted)
+		if err != nil {
+			return res, fmt.Errorf("count blobs: %w", err)
+		}
+		p.record("dry_run_", res)
+		return res, nil
+	}
+	// Deleting leaf comments can turn their deleted parents into leaves, so
+	// keep sweeping until a pass removes nothing.
+	for {
+		n, err := p.batch(ctx, `
+			DELETE FROM comments WHERE id IN (
+				SELECT c.id FROM comments c
+				WHERE c.deleted_at < $1
+					AND NOT EXISTS (SELECT 1 FROM comments r WHERE r.parent_id = c.id)
+				LIMIT $2
+				FOR UPDATE SKIP LOCKED
+			)`, cutoff)
+		res.CommentsDeleted += n
+		if err != nil {
+			return res, fmt.Errorf("delete comments: %w", err)
+		}
+		if n == 0 {
+			break
+		}
+	}
+	n, err := p.sweep(ctx, `
+		WITH targets AS (
+			SELECT id FROM comments
+			WHERE deleted_at < $1 AND purged_at IS NULL
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		), detached AS (
+			DELETE FROM attachments WHERE comment_id IN (SELECT id FROM targets)
+		)
+		UPDATE comments SET content = '[deleted]', purged_at = CURRENT_TIMESTAMP
+		WHERE id IN (SELECT id FROM targets)`, cutoff)
+	res.CommentsScrubbed += n
+	if err != nil {
+		return res, fmt.Errorf("scrub comments: %w", err)
+	}
+	n, err = p.sweep(ctx, `
+		DELETE FROM posts WHERE id IN (
+			SELECT p.id FROM posts p
+			WHERE p.deleted_at < $1
+				AND NOT EXISTS (SELECT 1 FROM comments c WHERE c.post_id = p.id)
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		)`, cutoff)
+	res.PostsDeleted += n
+	if err != nil {
+		return res, fmt.Errorf("delete posts: %w", err)
+	}
+	n, err = p.sweep(ctx, `
+		WITH targets AS (
+			SELECT id FROM posts
+			WHERE deleted_at < $1 AND purged_at IS NULL
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		), untagged AS (
+			DELETE FROM post_tags WHERE post_id IN (SELECT id FROM targets)
+		), detached AS (
+			DELETE FROM attachments WHERE post_id IN (SELECT id FROM targets)
+		)
+		UPDATE posts SET title = '[deleted]', content = '[deleted]', pinned_at = NULL, purged_at = CURRENT_TIMESTAMP
+		WHERE id IN (SELECT id FROM targets)`, cutoff)
+	res.PostsScrubbed += n
+	if err != nil {
+		return res, fmt.Errorf("scrub posts: %w", err)
+	}
+	for {
+		n, err := p.collectBlobs(ctx)
+		res.BlobsDeleted += n
+		if err != nil {
+			return res, fmt.Errorf("delete blobs: %w", err)
+		}
+		if n < int64(p.cfg.BatchSize) {
+			break
+		}
+	}
+	p.record("", res)
+	return res, nil
+}
+
+// sweep runs a batched statement until it affects fewer rows than a batch.
+func (p *Purger) sweep(ctx context.Context, query string, cutoff time.Time) (int64, error) {
+	var total int64
+	for {
+		n, err := p.batch(ctx, query, cutoff)
+		total += n
+		if err != nil || n < int64(p.cfg.BatchSize) {
+			return total, err
+		}
+	}
+}
+
+func (p *Purger) batch(ctx context.Context, query string, cutoff time.Time) (int64, error) {
+	res, err := p.db.ExecContext(ctx, query, cutoff, p.cfg.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// collectBlobs deletes one batch of stored files that no attachment refers
+// to. The blob rows stay locked until the files are gone, so an upload of
+// the same contents waits and then stores the file again. If the run fails
+// after deleting some files, their rows come back; uploads check for the
+// file and store it again, and the next run deletes what is still unused.
+func (p *Purger) collectBlobs(ctx context.Context) (int64, error) {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	rows, err := tx.QueryContext(ctx, `
+		SELECT sha256 FROM blobs b
+		WHERE NOT EXISTS (SELECT 1 FROM attachments a WHERE a.blob_sha256 = b.sha256)
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED`, p.cfg.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+	var hashes []string
+	for rows.Next() {
+		var hash string
+		if err := rows.Scan(&hash); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		hashes = append(hashes, hash)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	for _, hash := range hashes {
+		if err := p.store.Delete(ctx, storage.BlobKey(hash)); err != nil {
+			return 0, err
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM blobs WHERE sha256 = $1`, hash); err != nil {
+			return 0, err
+		}
+	}
+	return int64(len(hashes)), tx.Commit()
+}
+
+func (p *Purger) record(prefix string, res Result) {
+	metrics.Add(prefix+"posts_deleted", res.PostsDeleted)
+	metrics.Add(prefix+"posts_scrubbed", res.PostsScrubbed)
+	metrics.Add(prefix+"comments_deleted", res.CommentsDeleted)
+	metrics.Add(prefix+"comments_scrubbed", res.CommentsScrubbed)
+	metrics.Add(prefix+"blobs_deleted", res.BlobsDeleted)
+}
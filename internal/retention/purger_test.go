@@ -0,0 +1,152 @@
+package retention
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/v1-nce/threadtalk-backend/internal/dbtest"
+	"github.com/v1-nce/threadtalk-backend/internal/storage"
+)
+
+func TestConfigFromEnv(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		want Config
+	}{
+		{"defaults", nil, Config{Window: 30 * 24 * time.Hour, Interval: time.Hour, BatchSize: 500}},
+		{"all set", map[string]string{
+			"RETENTION_WINDOW":     "72h",
+			"RETENTION_INTERVAL":   "10m",
+			"RETENTION_BATCH_SIZE": "50",
+			"RETENTION_DRY_RUN":    "true",
+		}, Config{Window: 72 * time.Hour, Interval: 10 * time.Minute, BatchSize: 50, DryRun: true}},
+		{"zero interval disables", map[string]string{"RETENTION_INTERVAL": "0"}, Config{Window: 30 * 24 * time.Hour, BatchSize: 500}},
+		{"invalid values ignored", map[string]string{
+			"RETENTION_WINDOW":     "-1h",
+			"RETENTION_INTERVAL":   "soon",
+			"RETENTION_BATCH_SIZE": "0",
+			"RETENTION_DRY_RUN":    "maybe",
+		}, Config{Window: 30 * 24 * time.Hour, Interval: time.Hour, BatchSize: 500}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, k := range []string{"RETENTION_WINDOW", "RETENTION_INTERVAL", "RETENTION_BATCH_SIZE", "RETENTION_DRY_RUN"} {
+				t.Setenv(k, tt.env[k])
+			}
+			if got := ConfigFromEnv(); got != tt.want {
+				t.Errorf("ConfigFromEnv() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunOnceDryRun(t *testing.T) {
+	db, f := dbtest.New(t,
+		dbtest.Row("FROM posts p", int64(2), int64(1)),
+		dbtest.Row("FROM comments c", int64(5), int64(3)),
+		dbtest.Row("SELECT COUNT(*) FROM blobs", int64(4)),
+	)
+	p := NewPurger(db, &fakeStore{}, Config{Window: time.Hour, BatchSize: 10, DryRun: true})
+	res, err := p.RunOnce(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Result{PostsDeleted: 2, PostsScrubbed: 1, CommentsDeleted: 5, CommentsScrubbed: 3, BlobsDeleted: 4}
+	if res != want {
+		t.Errorf("RunOnce() = %+v, want %+v", res, want)
+	}
+	if f.Executed("DELETE") || f.Executed("UPDATE") {
+		t.Error("dry run changed data")
+	}
+}
+
+func TestRunOnceSweepsCommentsUntilNoneLeft(t *testing.T) {
+	// Each pass can turn deleted parents into leaves, so the loop runs until
+	// a pass deletes nothing, even when a pass deleted less than a batch.
+	const deleteLeaves = "DELETE FROM comments WHERE id IN"
+	db, f := dbtest.New(t,
+		dbtest.Reply{Match: deleteLeaves, Affected: 3},
+		dbtest.Reply{Match: deleteLeaves, Affected: 1},
+	)
+	p := NewPurger(db, &fakeStore{}, Config{Window: time.Hour, BatchSize: 10})
+	res, err := p.RunOnce(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.CommentsDeleted != 4 {
+		t.Errorf("CommentsDeleted = %d, want 4", res.CommentsDeleted)
+	}
+	if n := len(f.Find(deleteLeaves)); n != 3 {
+		t.Errorf("ran %d leaf passes, want 3", n)
+	}
+}
+
+func TestCollectBlobs(t *testing.T) {
+	const (
+		hashA = "aa00000000000000000000000000000000000000000000000000000000000000"
+		hashB = "bb00000000000000000000000000000000000000000000000000000000000000"
+	)
+	unused := dbtest.Reply{Match: "SELECT sha256 FROM blobs", Rows: [][]driver.Value{{hashA}, {hashB}}}
+	tests := []struct {
+		name        string
+		deleteErr   error
+		wantN       int64
+		wantErr     bool
+		wantDeleted []string
+		wantRows    int
+	}{
+		{"deletes files then rows", nil, 2, false, []string{storage.BlobKey(hashA), storage.BlobKey(hashB)}, 2},
+		{"store failure keeps the row", errors.New("unavailable"), 0, true, nil, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, f := dbtest.New(t, unused)
+			store := &fakeStore{err: tt.deleteErr}
+			p := NewPurger(db, store, Config{BatchSize: 10})
+			n, err := p.collectBlobs(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("collectBlobs() error = %v, want error %t", err, tt.wantErr)
+			}
+			if n != tt.wantN {
+				t.Errorf("collectBlobs() = %d, want %d", n, tt.wantN)
+			}
+			if len(store.deleted) != len(tt.wantDeleted) {
+				t.Fatalf("deleted %v, want %v", store.deleted, tt.wantDeleted)
+			}
+			for i, key := range tt.wantDeleted {
+				if store.deleted[i] != key {
+					t.Errorf("deleted[%d] = %q, want %q", i, store.deleted[i], key)
+				}
+			}
+			if got := len(f.Find("DELETE FROM blobs")); got != tt.wantRows {
+				t.Errorf("deleted %d blob rows, want %d", got, tt.wantRows)
+			}
+		})
+	}
+}
+
+type fakeStore struct {
+	err     error
+	deleted []string
+}
+
+func (s *fakeStore) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	return nil
+}
+
+func (s *fakeStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	return nil, storage.ErrNotFound
+}
+
+func (s *fakeStore) Delete(ctx context.Context, key string) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.deleted = append(s.deleted, key)
+	return nil
+}
@@ -3,6 +3,7 @@ package router
 import (
 	"context"
 	"database/sql"
+	"expvar"
 	"net/http"
 	"os"
 	"time"
@@ -11,15 +12,17 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/v1-nce/threadtalk-backend/internal/handlers"
 	"github.com/v1-nce/threadtalk-backend/internal/middleware"
+	"github.com/v1-nce/threadtalk-backend/internal/realtime"
+	"github.com/v1-nce/threadtalk-backend/internal/storage"
 )
 
-func SetUpRouter(db *sql.DB) *gin.Engine {
+func SetUpRouter(db *sql.DB, store storage.BlobStore, hub *realtime.Hub, presence *realtime.Presence) *gin.Engine {
 	r := gin.Default()
 
 	// Apply CORS Middleware
 	r.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{os.Getenv("FRONTEND_URL")},
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
 		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization"},
 		ExposeHeaders:    []string{"Content-Length"},
 		AllowCredentials: true,
@@ -48,9 +51,18 @@ func SetUpRouter(db *sql.DB) *gin.Engine {
 	// Rate Limiters
 	publicLimit := middleware.NewRateLimiter(5, 10).Middleware()
 	authLimit := middleware.NewRateLimiter(1, 3).Middleware()
+	reactionLimit := middleware.NewUserRateLimiter(2, 10).Middleware()
+	// Bots skip the limits above, which are sized for people, and share one
+	// budget per bot across all authenticated routes instead.
+	botLimit := middleware.NewBotRateLimiter(5, 20).Middleware()
+
+	moderatorOnly := middleware.ModeratorMiddleware(db)
+	adminOnly := middleware.AdminMiddleware(db)
 
 	authHandler := &handlers.AuthHandler{DB: db}
-	forumHandler := &handlers.ForumHandler{DB: db}
+	forumHandler := &handlers.ForumHandler{DB: db, Hub: hub}
+	attachmentHandler := &handlers.AttachmentHandler{DB: db, Store: store}
+	wsHandler := &handlers.WebSocketHandler{DB: db, Hub: hub, Presence: presence}
 
 	// Public Routes
 	r.POST("/auth/signup", authLimit, authHandler.Signup)
@@ -59,19 +71,93 @@ func SetUpRouter(db *sql.DB) *gin.Engine {
 
 	// Public Routes with general rate limiting
 	r.GET("/topics", publicLimit, forumHandler.GetTopics)
+	r.GET("/topics/:topic_id", publicLimit, forumHandler.GetTopic)
 	r.GET("/topics/:topic_id/posts", publicLimit, forumHandler.GetPosts)
-	r.GET("/posts/:post_id", publicLimit, forumHandler.GetPostWithComments)
+	r.GET("/topics/:topic_id/events", publicLimit, forumHandler.TopicEvents)
+	r.GET("/posts/:post_id", publicLimit, middleware.OptionalAuthMiddleware(db), forumHandler.GetPostWithComments)
+	r.GET("/posts/:post_id/events", publicLimit, forumHandler.PostEvents)
+	r.GET("/categories", publicLimit, forumHandler.GetCategories)
+	r.GET("/search", publicLimit, forumHandler.SearchPosts)
+	r.GET("/tags", publicLimit, forumHandler.GetTags)
+	r.GET("/reactions", publicLimit, forumHandler.GetReactions)
+	r.GET("/attachments/:attachment_id", publicLimit, attachmentHandler.GetAttachment)
+	r.GET("/email/unsubscribe", publicLimit, authHandler.UnsubscribePage)
+	r.POST("/email/unsubscribe", publicLimit, authHandler.Unsubscribe)
+	r.GET("/email/verify", publicLimit, authHandler.VerifyEmail)
+	r.POST("/hooks/:token", publicLimit, forumHandler.ReceiveIncomingWebhook)
 
 	// Protected Routes
 	protected := r.Group("/api")
-	protected.Use(middleware.AuthMiddleware())
+	protected.Use(middleware.AuthMiddleware(db), botLimit)
 	{
 		protected.GET("/profile", authHandler.GetProfile)
+		protected.GET("/ws", publicLimit, wsHandler.Serve)
+		protected.GET("/blocks", authHandler.GetBlocks)
+		protected.PUT("/blocks/:username", authHandler.BlockUser)
+		protected.DELETE("/blocks/:username", authHandler.UnblockUser)
+		protected.GET("/notifications", forumHandler.GetNotifications)
+		protected.PUT("/notifications/:notification_id/read", forumHandler.MarkNotificationRead)
+		protected.POST("/notifications/read", forumHandler.MarkAllNotificationsRead)
+		protected.GET("/bots", authHandler.GetBots)
+		protected.POST("/bots", authLimit, authHandler.CreateBot)
+		protected.DELETE("/bots/:bot_id", authHandler.DeleteBot)
+		protected.GET("/bots/:bot_id/tokens", authHandler.GetBotTokens)
+		protected.POST("/bots/:bot_id/tokens", authLimit, authHandler.CreateBotToken)
+		protected.DELETE("/bots/:bot_id/tokens/:token_id", authHandler.DeleteBotToken)
+		protected.GET("/email-preferences", authHandler.GetEmailPreferences)
+		protected.PUT("/email-preferences", authHandler.PutEmailPreferences)
+		protected.GET("/subscriptions", forumHandler.GetSubscriptions)
+		protected.PUT("/subscriptions", forumHandler.PutSubscription)
+		protected.DELETE("/subscriptions/:subscription_id", forumHandler.DeleteSubscription)
 		protected.POST("/topics", forumHandler.CreateTopic)
 		protected.POST("/posts", forumHandler.CreatePost)
 		protected.POST("/comments", forumHandler.CreateComment)
+		protected.GET("/drafts", forumHandler.GetDrafts)
+		protected.POST("/drafts", forumHandler.CreateDraft)
+		protected.GET("/drafts/:draft_id", forumHandler.GetDraft)
+		protected.PUT("/drafts/:draft_id", forumHandler.UpdateDraft)
+		protected.DELETE("/drafts/:draft_id", forumHandler.DeleteDraft)
 		protected.DELETE("/posts/:post_id", authLimit, forumHandler.DeletePost)
 		protected.DELETE("/comments/:comment_id", authLimit, forumHandler.DeleteComment)
+		protected.POST("/posts/:post_id/restore", authLimit, forumHandler.RestorePost)
+		protected.POST("/comments/:comment_id/restore", authLimit, forumHandler.RestoreComment)
+		protected.POST("/posts/:post_id/attachments", authLimit, attachmentHandler.UploadPostAttachment)
+		protected.POST("/comments/:comment_id/attachments", authLimit, attachmentHandler.UploadCommentAttachment)
+		protected.DELETE("/attachments/:attachment_id", authLimit, attachmentHandler.DeleteAttachment)
+		protected.POST("/posts/:post_id/poll/votes", authLimit, forumHandler.VotePoll)
+		protected.PUT("/posts/:post_id/answer", forumHandler.AcceptAnswer)
+		protected.DELETE("/posts/:post_id/answer", forumHandler.UnacceptAnswer)
+		protected.PUT("/posts/:post_id/reactions/:emoji", reactionLimit, forumHandler.AddPostReaction)
+		protected.DELETE("/posts/:post_id/reactions/:emoji", reactionLimit, forumHandler.RemovePostReaction)
+		protected.PUT("/comments/:comment_id/reactions/:emoji", reactionLimit, forumHandler.AddCommentReaction)
+		protected.DELETE("/comments/:comment_id/reactions/:emoji", reactionLimit, forumHandler.RemoveCommentReaction)
+
+		// Moderator Routes
+		protected.GET("/mod/posts/:post_id", moderatorOnly, forumHandler.GetPostWithDeletedContent)
+		protected.GET("/mod/metrics", moderatorOnly, gin.WrapH(expvar.Handler()))
+		protected.PATCH("/topics/:topic_id", moderatorOnly, forumHandler.UpdateTopic)
+		protected.POST("/categories", moderatorOnly, forumHandler.CreateCategory)
+		protected.PATCH("/categories/:category_id", moderatorOnly, forumHandler.UpdateCategory)
+		protected.DELETE("/categories/:category_id", moderatorOnly, forumHandler.DeleteCategory)
+		protected.PUT("/posts/:post_id/pin", moderatorOnly, forumHandler.PinPost)
+		protected.DELETE("/posts/:post_id/pin", moderatorOnly, forumHandler.UnpinPost)
+		protected.PUT("/posts/:post_id/lock", moderatorOnly, forumHandler.LockPost)
+		protected.DELETE("/posts/:post_id/lock", moderatorOnly, forumHandler.UnlockPost)
+		protected.POST("/posts/:post_id/move", moderatorOnly, forumHandler.MovePost)
+		protected.POST("/posts/:post_id/merge", moderatorOnly, forumHandler.MergePost)
+		protected.POST("/comments/:comment_id/split", moderatorOnly, forumHandler.SplitComment)
+
+		// Admin Routes
+		protected.GET("/admin/webhooks", adminOnly, forumHandler.GetWebhooks)
+		protected.POST("/admin/webhooks", adminOnly, forumHandler.CreateWebhook)
+		protected.PATCH("/admin/webhooks/:webhook_id", adminOnly, forumHandler.UpdateWebhook)
+		protected.DELETE("/admin/webhooks/:webhook_id", adminOnly, forumHandler.DeleteWebhook)
+		protected.GET("/admin/webhooks/:webhook_id/deliveries", adminOnly, forumHandler.GetWebhookDeliveries)
+		protected.POST("/admin/webhooks/:webhook_id/deliveries/:delivery_id/retry", adminOnly, forumHandler.RetryWebhookDelivery)
+		protected.GET("/admin/incoming-webhooks", adminOnly, forumHandler.GetIncomingWebhooks)
+		protected.POST("/admin/incoming-webhooks", adminOnly, forumHandler.CreateIncomingWebhook)
+		protected.PATCH("/admin/incoming-webhooks/:incoming_webhook_id", adminOnly, forumHandler.UpdateIncomingWebhook)
+		protected.DELETE("/admin/incoming-webhooks/:incoming_webhook_id", adminOnly, forumHandler.DeleteIncomingWebhook)
 	}
 
 	return r
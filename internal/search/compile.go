@@ -0,0 +1,63 @@
+package search
+
+import (
+	"fmt"
+	"strings"
+)
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// Compile turns a parsed query into SQL conditions against the posts table
+// aliased as "p". Each condition is prefixed with " AND " so the result can be
+// appended to an existing WHERE clause. Placeholders start at argPos.
+func Compile(q *Query, argPos int) (string, []interface{}) {
+	if q.Empty() {
+		return "", nil
+	}
+	var sb strings.Builder
+	args := make([]interface{}, 0, len(q.Terms))
+	bind := func(v interface{}) string {
+		args = append(args, v)
+		placeholder := fmt.Sprintf("$%d", argPos)
+		argPos++
+		return placeholder
+	}
+	for _, term := range q.Terms {
+		var cond string
+		var negated bool
+		switch n := term.(type) {
+		case Text:
+			arg := bind("%" + likeEscaper.Replace(n.Value) + "%")
+			cond = fmt.Sprintf(`(p.title ILIKE %s OR p.content ILIKE %s)`, arg, arg)
+			negated = n.Negated
+		case Author:
+			cond = fmt.Sprintf(`p.user_id IN (SELECT id FROM users WHERE LOWER(username) = LOWER(%s))`, bind(n.Username))
+			negated = n.Negated
+		case Topic:
+			arg := bind(n.Name)
+			cond = fmt.Sprintf(`p.topic_id IN (SELECT id FROM topics WHERE LOWER(name) = LOWER(%s) OR slug = LOWER(%s))`, arg, arg)
+			negated = n.Negated
+		case Tag:
+			cond = fmt.Sprintf(`EXISTS (SELECT 1 FROM post_tags pt JOIN tags t ON t.id = pt.tag_id WHERE pt.post_id = p.id AND t.name = %s)`, bind(n.Name))
+			negated = n.Negated
+		case DateRange:
+			if n.Before {
+				cond = fmt.Sprintf(`p.created_at < %s`, bind(n.Date))
+			} else {
+				cond = fmt.Sprintf(`p.created_at >= %s`, bind(n.Date.AddDate(0, 0, 1)))
+			}
+			negated = n.Negated
+		case Has:
+			cond = `p.comment_count > 0`
+			negated = n.Negated
+		default:
+			continue
+		}
+		if negated {
+			cond = "NOT (" + cond + ")"
+		}
+		sb.WriteString(" AND ")
+		sb.WriteString(cond)
+	}
+	return sb.String(), args
+}
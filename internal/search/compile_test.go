@@ -0,0 +1,65 @@
+package search
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCompile(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		argPos   int
+		wantSQL  string
+		wantArgs []interface{}
+	}{
+		{"empty", "", 1, "", nil},
+		{
+			"text escapes like wildcards", `100%_\`, 3,
+			` AND (p.title ILIKE $3 OR p.content ILIKE $3)`,
+			[]interface{}{`%100\%\_\\%`},
+		},
+		{
+			"author", "author:alice", 1,
+			` AND p.user_id IN (SELECT id FROM users WHERE LOWER(username) = LOWER($1))`,
+			[]interface{}{"alice"},
+		},
+		{
+			"negated tag", "-tag:go", 2,
+			` AND NOT (EXISTS (SELECT 1 FROM post_tags pt JOIN tags t ON t.id = pt.tag_id WHERE pt.post_id = p.id AND t.name = $2))`,
+			[]interface{}{"go"},
+		},
+		{
+			"after is inclusive of the day", "after:2026-01-02", 1,
+			` AND p.created_at >= $1`,
+			[]interface{}{date("2026-01-03")},
+		},
+		{
+			"before", "before:2026-01-02", 1,
+			` AND p.created_at < $1`,
+			[]interface{}{date("2026-01-02")},
+		},
+		{
+			"has binds nothing", "has:comments topic:go", 1,
+			` AND p.comment_count > 0 AND p.topic_id IN (SELECT id FROM topics WHERE LOWER(name) = LOWER($1) OR slug = LOWER($1))`,
+			[]interface{}{"go"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := Parse(tt.input)
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.input, err)
+			}
+			sql, args := Compile(q, tt.argPos)
+			if sql != tt.wantSQL {
+				t.Errorf("Compile(%q) SQL = %q, want %q", tt.input, sql, tt.wantSQL)
+			}
+			if len(args) != 0 || len(tt.wantArgs) != 0 {
+				if !reflect.DeepEqual(args, tt.wantArgs) {
+					t.Errorf("Compile(%q) args = %#v, want %#v", tt.input, args, tt.wantArgs)
+				}
+			}
+		})
+	}
+}
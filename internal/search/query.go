@@ -0,0 +1,215 @@
+package search
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	maxQueryLength   = 256
+	maxLiteralLength = 200
+	maxTerms         = 20
+	dateLayout       = "2006-01-02"
+)
+
+type ParseError struct {
+	Pos int
+	Msg string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("invalid search query at position %d: %s", e.Pos+1, e.Msg)
+}
+
+type Node interface {
+	node()
+}
+
+type Text struct {
+	Value   string
+	Phrase  bool
+	Negated bool
+}
+
+type Author struct {
+	Username string
+	Negated  bool
+}
+
+type Topic struct {
+	Name    string
+	Negated bool
+}
+
+type Tag struct {
+	Name    string
+	Negated bool
+}
+
+type DateRange struct {
+	Before  bool
+	Date    time.Time
+	Negated bool
+}
+
+type Has struct {
+	Field   string
+	Negated bool
+}
+
+func (Text) node()      {}
+func (Author) node()    {}
+func (Topic) node()     {}
+func (Tag) node()       {}
+func (DateRange) node() {}
+func (Has) node()       {}
+
+type Query struct {
+	Terms []Node
+}
+
+func (q *Query) Empty() bool {
+	return q == nil || len(q.Terms) == 0
+}
+
+var hasFields = map[string]bool{
+	"comments": true,
+}
+
+type token struct {
+	pos     int
+	key     string
+	value   string
+	quoted  bool
+	negated bool
+}
+
+// Literal treats input as plain free text with no operators, trimmed to 200
+// characters. It backs the older search parameters that predate the query
+// language and must keep accepting anything.
+func Literal(input string) *Query {
+	input = strings.TrimSpace(input)
+	if input == "" {
+		return &Query{}
+	}
+	if runes := []rune(input); len(runes) > maxLiteralLength {
+		input = string(runes[:maxLiteralLength])
+	}
+	return &Query{Terms: []Node{Text{Value: input}}}
+}
+
+// Parse reads the search query language. Positions in a ParseError count
+// runes, not bytes.
+func Parse(input string) (*Query, error) {
+	if utf8.RuneCountInString(input) > maxQueryLength {
+		return nil, &ParseError{Pos: maxQueryLength, Msg: fmt.Sprintf("query exceeds %d characters", maxQueryLength)}
+	}
+	tokens, err := tokenize(input)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) > maxTerms {
+		return nil, &ParseError{Pos: tokens[maxTerms].pos, Msg: fmt.Sprintf("query exceeds %d terms", maxTerms)}
+	}
+	q := &Query{Terms: make([]Node, 0, len(tokens))}
+	for _, t := range tokens {
+		n, err := t.toNode()
+		if err != nil {
+			return nil, err
+		}
+		q.Terms = append(q.Terms, n)
+	}
+	return q, nil
+}
+
+func tokenize(input string) ([]token, error) {
+	var tokens []token
+	runes := []rune(input)
+	i := 0
+	for i < len(runes) {
+		if unicode.IsSpace(runes[i]) {
+			i++
+			continue
+		}
+		t := token{pos: i}
+		if runes[i] == '-' {
+			t.negated = true
+			i++
+			if i >= len(runes) || unicode.IsSpace(runes[i]) {
+				return nil, &ParseError{Pos: t.pos, Msg: "'-' must be followed by a term"}
+			}
+		}
+		start := i
+		for i < len(runes) && !unicode.IsSpace(runes[i]) && runes[i] != '"' && runes[i] != ':' {
+			i++
+		}
+		if i < len(runes) && runes[i] == ':' {
+			t.key = strings.ToLower(string(runes[start:i]))
+			if t.key == "" {
+				return nil, &ParseError{Pos: start, Msg: "missing operator name before ':'"}
+			}
+			i++
+			start = i
+		} else {
+			i = start
+		}
+		if i < len(runes) && runes[i] == '"' {
+			end := i + 1
+			for end < len(runes) && runes[end] != '"' {
+				end++
+			}
+			if end >= len(runes) {
+				return nil, &ParseError{Pos: i, Msg: "unterminated quoted phrase"}
+			}
+			t.value = strings.TrimSpace(string(runes[i+1 : end]))
+			t.quoted = true
+			i = end + 1
+		} else {
+			for i < len(runes) && !unicode.IsSpace(runes[i]) {
+				if runes[i] == '"' {
+					return nil, &ParseError{Pos: i, Msg: "unexpected '\"' inside term"}
+				}
+				i++
+			}
+			t.value = string(runes[start:i])
+		}
+		if t.value == "" {
+			if t.key != "" {
+				return nil, &ParseError{Pos: t.pos, Msg: fmt.Sprintf("operator '%s:' requires a value", t.key)}
+			}
+			return nil, &ParseError{Pos: t.pos, Msg: "empty phrase"}
+		}
+		tokens = append(tokens, t)
+	}
+	return tokens, nil
+}
+
+func (t token) toNode() (Node, error) {
+	switch t.key {
+	case "":
+		return Text{Value: t.value, Phrase: t.quoted, Negated: t.negated}, nil
+	case "author":
+		return Author{Username: t.value, Negated: t.negated}, nil
+	case "topic":
+		return Topic{Name: t.value, Negated: t.negated}, nil
+	case "tag":
+		return Tag{Name: strings.ToLower(strings.TrimPrefix(t.value, "#")), Negated: t.negated}, nil
+	case "before", "after":
+		d, err := time.Parse(dateLayout, t.value)
+		if err != nil {
+			return nil, &ParseError{Pos: t.pos, Msg: fmt.Sprintf("'%s:' expects a date in YYYY-MM-DD format", t.key)}
+		}
+		return DateRange{Before: t.key == "before", Date: d, Negated: t.negated}, nil
+	case "has":
+		field := strings.ToLower(t.value)
+		if !hasFields[field] {
+			return nil, &ParseError{Pos: t.pos, Msg: fmt.Sprintf("unsupported value for 'has:': %s", t.value)}
+		}
+		return Has{Field: field, Negated: t.negated}, nil
+	default:
+		return nil, &ParseError{Pos: t.pos, Msg: fmt.Sprintf("unknown operator '%s:'", t.key)}
+	}
+}
@@ -0,0 +1,130 @@
+package search
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func date(s string) time.Time {
+	d, err := time.Parse(dateLayout, s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []Node
+	}{
+		{"empty", "", []Node{}},
+		{"blank", "   \t ", []Node{}},
+		{"words", "go  channels", []Node{Text{Value: "go"}, Text{Value: "channels"}}},
+		{"phrase", `"exact phrase"`, []Node{Text{Value: "exact phrase", Phrase: true}}},
+		{"phrase trimmed", `"  padded "`, []Node{Text{Value: "padded", Phrase: true}}},
+		{"negated word", "-spam", []Node{Text{Value: "spam", Negated: true}}},
+		{"negated phrase", `-"buy now"`, []Node{Text{Value: "buy now", Phrase: true, Negated: true}}},
+		{"hyphen inside word", "e-mail", []Node{Text{Value: "e-mail"}}},
+		{"author", "author:alice", []Node{Author{Username: "alice"}}},
+		{"operator is case-insensitive", "AUTHOR:Alice", []Node{Author{Username: "Alice"}}},
+		{"quoted topic", `topic:"general chat"`, []Node{Topic{Name: "general chat"}}},
+		{"tag lowercased without hash", "tag:#Go", []Node{Tag{Name: "go"}}},
+		{"negated tag", "-tag:meta", []Node{Tag{Name: "meta", Negated: true}}},
+		{"before", "before:2026-01-02", []Node{DateRange{Before: true, Date: date("2026-01-02")}}},
+		{"after", "after:2026-01-02", []Node{DateRange{Date: date("2026-01-02")}}},
+		{"has comments", "has:Comments", []Node{Has{Field: "comments"}}},
+		{"negated has", "-has:comments", []Node{Has{Field: "comments", Negated: true}}},
+		{"value keeps later colons", "author:a:b", []Node{Author{Username: "a:b"}}},
+		{"mixed", `author:bob "race condition" -flaky tag:go`, []Node{
+			Author{Username: "bob"},
+			Text{Value: "race condition", Phrase: true},
+			Text{Value: "flaky", Negated: true},
+			Tag{Name: "go"},
+		}},
+		{"unicode", "café", []Node{Text{Value: "café"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := Parse(tt.input)
+			if err != nil {
+				t.Fatalf("Parse(%q) error: %v", tt.input, err)
+			}
+			if !reflect.DeepEqual(q.Terms, tt.want) {
+				t.Errorf("Parse(%q) = %#v, want %#v", tt.input, q.Terms, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		pos   int
+		msg   string
+	}{
+		{"dangling minus", "go -", 3, "'-' must be followed by a term"},
+		{"minus before space", "- go", 0, "'-' must be followed by a term"},
+		{"missing operator name", ":value", 0, "missing operator name"},
+		{"missing operator value", "go author:", 3, "operator 'author:' requires a value"},
+		{"empty phrase", `""`, 0, "empty phrase"},
+		{"unterminated phrase", `go "open`, 3, "unterminated quoted phrase"},
+		{"quote inside term", `ab"c"`, 2, `unexpected '"' inside term`},
+		{"unknown operator", "go foo:bar", 3, "unknown operator 'foo:'"},
+		{"bad date", "before:yesterday", 0, "expects a date in YYYY-MM-DD format"},
+		{"unsupported has", "has:votes", 0, "unsupported value for 'has:'"},
+		// Positions count runes, so multi-byte text before the error does not
+		// shift them.
+		{"position after unicode", `ééé "open`, 4, "unterminated quoted phrase"},
+		{"too many terms", strings.Repeat("a ", maxTerms) + "b", 2 * maxTerms, "query exceeds 20 terms"},
+		{"too long", strings.Repeat("a", maxQueryLength+1), maxQueryLength, "query exceeds 256 characters"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.input)
+			var pe *ParseError
+			if !errors.As(err, &pe) {
+				t.Fatalf("Parse(%q) error = %v, want *ParseError", tt.input, err)
+			}
+			if pe.Pos != tt.pos {
+				t.Errorf("Parse(%q) position = %d, want %d", tt.input, pe.Pos, tt.pos)
+			}
+			if !strings.Contains(pe.Msg, tt.msg) {
+				t.Errorf("Parse(%q) message = %q, want it to contain %q", tt.input, pe.Msg, tt.msg)
+			}
+		})
+	}
+}
+
+func TestParseLengthCountsRunes(t *testing.T) {
+	// 256 two-byte runes are 512 bytes but still within the limit.
+	if _, err := Parse(strings.Repeat("é", maxQueryLength)); err != nil {
+		t.Fatalf("Parse of %d runes: %v", maxQueryLength, err)
+	}
+}
+
+func TestLiteral(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []Node
+	}{
+		{"empty", "  ", nil},
+		{"operators are text", `author:bob "unbalanced`, []Node{Text{Value: `author:bob "unbalanced`}}},
+		{"trimmed", "  go  ", []Node{Text{Value: "go"}}},
+		{"long input is cut", strings.Repeat("é", 300), []Node{Text{Value: strings.Repeat("é", maxLiteralLength)}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := Literal(tt.input)
+			if !reflect.DeepEqual(q.Terms, tt.want) {
+				t.Errorf("Literal(%q) = %#v, want %#v", tt.input, q.Terms, tt.want)
+			}
+		})
+	}
+}
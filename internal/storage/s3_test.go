@@ -0,0 +1,142 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestS3Sign(t *testing.T) {
+	s, err := NewS3Store(S3Config{
+		Endpoint:        "http://minio:9000",
+		Bucket:          "uploads",
+		AccessKeyID:     "AKID",
+		SecretAccessKey: "secret",
+	})
+	if err != nil {
+		t.Fatalf("NewS3Store: %v", err)
+	}
+	req, _ := http.NewRequest(http.MethodPut, "http://minio:9000/uploads/ab/ab%20c.txt", nil)
+	s.sign(req, "/uploads/ab/ab%20c.txt", []byte("hello"), "text/plain", time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC))
+	// Reference signature computed independently of this package.
+	want := "AWS4-HMAC-SHA256 Credential=AKID/20240102/us-east-1/s3/aws4_request, " +
+		"SignedHeaders=content-type;host;x-amz-content-sha256;x-amz-date, " +
+		"Signature=eec866fa6096d4553fa3a1eba36346cdeafd09f74711e1c1da85f52a7ae91a5b"
+	if got := req.Header.Get("Authorization"); got != want {
+		t.Errorf("Authorization =\n%s\nwant\n%s", got, want)
+	}
+	if got := req.Header.Get("x-amz-date"); got != "20240102T030405Z" {
+		t.Errorf("x-amz-date = %q", got)
+	}
+}
+
+// s3StandIn is an in-memory stand-in for an S3 bucket that checks each
+// request is signed for the expected credentials and payload.
+type s3StandIn struct {
+	t       *testing.T
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func (s *s3StandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	auth := r.Header.Get("Authorization")
+	date := r.Header.Get("x-amz-date")
+	if len(date) < 8 ||
+		!strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKID/"+date[:8]+"/eu-west-1/s3/aws4_request, ") ||
+		!strings.Contains(auth, "SignedHeaders=") || !strings.Contains(auth, "host;x-amz-content-sha256;x-amz-date") {
+		s.t.Errorf("%s %s: bad Authorization %q", r.Method, r.URL.Path, auth)
+		http.Error(w, "AccessDenied", http.StatusForbidden)
+		return
+	}
+	if got := r.Header.Get("x-amz-content-sha256"); got != sha256Hex(body) {
+		s.t.Errorf("%s %s: payload hash %q does not match the body", r.Method, r.URL.Path, got)
+		http.Error(w, "XAmzContentSHA256Mismatch", http.StatusBadRequest)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch r.Method {
+	case http.MethodPut:
+		s.objects[r.URL.Path] = body
+	case http.MethodGet:
+		data, ok := s.objects[r.URL.Path]
+		if !ok {
+			http.Error(w, "NoSuchKey", http.StatusNotFound)
+			return
+		}
+		w.Write(data)
+	case http.MethodDelete:
+		delete(s.objects, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "MethodNotAllowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func TestS3StoreAgainstStandIn(t *testing.T) {
+	standIn := &s3StandIn{t: t, objects: map[string][]byte{}}
+	srv := httptest.NewServer(standIn)
+	defer srv.Close()
+	s, err := NewS3Store(S3Config{
+		Endpoint:        srv.URL + "/",
+		Region:          "eu-west-1",
+		Bucket:          "uploads",
+		AccessKeyID:     "AKID",
+		SecretAccessKey: "secret",
+	})
+	if err != nil {
+		t.Fatalf("NewS3Store: %v", err)
+	}
+	ctx := context.Background()
+	key := "ab/report 1.pdf"
+	if err := s.Put(ctx, key, []byte("contents"), "application/pdf"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if _, ok := standIn.objects["/uploads/ab/report 1.pdf"]; !ok {
+		t.Fatalf("object stored under %v, want /uploads/ab/report 1.pdf", standIn.objects)
+	}
+	r, err := s.Get(ctx, key)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	data, _ := io.ReadAll(r)
+	r.Close()
+	if !bytes.Equal(data, []byte("contents")) {
+		t.Errorf("Get = %q, want %q", data, "contents")
+	}
+	if err := s.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := s.Get(ctx, key); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get after Delete error = %v, want ErrNotFound", err)
+	}
+	if err := s.Delete(ctx, key); err != nil {
+		t.Errorf("Delete of a missing object = %v, want nil", err)
+	}
+}
+
+func TestS3StoreErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "<Error><Code>AccessDenied</Code></Error>", http.StatusForbidden)
+	}))
+	defer srv.Close()
+	s, err := NewS3Store(S3Config{Endpoint: srv.URL, Bucket: "b", AccessKeyID: "a", SecretAccessKey: "s"})
+	if err != nil {
+		t.Fatalf("NewS3Store: %v", err)
+	}
+	err = s.Put(context.Background(), "k", []byte("x"), "")
+	if err == nil || !strings.Contains(err.Error(), "403") || !strings.Contains(err.Error(), "AccessDenied") {
+		t.Errorf("Put error = %v, want the 403 response", err)
+	}
+	if _, err := NewS3Store(S3Config{Bucket: "b"}); err == nil {
+		t.Error("NewS3Store accepted a config without credentials")
+	}
+}
@@ -0,0 +1,324 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Config struct {
+	Interval     time.Duration
+	BatchSize    int
+	MaxAttempts  int
+	Timeout      time.Duration
+	LogRetention time.Duration
+}
+
+// ConfigFromEnv reads WEBHOOK_INTERVAL (0 disables the worker),
+// WEBHOOK_BATCH_SIZE, WEBHOOK_MAX_ATTEMPTS, WEBHOOK_TIMEOUT and
+// WEBHOOK_LOG_RETENTION.
+func ConfigFromEnv() Config {
+	cfg := Config{
+		Interval:     10 * time.Second,
+		BatchSize:    50,
+		MaxAttempts:  8,
+		Timeout:      10 * time.Second,
+		LogRetention: 30 * 24 * time.Hour,
+	}
+	if s := os.Getenv("WEBHOOK_INTERVAL"); s != "" {
+		if v, err := time.ParseDuration(s); err == nil && v >= 0 {
+			cfg.Interval = v
+		} else {
+			log.Printf("WARN: Ignoring invalid WEBHOOK_INTERVAL %q", s)
+		}
+	}
+	if v, err := strconv.Atoi(os.Getenv("WEBHOOK_BATCH_SIZE")); err == nil && v > 0 {
+		cfg.BatchSize = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("WEBHOOK_MAX_ATTEMPTS")); err == nil && v > 0 {
+		cfg.MaxAttempts = v
+	}
+	if v, err := time.ParseDuration(os.Getenv("WEBHOOK_TIMEOUT")); err == nil && v > 0 {
+		cfg.Timeout = v
+	}
+	if v, err := time.ParseDuration(os.Getenv("WEBHOOK_LOG_RETENTION")); err == nil && v > 0 {
+		cfg.LogRetention = v
+	}
+	return cfg
+}
+
+type Result struct {
+	Delivered int64
+	Retried   int64
+	Dead      int64
+	Pruned    int64
+}
+
+var metrics = expvar.NewMap("webhooks")
+
+// Sign returns the X-ThreadTalk-Signature value for body sent at timestamp:
+// "sha256=" and the hex HMAC-SHA256 of "<timestamp>.<body>" keyed with the
+// webhook's secret. Receivers should recompute it, compare in constant time
+// and reject stale timestamps.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Dispatcher delivers queued webhook_deliveries to their webhooks. A
+// delivery succeeds on any 2xx response; anything else, including redirects
+// and timeouts, is retried with exponential backoff until MaxAttempts, after
+// which the delivery is dead-lettered and can be retried by an admin.
+// Deliveries of inactive webhooks wait until the webhook is re-enabled.
+type Dispatcher struct {
+	db       *sql.DB
+	cfg      Config
+	client   *http.Client
+	stopChan chan struct{}
+	stopped  sync.Once
+	done     chan struct{}
+}
+
+func NewDispatcher(db *sql.DB, cfg Config) *Dispatcher {
+	return &Dispatcher{
+		db:  db,
+		cfg: cfg,
+		client: &http.Client{
+			Timeout: cfg.Timeout,
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		stopChan: make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+func (d *Dispatcher) Start() {
+	if d.cfg.Interval == 0 {
+		log.Println("Webhook dispatcher disabled")
+		close(d.done)
+		return
+	}
+	log.Printf("Webhook dispatcher started (interval %s, max attempts %d)", d.cfg.Interval, d.cfg.MaxAttempts)
+	go d.loop()
+}
+
+func (d *Dispatcher) Stop() {
+	d.stopped.Do(func() {
+		close(d.stopChan)
+	})
+	<-d.done
+}
+
+func (d *Dispatcher) loop() {
+	defer close(d.done)
+	ticker := time.NewTicker(d.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		d.runLogged()
+		select {
+		case <-d.stopChan:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (d *Dispatcher) runLogged() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	go func() {
+		select {
+		case <-d.stopChan:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	res, err := d.RunOnce(ctx)
+	metrics.Add("runs", 1)
+	metrics.Add("delivered", res.Delivered)
+	metrics.Add("retried", res.Retried)
+	metrics.Add("dead", res.Dead)
+	metrics.Add("pruned", res.Pruned)
+	last := new(expvar.Int)
+	last.Set(time.Now().Unix())
+	metrics.Set("last_run_unix", last)
+	lastErr := new(expvar.String)
+	if err != nil {
+		lastErr.Set(err.Error())
+		log.Printf("ERROR: Webhook run failed: %v", err)
+	}
+	metrics.Set("last_error", lastErr)
+	if res.Delivered+res.Retried+res.Dead > 0 {
+		log.Printf("Webhooks delivered %d, will retry %d, dead-lettered %d", res.Delivered, res.Retried, res.Dead)
+	}
+}
+
+// RunOnce sends up to BatchSize due deliveries and prunes finished ones
+// older than LogRetention.
+func (d *Dispatcher) RunOnce(ctx context.Context) (Result, error) {
+	var res Result
+	for i := 0; i < d.cfg.BatchSize; i++ {
+		found, err := d.deliverNext(ctx, &res)
+		if err != nil {
+			return res, fmt.Errorf("deliver webhook: %w", err)
+		}
+		if !found {
+			break
+		}
+	}
+	r, err := d.db.ExecContext(ctx, `
+		DELETE FROM webhook_deliveries
+		WHERE status IN ('delivered', 'dead') AND created_at < $1`, time.Now().Add(-d.cfg.LogRetention))
+	if err != nil {
+		return res, fmt.Errorf("prune deliveries: %w", err)
+	}
+	res.Pruned, _ = r.RowsAffected()
+	return res, nil
+}
+
+type envelope struct {
+	ID        int64           `json:"id,string"`
+	Event     string          `json:"event"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// retryDelay is how long to wait after the given number of failed attempts:
+// 30 seconds, doubling up to six hours.
+func retryDelay(attempts int) time.Duration {
+	delay := 30 * time.Second
+	for i := 1; i < attempts && delay < 6*time.Hour; i++ {
+		delay *= 2
+	}
+	return min(delay, 6*time.Hour)
+}
+
+// deliverNext claims one due delivery, sends it and records the outcome. The
+// claim is committed before the request is made: it counts the attempt and
+// pushes next_attempt_at past the request timeout, so other replicas leave
+// the delivery alone while it is in flight and pick it up again if this one
+// dies before recording the outcome.
+func (d *Dispatcher) deliverNext(ctx context.Context, res *Result) (bool, error) {
+	var env envelope
+	var url, secret string
+	var payload []byte
+	var attempts int
+	lease := d.cfg.Timeout + time.Minute
+	err := d.db.QueryRowContext(ctx, `
+		UPDATE webhook_deliveries d SET
+			attempts = d.attempts + 1,
+			next_attempt_at = CURRENT_TIMESTAMP + make_interval(secs => $1)
+		FROM webhooks w
+		WHERE w.id = d.webhook_id AND d.id = (
+			SELECT due.id
+			FROM webhook_deliveries due
+			JOIN webhooks hook ON hook.id = due.webhook_id
+			WHERE due.status = 'pending' AND due.next_attempt_at <= CURRENT_TIMESTAMP AND hook.active
+			ORDER BY due.next_attempt_at, due.id
+			LIMIT 1
+			FOR UPDATE OF due SKIP LOCKED
+		)
+		RETURNING d.id, d.event, d.created_at, d.payload, w.url, w.secret, d.attempts`, lease.Seconds()).
+		Scan(&env.ID, &env.Event, &env.CreatedAt, &payload, &url, &secret, &attempts)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	env.Data = payload
+	body, err := json.Marshal(env)
+	if err != nil {
+		return false, err
+	}
+	status, sendErr := d.send(ctx, url, secret, env, body)
+	// The outcome only applies to this claim; if an admin requeued the
+	// delivery meanwhile, attempts no longer match and it is left pending.
+	if sendErr == nil {
+		_, err = d.db.ExecContext(ctx, `
+			UPDATE webhook_deliveries
+			SET status = 'delivered', response_status = $3, last_error = '', delivered_at = CURRENT_TIMESTAMP
+			WHERE id = $1 AND attempts = $2`, env.ID, attempts, status)
+		if err != nil {
+			return false, err
+		}
+		res.Delivered++
+		return true, nil
+	}
+	msg := sendErr.Error()
+	if len(msg) > 500 {
+		msg = msg[:500]
+	}
+	msg = strings.ToValidUTF8(msg, "")
+	var responseStatus *int
+	if status != 0 {
+		responseStatus = &status
+	}
+	dead := attempts >= d.cfg.MaxAttempts
+	next := "pending"
+	if dead {
+		next = "dead"
+	}
+	_, err = d.db.ExecContext(ctx, `
+		UPDATE webhook_deliveries SET
+			response_status = $3,
+			last_error = $4,
+			status = $5,
+			next_attempt_at = CURRENT_TIMESTAMP + make_interval(secs => $6)
+		WHERE id = $1 AND attempts = $2`, env.ID, attempts, responseStatus, msg, next, retryDelay(attempts).Seconds())
+	if err != nil {
+		return false, err
+	}
+	if dead {
+		log.Printf("WARN: Webhook delivery %d dead-lettered: %s", env.ID, msg)
+		res.Dead++
+	} else {
+		res.Retried++
+	}
+	return true, nil
+}
+
+// send posts body to url and returns the response status, with an error for
+// anything but 2xx.
+func (d *Dispatcher) send(ctx context.Context, url, secret string, env envelope, body []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "ThreadTalk-Webhooks/1.0")
+	req.Header.Set("X-ThreadTalk-Event", env.Event)
+	req.Header.Set("X-ThreadTalk-Delivery", strconv.FormatInt(env.ID, 10))
+	req.Header.Set("X-ThreadTalk-Timestamp", strconv.FormatInt(timestamp, 10))
+	req.Header.Set("X-ThreadTalk-Signature", Sign(secret, timestamp, body))
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	snippet, _ := io.ReadAll(io.LimitReader(resp.Body, 200))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, errors.New("unexpected response " + resp.Status + ": " + string(snippet))
+	}
+	return resp.StatusCode, nil
+}
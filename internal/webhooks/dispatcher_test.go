@@ -0,0 +1,115 @@
+package webhooks
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSign(t *testing.T) {
+	// Reference value computed independently of this package.
+	want := "sha256=086f6aff7bd084c98679825129c5a64dbad88c760016d6d2c0fb123f27951d54"
+	if got := Sign("secret", 1700000000, []byte(`{"id":"1"}`)); got != want {
+		t.Errorf("Sign = %q, want %q", got, want)
+	}
+	if Sign("secret", 1700000001, []byte(`{"id":"1"}`)) == want {
+		t.Error("signature does not cover the timestamp")
+	}
+	if Sign("other", 1700000000, []byte(`{"id":"1"}`)) == want {
+		t.Error("signature does not depend on the secret")
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{8, 64 * time.Minute},
+		{10, 256 * time.Minute},
+		{11, 6 * time.Hour},
+		{1000, 6 * time.Hour},
+	}
+	for _, tt := range tests {
+		if got := retryDelay(tt.attempts); got != tt.want {
+			t.Errorf("retryDelay(%d) = %s, want %s", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestSend(t *testing.T) {
+	var got *http.Request
+	var gotBody []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		gotBody, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+	d := NewDispatcher(nil, Config{Timeout: 5 * time.Second})
+	body := []byte(`{"id":"42"}`)
+	status, err := d.send(context.Background(), srv.URL, "secret", envelope{ID: 42, Event: "post.created"}, body)
+	if err != nil || status != http.StatusAccepted {
+		t.Fatalf("send = %d, %v; want 202, nil", status, err)
+	}
+	if string(gotBody) != string(body) {
+		t.Errorf("body = %s, want %s", gotBody, body)
+	}
+	for header, want := range map[string]string{
+		"Content-Type":          "application/json",
+		"X-ThreadTalk-Event":    "post.created",
+		"X-ThreadTalk-Delivery": "42",
+	} {
+		if v := got.Header.Get(header); v != want {
+			t.Errorf("%s = %q, want %q", header, v, want)
+		}
+	}
+	timestamp, err := strconv.ParseInt(got.Header.Get("X-ThreadTalk-Timestamp"), 10, 64)
+	if err != nil {
+		t.Fatalf("bad timestamp header: %v", err)
+	}
+	if sig := got.Header.Get("X-ThreadTalk-Signature"); sig != Sign("secret", timestamp, body) {
+		t.Errorf("signature %q does not verify", sig)
+	}
+}
+
+func TestSendFailures(t *testing.T) {
+	tests := []struct {
+		name       string
+		handler    http.HandlerFunc
+		wantStatus int
+		wantErr    string
+	}{
+		{"server error", func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "overloaded", http.StatusServiceUnavailable)
+		}, http.StatusServiceUnavailable, "503 Service Unavailable: overloaded"},
+		{"redirect is not followed", func(w http.ResponseWriter, r *http.Request) {
+			http.Redirect(w, r, "/elsewhere", http.StatusFound)
+		}, http.StatusFound, "unexpected response 302"},
+		{"timeout", func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(200 * time.Millisecond)
+		}, 0, "Timeout"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(tt.handler)
+			defer srv.Close()
+			d := NewDispatcher(nil, Config{Timeout: 50 * time.Millisecond})
+			status, err := d.send(context.Background(), srv.URL, "secret", envelope{ID: 1, Event: "post.created"}, []byte(`{}`))
+			if status != tt.wantStatus {
+				t.Errorf("status = %d, want %d", status, tt.wantStatus)
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
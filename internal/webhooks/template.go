@@ -0,0 +1,68 @@
+package webhooks
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+	"unicode/utf8"
+)
+
+// maxTemplateOutput bounds what a template may produce before it is trimmed
+// to the post or comment limits, so a template ranging over a large payload
+// cannot build an unbounded string.
+const maxTemplateOutput = 64 << 10
+
+// ParseTemplate checks an incoming webhook's title or content template. An
+// empty template is valid and selects the default field.
+func ParseTemplate(text string) (*template.Template, error) {
+	if text == "" {
+		return nil, nil
+	}
+	return template.New("webhook").Option("missingkey=default").Parse(text)
+}
+
+// Render fills text with payload, the decoded JSON body of an incoming
+// webhook. Without a template the first of fields that is set in the payload
+// is used, so plain {"title": ..., "content": ...} or chat-style {"text": ...}
+// bodies work as is. Missing keys render as empty strings.
+func Render(text string, payload map[string]interface{}, fields ...string) (string, error) {
+	if text == "" {
+		for _, f := range fields {
+			if v, ok := payload[f]; ok && v != nil {
+				return strings.TrimSpace(fmt.Sprint(v)), nil
+			}
+		}
+		return "", nil
+	}
+	tmpl, err := ParseTemplate(text)
+	if err != nil {
+		return "", err
+	}
+	var out limitedBuffer
+	if err := tmpl.Execute(&out, payload); err != nil {
+		return "", err
+	}
+	// text/template prints "<no value>" for keys missing from a map.
+	return strings.TrimSpace(strings.ReplaceAll(out.String(), "<no value>", "")), nil
+}
+
+// Truncate shortens s to at most max runes, marking the cut with an ellipsis.
+func Truncate(s string, max int) string {
+	if utf8.RuneCountInString(s) <= max {
+		return s
+	}
+	runes := []rune(s)
+	return strings.TrimSpace(string(runes[:max-1])) + "…"
+}
+
+type limitedBuffer struct {
+	bytes.Buffer
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.Len()+len(p) > maxTemplateOutput {
+		return 0, fmt.Errorf("template output exceeds %d bytes", maxTemplateOutput)
+	}
+	return b.Buffer.Write(p)
+}
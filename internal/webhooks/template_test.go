@@ -0,0 +1,81 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func payload(t *testing.T, s string) map[string]interface{} {
+	t.Helper()
+	var p map[string]interface{}
+	dec := json.NewDecoder(strings.NewReader(s))
+	dec.UseNumber()
+	if err := dec.Decode(&p); err != nil {
+		t.Fatalf("decode %s: %v", s, err)
+	}
+	return p
+}
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		payload  string
+		fields   []string
+		want     string
+	}{
+		{"default field", "", `{"content": "  hello  "}`, []string{"content", "text"}, "hello"},
+		{"fallback field", "", `{"text": "chat message"}`, []string{"content", "text"}, "chat message"},
+		{"null field is skipped", "", `{"content": null, "text": "x"}`, []string{"content", "text"}, "x"},
+		{"no field", "", `{"other": 1}`, []string{"title"}, ""},
+		{"template", "Build {{.build}} {{.status}}", `{"build": 12345678901, "status": "passed"}`, nil, "Build 12345678901 passed"},
+		{"nested", "{{.repo.name}}: {{.commit.message}}", `{"repo": {"name": "api"}, "commit": {"message": "fix"}}`, nil, "api: fix"},
+		{"missing key", "[{{.missing}}] {{.status}}", `{"status": "ok"}`, nil, "[] ok"},
+		{"range", "{{range .items}}{{.}},{{end}}", `{"items": ["a", "b"]}`, nil, "a,b,"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Render(tt.template, payload(t, tt.payload), tt.fields...)
+			if err != nil {
+				t.Fatalf("Render: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Render = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderErrors(t *testing.T) {
+	if _, err := ParseTemplate("{{.unclosed"); err == nil {
+		t.Error("ParseTemplate accepted an unclosed action")
+	}
+	if _, err := Render("{{.a.b}}", payload(t, `{"a": "string"}`)); err == nil {
+		t.Error("Render indexed into a string without an error")
+	}
+	big := payload(t, `{"s": "`+strings.Repeat("x", 1024)+`"}`)
+	tmpl := "{{range $i, $_ := .n}}{{$.s}}{{end}}"
+	big["n"] = make([]int, maxTemplateOutput/1024+1)
+	if _, err := Render(tmpl, big); err == nil || !strings.Contains(err.Error(), "template output exceeds") {
+		t.Errorf("Render error = %v, want the output limit", err)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		in   string
+		max  int
+		want string
+	}{
+		{"short", 10, "short"},
+		{"exactly10!", 10, "exactly10!"},
+		{"hello world, again", 7, "hello…"},
+		{"日本語のテキスト", 4, "日本語…"},
+	}
+	for _, tt := range tests {
+		if got := Truncate(tt.in, tt.max); got != tt.want {
+			t.Errorf("Truncate(%q, %d) = %q, want %q", tt.in, tt.max, got, tt.want)
+		}
+	}
+}